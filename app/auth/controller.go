@@ -6,12 +6,17 @@ import (
 	"gochess/lib/jwt"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 )
 
 const kAuthCookie = "auth"
 
-var kJwtSecret = env.MustEnv("JWT_SECRET")
+// Read when first needed rather than on init, so packages importing auth can be tested
+// without the secret
+var kJwtSecret = sync.OnceValue(func() []byte {
+	return []byte(env.MustEnv("JWT_SECRET"))
+})
 var kCookieExpiryDays = 365
 
 type Controller struct{}
@@ -23,7 +28,7 @@ func NewController() *Controller {
 func (*Controller) registrationHandler(w http.ResponseWriter, r *http.Request) {
 	c := NewBasicClaims()
 
-	token, err := jwt.CreateToken(c, []byte(kJwtSecret))
+	token, err := jwt.CreateToken(c, kJwtSecret())
 	if err != nil {
 		log.Printf("Failed to create new user token: %v", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
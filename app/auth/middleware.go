@@ -22,7 +22,7 @@ func Authenticate(next http.Handler) http.Handler {
 			return
 		}
 		var claims UserClaims
-		if err := jwt.ParseToken(cookie.Value, []byte(kJwtSecret), &claims); err != nil {
+		if err := jwt.ParseToken(cookie.Value, kJwtSecret(), &claims); err != nil {
 			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 			return
 		}
@@ -7,6 +7,8 @@ import (
 var c = NewController()
 
 func RegisterPublicRoutes(r chi.Router) {
+	// Fails on startup rather than on the first request without the secret
+	kJwtSecret()
 	r.Post("/auth/register", c.registrationHandler)
 }
 
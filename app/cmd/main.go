@@ -7,6 +7,8 @@ import (
 	"gochess/game"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	_ "github.com/lib/pq"
 
@@ -22,6 +24,24 @@ func main() {
 		log.Fatalf("DB migration failed: %v", err)
 	}
 
+	conn, err := db.Connection()
+	if err != nil {
+		log.Fatalf("DB connection failed: %v", err)
+	}
+	defer conn.Close()
+
+	log.Printf("Restoring games")
+	abortWindow := game.DefaultAbortWindow
+	if window := os.Getenv("ABORT_WINDOW"); window != "" {
+		if abortWindow, err = time.ParseDuration(window); err != nil {
+			log.Fatalf("Invalid ABORT_WINDOW %q: %v", window, err)
+		}
+	}
+	gameService, err := game.RestoreGameService(game.NewGameStore(conn), abortWindow)
+	if err != nil {
+		log.Fatalf("Restoring games failed: %v", err)
+	}
+
 	router := chi.NewRouter()
 
 	router.Route("/api/v1", func(r chi.Router) {
@@ -30,7 +50,7 @@ func main() {
 		r.Group(func(p chi.Router) {
 			p.Use(auth.Authenticate)
 			auth.RegisterRoutes(p)
-			game.RegisterRoutes(p)
+			game.RegisterRoutes(p, gameService)
 		})
 		auth.RegisterPublicRoutes(r)
 	})
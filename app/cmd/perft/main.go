@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gochess/lib/game"
+	"log"
+	"time"
+)
+
+func main() {
+	fen := flag.String("fen", game.FEN_StartingPosition, "position to count moves from")
+	depth := flag.Int("depth", 4, "number of plies to search")
+	divide := flag.Bool("divide", false, "print the node count below each legal move")
+	flag.Parse()
+
+	g, err := game.ParseFEN(*fen)
+	if err != nil {
+		log.Fatalf("Invalid position: %v", err)
+	}
+	if *depth < 1 {
+		log.Fatalf("Depth must be at least 1, got %d", *depth)
+	}
+
+	start := time.Now()
+	nodes := 0
+	if *divide {
+		for _, division := range g.PerftDivide(*depth) {
+			fmt.Printf("%s: %d\n", division.Move.UCI(), division.Nodes)
+			nodes += division.Nodes
+		}
+		fmt.Println()
+	} else {
+		nodes = g.Perft(*depth)
+	}
+	elapsed := time.Since(start)
+
+	fmt.Printf("Nodes searched: %d\n", nodes)
+	fmt.Printf("Time: %v (%.0f nodes/s)\n", elapsed.Round(time.Millisecond), float64(nodes)/elapsed.Seconds())
+}
@@ -0,0 +1,31 @@
+ALTER TABLE game
+    ADD COLUMN IF NOT EXISTS white_id UUID,
+    ADD COLUMN IF NOT EXISTS black_id UUID,
+    -- Set when one side is played by the engine
+    ADD COLUMN IF NOT EXISTS engine_level INT,
+    ADD COLUMN IF NOT EXISTS engine_side INT,
+    ADD COLUMN IF NOT EXISTS duration_ms BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS increment_ms BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS variant TEXT NOT NULL DEFAULT 'Standard',
+    ADD COLUMN IF NOT EXISTS initial_fen TEXT NOT NULL DEFAULT 'rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1',
+    ADD COLUMN IF NOT EXISTS result INT,
+    ADD COLUMN IF NOT EXISTS draw_reason INT,
+    ADD COLUMN IF NOT EXISTS winner INT,
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS started_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS ended_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS game_active_idx ON game (created_at) WHERE ended_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS game_move (
+    game_id UUID NOT NULL REFERENCES game (id) ON DELETE CASCADE,
+    -- Zero based index of the move in the game
+    ply INT NOT NULL,
+    uci TEXT NOT NULL,
+    san TEXT NOT NULL,
+    -- Both clocks once the move was made, including any increment
+    white_remaining_ms BIGINT NOT NULL,
+    black_remaining_ms BIGINT NOT NULL,
+    played_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (game_id, ply)
+);
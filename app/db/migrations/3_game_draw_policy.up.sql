@@ -0,0 +1,3 @@
+-- Whether threefold repetition and the 50-move rule end the game or have to be claimed
+ALTER TABLE game
+    ADD COLUMN IF NOT EXISTS draw_policy TEXT NOT NULL DEFAULT 'automatic';
@@ -0,0 +1,4 @@
+-- Simple or Bronstein delay on each move, in place of the increment
+ALTER TABLE game
+    ADD COLUMN IF NOT EXISTS delay_ms BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS delay_mode TEXT NOT NULL DEFAULT 'none';
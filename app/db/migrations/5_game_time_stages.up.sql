@@ -0,0 +1,17 @@
+-- Moves in the first stage of the time control, when later stages follow
+ALTER TABLE game
+    ADD COLUMN IF NOT EXISTS stage_moves INT NOT NULL DEFAULT 0;
+
+-- Stages of the time control after the first, as in 40 moves in 90 minutes then 30 minutes
+CREATE TABLE IF NOT EXISTS game_time_stage (
+    game_id UUID NOT NULL REFERENCES game (id) ON DELETE CASCADE,
+    -- One based, the first stage being stored with the game
+    stage INT NOT NULL,
+    -- Zero for the last stage, which lasts the rest of the game
+    moves INT NOT NULL,
+    duration_ms BIGINT NOT NULL,
+    increment_ms BIGINT NOT NULL DEFAULT 0,
+    delay_ms BIGINT NOT NULL DEFAULT 0,
+    delay_mode TEXT NOT NULL DEFAULT 'none',
+    PRIMARY KEY (game_id, stage)
+);
@@ -0,0 +1,14 @@
+-- Correspondence games give each side days for every move instead of a clock
+ALTER TABLE game
+    ADD COLUMN IF NOT EXISTS days_per_move INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS vacation_days INT NOT NULL DEFAULT 0;
+
+-- Vacations taken from correspondence games, during which the side's clock is stopped
+CREATE TABLE IF NOT EXISTS game_vacation (
+    game_id UUID NOT NULL REFERENCES game (id) ON DELETE CASCADE,
+    side INT NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    -- Unset until the side returns or its vacation time runs out
+    ended_at TIMESTAMPTZ,
+    PRIMARY KEY (game_id, side, started_at)
+);
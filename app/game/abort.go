@@ -0,0 +1,49 @@
+package game
+
+import (
+	"fmt"
+	"gochess/lib/game"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How long each side has to make their first move before the game is aborted, unless
+// configured otherwise
+const DefaultAbortWindow = 30 * time.Second
+
+type abortCommand struct {
+	userId uuid.UUID
+	ch     chan<- error
+}
+
+func (s *GameSession) abort(userId uuid.UUID) error {
+	side, exists := s.users[userId]
+	if !exists {
+		return fmt.Errorf("user is not allowed to abort this game")
+	}
+	return s.abortGame(&side)
+}
+
+// Aborts the game, by the side given or automatically when nil
+func (s *GameSession) abortGame(side *game.PieceColor) error {
+	if err := s.game.Abort(); err != nil {
+		return err
+	}
+	event := s.newEvent(GameEvent_Abort)
+	event.Side = side
+	return s.finishWith(&event)
+}
+
+// How long until the game is aborted for the side to move not making their first move.
+// The engine is trusted to move in time.
+func (s *GameSession) timeUntilAbort() (time.Duration, bool) {
+	if s.abortWindow <= 0 || (s.engine != nil && s.game.MovingSide() == s.engineSide) {
+		return 0, false
+	}
+	// Correspondence players have the days of a move to make their first
+	if control := s.game.Control(); control.IsCorrespondence() {
+		return s.game.TimeUntilAbort(control.MoveTime())
+	}
+	return s.game.TimeUntilAbort(s.abortWindow)
+}
@@ -0,0 +1,75 @@
+package game
+
+import (
+	"gochess/lib/game"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAbortWindow(t *testing.T) {
+	const window = 30 * time.Second
+	tests := map[string]struct {
+		moves []string
+		// How long after the last move, or the start, the deadline is checked. Moves are
+		// made half a window apart.
+		wait      time.Duration
+		wantAbort bool
+	}{
+		"White within the window":   {wait: window - time.Second, wantAbort: false},
+		"White past the window":     {wait: window, wantAbort: true},
+		"Black within the window":   {moves: []string{"e2e4"}, wait: window - time.Second, wantAbort: false},
+		"Black past the window":     {moves: []string{"e2e4"}, wait: window, wantAbort: true},
+		"Both moved":                {moves: []string{"e2e4", "e7e5"}, wait: time.Hour, wantAbort: false},
+		"Black's window from e4 on": {moves: []string{"e2e4"}, wait: window / 2, wantAbort: false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			white, black := uuid.New(), uuid.New()
+			users := []uuid.UUID{white, black}
+			store := newFakeStore()
+			source := game.NewFakeTimeSource(testStart)
+			s := newGameService(store, window, source)
+			t.Cleanup(func() { closeSessions(s) })
+			id, events := matchedGame(t, s, white, black)
+			for i, uci := range test.moves {
+				source.Advance(window / 2)
+				mustMove(t, s, id, users[i%2], uci)
+			}
+			expectEvents(t, events, slices.Repeat([]string{GameEvent_Move}, len(test.moves))...)
+
+			source.Advance(test.wait)
+			passDeadline(s, id)
+			if test.wantAbort {
+				expectEvents(t, events, GameEvent_Abort, GameEvent_End)
+			} else if _, err := s.SessionSnapshot(id, white); err != nil {
+				// The deadline has been dealt with once the snapshot is taken
+				t.Fatalf("SessionSnapshot() failed unexpectedly: %v", err)
+			}
+			result := store.result(id)
+			if got := result != nil && result.Result == game.GameResult_Aborted; got != test.wantAbort {
+				t.Errorf("store got result %+v, want aborted %t", result, test.wantAbort)
+			}
+		})
+	}
+}
+
+// Helpers
+
+// Delivers the deadline as the session's timer would, returning once the session has taken
+// it. Commands sent after are handled once it has been dealt with.
+func passDeadline(s *GameService, id uuid.UUID) {
+	s.mu.RLock()
+	session := s.games[id]
+	s.mu.RUnlock()
+	select {
+	case session.deadlines <- struct{}{}:
+	default:
+	}
+	for len(session.deadlines) > 0 {
+		runtime.Gosched()
+	}
+}
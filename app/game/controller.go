@@ -2,8 +2,10 @@ package game
 
 import (
 	"encoding/json"
+	"fmt"
 	"gochess/auth"
 	"gochess/lib/game"
+	"io"
 	"log"
 	"net/http"
 	"time"
@@ -16,9 +18,9 @@ type Controller struct {
 	service *GameService
 }
 
-func NewController() *Controller {
+func NewController(service *GameService) *Controller {
 	return &Controller{
-		service: NewGameService(),
+		service: service,
 	}
 }
 
@@ -35,12 +37,33 @@ func (c *Controller) startGameHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	control := game.TimeControl{
-		Total:     time.Duration(req.DurationMillis) * time.Millisecond,
-		Increment: time.Duration(req.IncrementMillis) * time.Millisecond,
+	control, err := timeControl(req.TimeControlRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	gameId, err := c.service.NewGame(control, user.Id)
+	initial, err := initialState(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	policy, ok := game.ParseDrawPolicy(req.DrawPolicy)
+	if !ok {
+		http.Error(w, "Unknown draw policy", http.StatusBadRequest)
+		return
+	}
+
+	var gameId uuid.UUID
+	switch req.Opponent {
+	case "", Opponent_Human:
+		gameId, err = c.service.NewGame(control, initial, policy, user.Id)
+	case Opponent_Engine:
+		gameId, err = c.service.NewEngineGame(control, initial, policy, user.Id, req.Level)
+	default:
+		http.Error(w, "Unknown opponent", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -57,6 +80,105 @@ func (c *Controller) startGameHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 }
 
+// Lists the games waiting for an opponent, besides the caller's own
+func (c *Controller) listGamesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.Claims(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Query().Get("status") {
+	case "", GameStatus_Open:
+	default:
+		http.Error(w, "Unknown status", http.StatusBadRequest)
+		return
+	}
+
+	res := ListGamesResponse{Games: c.service.OpenSeeks(user.Id)}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+// Streams the seeks opening and closing as Server-Sent Events, starting with those open
+func (c *Controller) lobbyEventsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.Claims(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	events, seeks := c.service.SubscribeLobby(user.Id)
+
+	log.Printf("User %s streaming the lobby\n", user.Id)
+
+	c.serveLobbyEvents(w, r, events, seeks)
+}
+
+// Waits for the user to be paired with an opponent, replying with the game started
+func (c *Controller) matchmakingHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.Claims(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	var req MatchmakingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Failed to decode JSON", http.StatusBadRequest)
+		return
+	}
+	control, err := timeControl(req.TimeControlRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	variant, ok := game.ParseVariant(req.Variant)
+	if !ok {
+		http.Error(w, "Unknown variant", http.StatusBadRequest)
+		return
+	}
+	policy, ok := game.ParseDrawPolicy(req.DrawPolicy)
+	if !ok {
+		http.Error(w, "Unknown draw policy", http.StatusBadRequest)
+		return
+	}
+	ratingRange := RatingRange{Min: req.RatingMin, Max: req.RatingMax}
+
+	gameId, side, err := c.service.Matchmake(r.Context(), user.Id, control, variant, policy, ratingRange)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s was matched into game %s\n", user.Id, gameId)
+
+	res := MatchmakingResponse{Id: gameId, Side: side}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (c *Controller) cancelMatchmakingHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.Claims(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if err := c.service.CancelMatchmaking(user.Id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s stopped matchmaking\n", user.Id)
+
+	w.WriteHeader(http.StatusOK)
+}
+
 func (c *Controller) joinGameHandler(w http.ResponseWriter, r *http.Request) {
 	gameId, user, ok := c.gameParams(w, r)
 	if !ok {
@@ -91,24 +213,43 @@ func (c *Controller) gameSnapshotHandler(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 }
 
+func (c *Controller) gamePGNHandler(w http.ResponseWriter, r *http.Request) {
+	gameId, user, ok := c.gameParams(w, r)
+	if !ok {
+		return
+	}
+
+	pgn, err := c.service.SessionPGN(gameId, user.Id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-chess-pgn")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.WriteString(w, pgn); err != nil {
+		log.Printf("Failed to write PGN for game %s: %v\n", gameId, err)
+	}
+}
+
 func (c *Controller) gameMoveHandler(w http.ResponseWriter, r *http.Request) {
 	gameId, user, ok := c.gameParams(w, r)
 	if !ok {
 		return
 	}
 
-	var move game.Move
-	if err := json.NewDecoder(r.Body).Decode(&move); err != nil {
+	var req MoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Failed to decode JSON", http.StatusBadRequest)
 		return
 	}
 
-	if err := c.service.MakeMove(gameId, user.Id, move); err != nil {
+	if err := c.service.MakeMove(gameId, user.Id, req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("User %s made move %v in game %s\n", user.Id, move, gameId)
+	log.Printf("User %s made move %v in game %s\n", user.Id, req, gameId)
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -129,6 +270,289 @@ func (c *Controller) gameResignHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+func (c *Controller) gameAbortHandler(w http.ResponseWriter, r *http.Request) {
+	gameId, user, ok := c.gameParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.Abort(gameId, user.Id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s aborted game %s\n", user.Id, gameId)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Controller) gameOfferDrawHandler(w http.ResponseWriter, r *http.Request) {
+	gameId, user, ok := c.gameParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.OfferDraw(gameId, user.Id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s offered a draw in game %s\n", user.Id, gameId)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Controller) gameAcceptDrawHandler(w http.ResponseWriter, r *http.Request) {
+	gameId, user, ok := c.gameParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.AcceptDraw(gameId, user.Id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s accepted a draw in game %s\n", user.Id, gameId)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Controller) gameDeclineDrawHandler(w http.ResponseWriter, r *http.Request) {
+	gameId, user, ok := c.gameParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.DeclineDraw(gameId, user.Id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s declined a draw in game %s\n", user.Id, gameId)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Controller) gameClaimDrawHandler(w http.ResponseWriter, r *http.Request) {
+	gameId, user, ok := c.gameParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.ClaimDraw(gameId, user.Id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s claimed a draw in game %s\n", user.Id, gameId)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Controller) gameRequestTakebackHandler(w http.ResponseWriter, r *http.Request) {
+	gameId, user, ok := c.gameParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.RequestTakeback(gameId, user.Id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s requested a takeback in game %s\n", user.Id, gameId)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Controller) gameAcceptTakebackHandler(w http.ResponseWriter, r *http.Request) {
+	gameId, user, ok := c.gameParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.AcceptTakeback(gameId, user.Id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s accepted a takeback in game %s\n", user.Id, gameId)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Controller) gameDeclineTakebackHandler(w http.ResponseWriter, r *http.Request) {
+	gameId, user, ok := c.gameParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.DeclineTakeback(gameId, user.Id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s declined a takeback in game %s\n", user.Id, gameId)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Controller) gameCancelHandler(w http.ResponseWriter, r *http.Request) {
+	gameId, user, ok := c.gameParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.CancelSeek(gameId, user.Id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s cancelled game %s\n", user.Id, gameId)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Controller) gameStartVacationHandler(w http.ResponseWriter, r *http.Request) {
+	gameId, user, ok := c.gameParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.StartVacation(gameId, user.Id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s went on vacation from game %s\n", user.Id, gameId)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Controller) gameEndVacationHandler(w http.ResponseWriter, r *http.Request) {
+	gameId, user, ok := c.gameParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.EndVacation(gameId, user.Id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s returned from vacation in game %s\n", user.Id, gameId)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Upgrades to a WebSocket streaming the game's events, on which the client may also move
+// and resign
+func (c *Controller) gameSocketHandler(w http.ResponseWriter, r *http.Request) {
+	gameId, user, ok := c.gameParams(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := c.service.Subscribe(gameId, user.Id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied with the error
+		c.service.Unsubscribe(gameId, events)
+		return
+	}
+
+	log.Printf("User %s connected to game %s\n", user.Id, gameId)
+
+	c.serveSocket(conn, gameId, user.Id, events)
+}
+
+// Streams the game's events as Server-Sent Events, for clients unable to use the WebSocket.
+// A reconnecting client is first sent the events it missed since its Last-Event-ID.
+func (c *Controller) gameEventsHandler(w http.ResponseWriter, r *http.Request) {
+	gameId, user, ok := c.gameParams(w, r)
+	if !ok {
+		return
+	}
+
+	since, resuming, err := lastEventId(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var events <-chan GameEvent
+	var missed []GameEvent
+	if resuming {
+		events, missed, err = c.service.Resume(gameId, user.Id, since)
+	} else {
+		events, err = c.service.Subscribe(gameId, user.Id)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	log.Printf("User %s streaming events of game %s\n", user.Id, gameId)
+
+	c.serveEvents(w, r, gameId, events, missed)
+}
+
+// The time control requested, with any stages after the first
+func timeControl(req TimeControlRequest) (game.TimeControl, error) {
+	delayMode, ok := game.ParseDelayMode(req.DelayMode)
+	if !ok {
+		return game.TimeControl{}, fmt.Errorf("unknown delay mode")
+	}
+	control := game.TimeControl{
+		Total:     time.Duration(req.DurationMillis) * time.Millisecond,
+		Increment: time.Duration(req.IncrementMillis) * time.Millisecond,
+		Delay:     time.Duration(req.DelayMillis) * time.Millisecond,
+		DelayMode: delayMode,
+		Moves:     req.Moves,
+
+		DaysPerMove:  req.DaysPerMove,
+		VacationDays: req.VacationDays,
+	}
+	for _, stage := range req.Stages {
+		delayMode, ok := game.ParseDelayMode(stage.DelayMode)
+		if !ok {
+			return game.TimeControl{}, fmt.Errorf("unknown delay mode")
+		}
+		control.Later = append(control.Later, game.TimeStage{
+			Moves:     stage.Moves,
+			Time:      time.Duration(stage.DurationMillis) * time.Millisecond,
+			Increment: time.Duration(stage.IncrementMillis) * time.Millisecond,
+			Delay:     time.Duration(stage.DelayMillis) * time.Millisecond,
+			DelayMode: delayMode,
+		})
+	}
+	return control, nil
+}
+
+// The starting position requested, the standard setup unless playing Chess960
+func initialState(req StartGameRequest) (*game.GameState, error) {
+	variant, ok := game.ParseVariant(req.Variant)
+	if !ok {
+		return nil, fmt.Errorf("game: unknown variant %q", req.Variant)
+	}
+	if variant != game.Variant_Chess960 {
+		if req.Chess960Index != nil {
+			return nil, fmt.Errorf("game: chess960_index requires the chess960 variant")
+		}
+		return game.NewGameState(), nil
+	}
+	index := game.RandomChess960Index()
+	if req.Chess960Index != nil {
+		index = *req.Chess960Index
+	}
+	return game.NewChess960GameState(index)
+}
+
 func (c *Controller) gameParams(w http.ResponseWriter, r *http.Request) (uuid.UUID, *auth.UserClaims, bool) {
 	gameId, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
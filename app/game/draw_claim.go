@@ -0,0 +1,30 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type claimDrawCommand struct {
+	userId uuid.UUID
+	ch     chan<- error
+}
+
+// Claims a draw by threefold repetition or the 50-move rule, which only the player on move
+// may do in games played under the claimable draw policy
+func (s *GameSession) claimDraw(userId uuid.UUID) error {
+	side, exists := s.users[userId]
+	if !exists {
+		return fmt.Errorf("user is not allowed to claim a draw in this game")
+	}
+	if side != s.game.MovingSide() {
+		return fmt.Errorf("only the player on move may claim a draw")
+	}
+	if err := s.game.ClaimDraw(); err != nil {
+		return err
+	}
+	event := s.newEvent(GameEvent_Draw)
+	event.Side = &side
+	return s.finishWith(&event)
+}
@@ -0,0 +1,101 @@
+package game
+
+import (
+	"fmt"
+	"gochess/lib/game"
+
+	"github.com/google/uuid"
+)
+
+// A draw offered by one side, waiting for the opponent to accept or decline. Any move, by
+// either side, cancels it.
+type DrawOffer struct {
+	Side game.PieceColor `json:"side"`
+	// Number of moves played when the offer was made
+	Ply int `json:"ply"`
+}
+
+type offerDrawCommand struct {
+	userId uuid.UUID
+	ch     chan<- error
+}
+
+type acceptDrawCommand struct {
+	userId uuid.UUID
+	ch     chan<- error
+}
+
+type declineDrawCommand struct {
+	userId uuid.UUID
+	ch     chan<- error
+}
+
+func (s *GameSession) offerDraw(userId uuid.UUID) error {
+	side, exists := s.users[userId]
+	if !exists {
+		return fmt.Errorf("user is not allowed to offer a draw in this game")
+	}
+	if !s.game.InProgress() {
+		return fmt.Errorf("can't offer a draw, game not in progress")
+	}
+	if s.drawOffer != nil {
+		if s.drawOffer.Side == side {
+			return fmt.Errorf("a draw has already been offered")
+		}
+		// Offering back is as good as accepting
+		return s.acceptDraw(userId)
+	}
+
+	s.drawOffer = &DrawOffer{Side: side, Ply: len(s.game.Snapshot().Moves)}
+	event := s.newEvent(GameEvent_DrawOffer)
+	event.Side = &side
+	s.publish(event)
+
+	// The engine plays on
+	if s.engine != nil {
+		s.declineDrawFor(s.engineSide)
+	}
+	return nil
+}
+
+func (s *GameSession) acceptDraw(userId uuid.UUID) error {
+	side, exists := s.users[userId]
+	if !exists || s.drawOffer == nil || s.drawOffer.Side == side {
+		return fmt.Errorf("there is no draw offer to accept")
+	}
+	if err := s.game.AgreeDraw(); err != nil {
+		return err
+	}
+	s.drawOffer = nil
+	event := s.newEvent(GameEvent_Draw)
+	event.Side = &side
+	return s.finishWith(&event)
+}
+
+func (s *GameSession) declineDraw(userId uuid.UUID) error {
+	side, exists := s.users[userId]
+	if !exists || s.drawOffer == nil || s.drawOffer.Side == side {
+		return fmt.Errorf("there is no draw offer to decline")
+	}
+	s.declineDrawFor(side)
+	return nil
+}
+
+func (s *GameSession) declineDrawFor(side game.PieceColor) {
+	s.drawOffer = nil
+	event := s.newEvent(GameEvent_DrawDecline)
+	event.Side = &side
+	s.publish(event)
+}
+
+// Cancels the offer standing, if any, once a move is made or taken back
+func (s *GameSession) cancelDrawOffer() {
+	if s.drawOffer == nil {
+		return
+	}
+	side := s.drawOffer.Side
+	s.drawOffer = nil
+	event := s.newEvent(GameEvent_DrawCancel)
+	event.Side = &side
+	s.publish(event)
+}
@@ -0,0 +1,141 @@
+package game
+
+import (
+	"gochess/lib/game"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestOfferingBackAcceptsDraw(t *testing.T) {
+	white, black := uuid.New(), uuid.New()
+	store := newFakeStore()
+	s, _ := newTestService(t, store)
+	id, events := matchedGame(t, s, white, black)
+
+	if err := s.OfferDraw(id, white); err != nil {
+		t.Fatalf("OfferDraw() by white failed unexpectedly: %v", err)
+	}
+	if err := s.OfferDraw(id, white); err == nil {
+		t.Errorf("OfferDraw() by white twice succeeded")
+	}
+	if err := s.OfferDraw(id, black); err != nil {
+		t.Fatalf("OfferDraw() by black failed unexpectedly: %v", err)
+	}
+	expectEvents(t, events, GameEvent_DrawOffer, GameEvent_Draw, GameEvent_End)
+	if result := store.result(id); result == nil || result.Result != game.GameResult_Draw || result.DrawReason != game.DrawReason_Agreement {
+		t.Errorf("store got result %+v, want a draw by agreement", result)
+	}
+}
+
+func TestMoveCancelsDrawOffer(t *testing.T) {
+	tests := map[string]struct {
+		// Moves played before the offer, the last one cancelling it
+		moves   []string
+		offerer game.PieceColor
+	}{
+		"Offerer moves":           {moves: []string{"e2e4"}, offerer: game.PieceColor_White},
+		"Opponent moves":          {moves: []string{"e2e4"}, offerer: game.PieceColor_Black},
+		"Offerer moves as black":  {moves: []string{"e2e4", "e7e5"}, offerer: game.PieceColor_Black},
+		"Opponent moves as black": {moves: []string{"e2e4", "e7e5"}, offerer: game.PieceColor_White},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			white, black := uuid.New(), uuid.New()
+			users := map[game.PieceColor]uuid.UUID{game.PieceColor_White: white, game.PieceColor_Black: black}
+			s, _ := newTestService(t, newFakeStore())
+			id, events := matchedGame(t, s, white, black)
+			last := len(test.moves) - 1
+			for i, uci := range test.moves[:last] {
+				mustMove(t, s, id, users[game.PieceColor(i%2)], uci)
+			}
+
+			if err := s.OfferDraw(id, users[test.offerer]); err != nil {
+				t.Fatalf("OfferDraw() failed unexpectedly: %v", err)
+			}
+			mustMove(t, s, id, users[game.PieceColor(last%2)], test.moves[last])
+
+			var want []string
+			for range test.moves[:last] {
+				want = append(want, GameEvent_Move)
+			}
+			expectEvents(t, events, append(want, GameEvent_DrawOffer, GameEvent_Move, GameEvent_DrawCancel)...)
+			if err := s.AcceptDraw(id, users[test.offerer.Opponent()]); err == nil {
+				t.Errorf("AcceptDraw() succeeded after the offer was cancelled")
+			}
+		})
+	}
+}
+
+func TestTakebackCancelsDrawOffer(t *testing.T) {
+	white, black := uuid.New(), uuid.New()
+	s, _ := newTestService(t, newFakeStore())
+	id, events := matchedGame(t, s, white, black)
+	mustMove(t, s, id, white, "e2e4")
+	if err := s.OfferDraw(id, black); err != nil {
+		t.Fatalf("OfferDraw() failed unexpectedly: %v", err)
+	}
+	if err := s.RequestTakeback(id, white); err != nil {
+		t.Fatalf("RequestTakeback() failed unexpectedly: %v", err)
+	}
+	if err := s.AcceptTakeback(id, black); err != nil {
+		t.Fatalf("AcceptTakeback() failed unexpectedly: %v", err)
+	}
+	expectEvents(t, events, GameEvent_Move, GameEvent_DrawOffer, GameEvent_TakebackRequest, GameEvent_Takeback, GameEvent_DrawCancel)
+	snap, err := s.SessionSnapshot(id, white)
+	if err != nil {
+		t.Fatalf("SessionSnapshot() failed unexpectedly: %v", err)
+	}
+	if snap.DrawOffer != nil {
+		t.Errorf("draw offer got %+v after the takeback, want none", snap.DrawOffer)
+	}
+}
+
+func TestEngineDeclinesDraw(t *testing.T) {
+	user := uuid.New()
+	s, _ := newTestService(t, newFakeStore())
+	id, err := s.NewEngineGame(game.TimeControl_Hour, game.NewGameState(), game.DrawPolicy_Automatic, user, 1)
+	if err != nil {
+		t.Fatalf("NewEngineGame() failed unexpectedly: %v", err)
+	}
+	events, err := s.Subscribe(id, user)
+	if err != nil {
+		t.Fatalf("Subscribe() failed unexpectedly: %v", err)
+	}
+	if err := s.OfferDraw(id, user); err != nil {
+		t.Fatalf("OfferDraw() failed unexpectedly: %v", err)
+	}
+
+	// The engine may be playing white and move at any point
+	var got []string
+	for len(got) < 2 {
+		select {
+		case event := <-events:
+			if event.Type != GameEvent_Move {
+				got = append(got, event.Type)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("events got %v, want [%s %s]", got, GameEvent_DrawOffer, GameEvent_DrawDecline)
+		}
+	}
+	if got[0] != GameEvent_DrawOffer || got[1] != GameEvent_DrawDecline {
+		t.Errorf("events got %v, want [%s %s]", got, GameEvent_DrawOffer, GameEvent_DrawDecline)
+	}
+}
+
+// Helpers
+
+// Starts a game between the users, subscribed to by white
+func matchedGame(t *testing.T, s *GameService, white uuid.UUID, black uuid.UUID) (uuid.UUID, <-chan GameEvent) {
+	t.Helper()
+	id, err := s.NewMatchedGame(game.TimeControl_Hour, game.NewGameState(), game.DrawPolicy_Automatic, white, black)
+	if err != nil {
+		t.Fatalf("NewMatchedGame() failed unexpectedly: %v", err)
+	}
+	events, err := s.Subscribe(id, white)
+	if err != nil {
+		t.Fatalf("Subscribe() failed unexpectedly: %v", err)
+	}
+	return id, events
+}
@@ -0,0 +1,176 @@
+package game
+
+import (
+	"fmt"
+	"gochess/lib/game"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+const (
+	GameEvent_Join   = "join"
+	GameEvent_Move   = "move"
+	GameEvent_Resign = "resign"
+	// Aborted before both sides moved, by the side given or automatically without one
+	GameEvent_Abort = "abort"
+	// Draw offers, whether declined by the opponent or cancelled by a move
+	GameEvent_DrawOffer   = "draw_offer"
+	GameEvent_DrawDecline = "draw_decline"
+	GameEvent_DrawCancel  = "draw_cancel"
+	// A draw offer was accepted or a draw claimed
+	GameEvent_Draw = "draw"
+	// Takeback requests, declined by the opponent or cancelled by a move
+	GameEvent_TakebackRequest = "takeback_request"
+	GameEvent_TakebackDecline = "takeback_decline"
+	GameEvent_TakebackCancel  = "takeback_cancel"
+	// Moves were taken back, leaving the number of moves given
+	GameEvent_Takeback = "takeback"
+	// A side went on vacation from a correspondence game, or returned from it
+	GameEvent_VacationStart = "vacation_start"
+	GameEvent_VacationEnd   = "vacation_end"
+	// The game is over, whether by checkmate, timeout, resignation or a draw
+	GameEvent_End = "end"
+	// Sent first to a resuming subscriber, with the game as it stands
+	GameEvent_Sync = "sync"
+)
+
+// Events buffered per subscriber. One which falls further behind is dropped and has to
+// reconnect.
+const subscriberBufferSize = 64
+
+// Events kept for subscribers to resume from. One resuming from further back, or from
+// before the session was restored, is synced with the whole game instead.
+const eventLogSize = 256
+
+// GameEvent is published by the session to its subscribers whenever the game changes
+type GameEvent struct {
+	// Increases with every event the session publishes. Counted on from the microsecond the
+	// session started, so it also increases across restarts.
+	Id   int64  `json:"id"`
+	Type string `json:"type"`
+	// Number of moves played once the event happened
+	NumMoves int `json:"num_moves"`
+	// Side joining, moving, resigning or making or answering an offer
+	Side          *game.PieceColor          `json:"side,omitempty"`
+	Move          *game.Move                `json:"move,omitempty"`
+	SAN           string                    `json:"san,omitempty"`
+	UCI           string                    `json:"uci,omitempty"`
+	RemainingTime map[game.PieceColor]int64 `json:"remaining_time"`
+	Result        *game.ResultData          `json:"result,omitempty"`
+	// The whole game, with any offer or request pending, when syncing
+	Snapshot *GameSessionSnapshot `json:"snapshot,omitempty"`
+}
+
+type subscribeCommand struct {
+	userId uuid.UUID
+	// When resuming, the id of the last event the subscriber has seen
+	since *int64
+	ch    chan<- subscribeResult
+}
+
+type unsubscribeCommand struct {
+	events <-chan GameEvent
+}
+
+type subscribeResult struct {
+	events <-chan GameEvent
+	// Events the subscriber missed before subscribing, when resuming
+	missed []GameEvent
+	err    error
+}
+
+func (s *GameSession) subscribe(userId uuid.UUID, since *int64) subscribeResult {
+	if _, exists := s.users[userId]; !exists {
+		return subscribeResult{err: fmt.Errorf("no permission to access game")}
+	}
+	events := make(chan GameEvent, subscriberBufferSize)
+	s.subscribers[events] = struct{}{}
+	result := subscribeResult{events: events}
+	if since != nil {
+		result.missed = s.missedEvents(userId, *since)
+	}
+	return result
+}
+
+// The events published after the one with the id given, or the event syncing the whole game
+// when they are no longer all logged
+func (s *GameSession) missedEvents(userId uuid.UUID, since int64) []GameEvent {
+	first := s.lastEventId - int64(len(s.eventLog))
+	if since < first || since > s.lastEventId {
+		return []GameEvent{s.syncEvent(userId)}
+	}
+	return slices.Clone(s.eventLog[since-first:])
+}
+
+// Builds the event carrying the whole game as it stands, which a resuming subscriber may
+// have missed any part of: moves played or taken back, offers and requests made or
+// answered, vacations and the end of the game
+func (s *GameSession) syncEvent(userId uuid.UUID) GameEvent {
+	event := s.newEvent(GameEvent_Sync)
+	// Resuming from the sync picks up from the events after it
+	event.Id = s.lastEventId
+	event.Snapshot = s.gameSnapshot(userId).snapshot
+	return event
+}
+
+func (s *GameSession) unsubscribe(events <-chan GameEvent) {
+	for ch := range s.subscribers {
+		if ch == events {
+			delete(s.subscribers, ch)
+			close(ch)
+			return
+		}
+	}
+}
+
+func (s *GameSession) publish(event GameEvent) {
+	s.lastEventId++
+	event.Id = s.lastEventId
+	s.eventLog = append(s.eventLog, event)
+	if len(s.eventLog) > eventLogSize {
+		s.eventLog = slices.Delete(s.eventLog, 0, len(s.eventLog)-eventLogSize)
+	}
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (s *GameSession) closeSubscribers() {
+	for ch := range s.subscribers {
+		close(ch)
+	}
+	clear(s.subscribers)
+}
+
+// Builds an event carrying the clocks and result as they are now
+func (s *GameSession) newEvent(eventType string) GameEvent {
+	snap := s.game.Snapshot()
+	result, _ := s.game.Result()
+	return GameEvent{
+		Type:          eventType,
+		NumMoves:      len(snap.Moves),
+		RemainingTime: snap.RemainingTime,
+		Result:        result,
+	}
+}
+
+// Builds the event for the move at the index. Clocks and result are those of the game now.
+func (s *GameSession) moveEvent(snap game.GameSnapshot, index int) GameEvent {
+	side := s.game.InitialState().MovingSide()
+	if index%2 == 1 {
+		side = side.Opponent()
+	}
+	event := s.newEvent(GameEvent_Move)
+	event.NumMoves = index + 1
+	event.Side = &side
+	event.Move = &snap.Moves[index]
+	event.SAN = snap.SANMoves[index]
+	event.UCI = snap.UCIMoves[index]
+	return event
+}
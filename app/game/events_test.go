@@ -0,0 +1,146 @@
+package game
+
+import (
+	"gochess/lib/game"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestResumeSyncsPendingOffers(t *testing.T) {
+	white, black := uuid.New(), uuid.New()
+	s, _ := newTestService(t, newFakeStore())
+	id, err := s.NewMatchedGame(game.TimeControl_Hour, game.NewGameState(), game.DrawPolicy_Automatic, white, black)
+	if err != nil {
+		t.Fatalf("NewMatchedGame() failed unexpectedly: %v", err)
+	}
+	mustMove(t, s, id, white, "e2e4")
+	mustMove(t, s, id, black, "e7e5")
+	if err := s.OfferDraw(id, white); err != nil {
+		t.Fatalf("OfferDraw() failed unexpectedly: %v", err)
+	}
+	if err := s.RequestTakeback(id, black); err != nil {
+		t.Fatalf("RequestTakeback() failed unexpectedly: %v", err)
+	}
+
+	// Resuming from before the events logged syncs the whole game
+	events, missed, err := s.Resume(id, black, 0)
+	if err != nil {
+		t.Fatalf("Resume() failed unexpectedly: %v", err)
+	}
+	defer s.Unsubscribe(id, events)
+	if len(missed) != 1 || missed[0].Type != GameEvent_Sync {
+		t.Fatalf("Resume() got missed events %v, want a sync", missed)
+	}
+	snap := missed[0].Snapshot
+	if got := len(snap.Game.Moves); got != 2 {
+		t.Errorf("synced game got %d moves, want 2", got)
+	}
+	if snap.DrawOffer == nil || snap.DrawOffer.Side != game.PieceColor_White {
+		t.Errorf("synced draw offer got %+v, want one by white", snap.DrawOffer)
+	}
+	if snap.TakebackRequest == nil || snap.TakebackRequest.Side != game.PieceColor_Black {
+		t.Errorf("synced takeback request got %+v, want one by black", snap.TakebackRequest)
+	}
+}
+
+func TestResumeReplaysTakeback(t *testing.T) {
+	white, black := uuid.New(), uuid.New()
+	s, _ := newTestService(t, newFakeStore())
+	id, err := s.NewMatchedGame(game.TimeControl_Hour, game.NewGameState(), game.DrawPolicy_Automatic, white, black)
+	if err != nil {
+		t.Fatalf("NewMatchedGame() failed unexpectedly: %v", err)
+	}
+	events, err := s.Subscribe(id, white)
+	if err != nil {
+		t.Fatalf("Subscribe() failed unexpectedly: %v", err)
+	}
+	mustMove(t, s, id, white, "e2e4")
+	mustMove(t, s, id, black, "e7e5")
+	mustMove(t, s, id, white, "g1f3")
+	seen := expectEvents(t, events, GameEvent_Move, GameEvent_Move, GameEvent_Move)
+	s.Unsubscribe(id, events)
+
+	// While the client is away the move is taken back and another played in its place, so
+	// the number of moves is as it was
+	if err := s.RequestTakeback(id, white); err != nil {
+		t.Fatalf("RequestTakeback() failed unexpectedly: %v", err)
+	}
+	if err := s.AcceptTakeback(id, black); err != nil {
+		t.Fatalf("AcceptTakeback() failed unexpectedly: %v", err)
+	}
+	mustMove(t, s, id, white, "b1c3")
+
+	last := seen[len(seen)-1]
+	events, missed, err := s.Resume(id, white, last.Id)
+	if err != nil {
+		t.Fatalf("Resume() failed unexpectedly: %v", err)
+	}
+	defer s.Unsubscribe(id, events)
+	var types []string
+	for i, event := range missed {
+		types = append(types, event.Type)
+		if want := last.Id + int64(i) + 1; event.Id != want {
+			t.Errorf("missed %s event got id %d, want %d", event.Type, event.Id, want)
+		}
+	}
+	if want := []string{GameEvent_TakebackRequest, GameEvent_Takeback, GameEvent_Move}; !slices.Equal(types, want) {
+		t.Fatalf("Resume() got missed events %v, want %v", types, want)
+	}
+	if missed[1].NumMoves != 2 || missed[2].NumMoves != 3 || missed[2].SAN != "Nc3" {
+		t.Errorf("Resume() got takeback to %d moves then %s, want 2 moves then Nc3", missed[1].NumMoves, missed[2].SAN)
+	}
+
+	// Nothing is missed resuming from the latest event
+	resumed, missed, err := s.Resume(id, white, missed[2].Id)
+	if err != nil {
+		t.Fatalf("Resume() failed unexpectedly: %v", err)
+	}
+	defer s.Unsubscribe(id, resumed)
+	if len(missed) != 0 {
+		t.Errorf("Resume() from the latest event got missed events %v, want none", missed)
+	}
+}
+
+func TestResumeAfterRestart(t *testing.T) {
+	white, black := uuid.New(), uuid.New()
+	store := newFakeStore()
+	s, source := newTestService(t, store)
+	id, err := s.NewMatchedGame(game.TimeControl_Hour, game.NewGameState(), game.DrawPolicy_Automatic, white, black)
+	if err != nil {
+		t.Fatalf("NewMatchedGame() failed unexpectedly: %v", err)
+	}
+	events, err := s.Subscribe(id, white)
+	if err != nil {
+		t.Fatalf("Subscribe() failed unexpectedly: %v", err)
+	}
+	mustMove(t, s, id, white, "e2e4")
+	last := expectEvents(t, events, GameEvent_Move)[0]
+	closeSessions(s)
+
+	source.Advance(time.Minute)
+	restored, err := restoreGameService(store, 0, source)
+	if err != nil {
+		t.Fatalf("restoreGameService() failed unexpectedly: %v", err)
+	}
+	t.Cleanup(func() { closeSessions(restored) })
+	mustMove(t, restored, id, black, "e7e5")
+
+	// The events before the restart are gone, but the ids carry on increasing
+	events, missed, err := restored.Resume(id, white, last.Id)
+	if err != nil {
+		t.Fatalf("Resume() failed unexpectedly: %v", err)
+	}
+	defer restored.Unsubscribe(id, events)
+	if len(missed) != 1 || missed[0].Type != GameEvent_Sync {
+		t.Fatalf("Resume() after a restart got missed events %v, want a sync", missed)
+	}
+	if missed[0].Id <= last.Id {
+		t.Errorf("sync after a restart got id %d, want more than %d", missed[0].Id, last.Id)
+	}
+	if got := missed[0].Snapshot.Game.SANMoves; !slices.Equal(got, []string{"e4", "e5"}) {
+		t.Errorf("synced game moves got %v, want [e4 e5]", got)
+	}
+}
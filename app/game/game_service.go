@@ -1,51 +1,215 @@
 package game
 
 import (
+	"context"
 	"fmt"
+	"gochess/lib/engine"
 	"gochess/lib/game"
+	"log"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type GameService struct {
 	games map[uuid.UUID]*GameSession
-	mu    sync.RWMutex
+	store gameStore
+	// Games created by a user and waiting for another to join
+	lobby *Lobby
+	// Pairs users waiting for an opponent into new games
+	matchmaker *Matchmaker
+	// Games are aborted if a side doesn't make their first move within this long
+	abortWindow time.Duration
+	// What the games tell the time by
+	source game.TimeSource
+	mu     sync.RWMutex
 }
 
-func NewGameService() *GameService {
-	return &GameService{
-		games: make(map[uuid.UUID]*GameSession),
+func NewGameService(store *GameStore, abortWindow time.Duration) *GameService {
+	return newGameService(store, abortWindow, game.SystemTime)
+}
+
+// RestoreGameService creates the service with a session for every stored game which
+// hasn't ended, as when starting up. Games which fail to restore are left out.
+func RestoreGameService(store *GameStore, abortWindow time.Duration) (*GameService, error) {
+	return restoreGameService(store, abortWindow, game.SystemTime)
+}
+
+func newGameService(store gameStore, abortWindow time.Duration, source game.TimeSource) *GameService {
+	s := &GameService{
+		games:       make(map[uuid.UUID]*GameSession),
+		store:       store,
+		lobby:       NewLobby(),
+		abortWindow: abortWindow,
+		source:      source,
+	}
+	// Every player has the default rating until users are rated
+	s.matchmaker = NewMatchmaker(s, func(uuid.UUID) int { return DefaultRating })
+	return s
+}
+
+func restoreGameService(store gameStore, abortWindow time.Duration, source game.TimeSource) (*GameService, error) {
+	s := newGameService(store, abortWindow, source)
+	stored, err := store.activeGames()
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range stored {
+		// One game which can't be replayed mustn't keep the others from being played
+		session, err := restoreGameSession(g, store, abortWindow, source)
+		if err != nil {
+			log.Printf("Failed to restore game %s, leaving it out: %v\n", g.id, err)
+			continue
+		}
+		s.games[g.id] = session
+		if g.startedAt == nil && g.engineLevel == nil && len(g.users) == 1 {
+			seek := newSeek(g.id, g.users, g.control, g.variant, g.drawPolicy, g.createdAt)
+			if source.Now().Before(seek.ExpiresAt) {
+				s.openSeek(seek)
+			} else if err := s.expireSeek(g.id); err != nil {
+				log.Printf("Failed to expire game %s: %v\n", g.id, err)
+			}
+		}
 	}
+	return s, nil
 }
 
-func (s *GameService) NewGame(ctrl game.TimeControl, userId uuid.UUID) (uuid.UUID, error) {
+func (s *GameService) NewGame(ctrl game.TimeControl, initial *game.GameState, policy game.DrawPolicy, userId uuid.UUID) (uuid.UUID, error) {
 	if !ctrl.Validate() {
 		return uuid.Nil, fmt.Errorf("game: invalid time control")
 	}
 
 	id := uuid.New()
-	session := NewGameSession(ctrl, userId)
+	session, err := NewGameSession(id, ctrl, initial, policy, userId, s.store, s.abortWindow, s.source)
+	if err != nil {
+		return uuid.Nil, err
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// Nobody can join until the game is added, so its users are only read here
+	s.openSeek(newSeek(id, session.users, ctrl, initial.Variant(), policy, session.createdAt))
 	s.games[id] = session
 	return id, nil
 }
 
+// NewEngineGame starts a game straight away against the engine at the given level
+func (s *GameService) NewEngineGame(ctrl game.TimeControl, initial *game.GameState, policy game.DrawPolicy, userId uuid.UUID, level int) (uuid.UUID, error) {
+	if !ctrl.Validate() {
+		return uuid.Nil, fmt.Errorf("game: invalid time control")
+	}
+	e, err := engine.NewEngine(level)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	id := uuid.New()
+	session, err := NewEngineGameSession(id, ctrl, initial, policy, userId, e, s.store, s.abortWindow, s.source)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.games[id] = session
+	return id, nil
+}
+
+// NewMatchedGame starts a game between two users paired by matchmaking
+func (s *GameService) NewMatchedGame(ctrl game.TimeControl, initial *game.GameState, policy game.DrawPolicy, white uuid.UUID, black uuid.UUID) (uuid.UUID, error) {
+	id := uuid.New()
+	session, err := NewMatchedGameSession(id, ctrl, initial, policy, white, black, s.store, s.abortWindow, s.source)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.games[id] = session
+	return id, nil
+}
+
+// Matchmake waits for the user to be paired with an opponent for the time control, variant
+// and draw policy, returning the game started and the user's side
+func (s *GameService) Matchmake(ctx context.Context, userId uuid.UUID, ctrl game.TimeControl, variant game.Variant, policy game.DrawPolicy, ratingRange RatingRange) (uuid.UUID, game.PieceColor, error) {
+	return s.matchmaker.Enter(ctx, userId, ctrl, variant, policy, ratingRange)
+}
+
+func (s *GameService) CancelMatchmaking(userId uuid.UUID) error {
+	return s.matchmaker.Cancel(userId)
+}
+
 func (s *GameService) JoinGame(gameId uuid.UUID, userId uuid.UUID) error {
 	ch := make(chan error)
 	cmd := joinGameCommand{userId, ch}
 	if err := s.sendCommand(cmd, gameId); err != nil {
 		return err
 	}
-	return <-ch
+	if err := <-ch; err != nil {
+		return err
+	}
+	s.lobby.remove(gameId)
+	return nil
+}
+
+// OpenSeeks returns the games waiting for an opponent, besides those the user created
+func (s *GameService) OpenSeeks(userId uuid.UUID) []Seek {
+	return s.lobby.Seeks(userId)
+}
+
+// SubscribeLobby returns the seeks opening and closing from now on, with those open now,
+// besides those the user created
+func (s *GameService) SubscribeLobby(userId uuid.UUID) (<-chan LobbyEvent, []Seek) {
+	return s.lobby.Subscribe(userId)
+}
+
+func (s *GameService) UnsubscribeLobby(events <-chan LobbyEvent) {
+	s.lobby.Unsubscribe(events)
+}
+
+// CancelSeek cancels a game nobody has joined yet, closing its session
+func (s *GameService) CancelSeek(gameId uuid.UUID, userId uuid.UUID) error {
+	ch := make(chan error)
+	cmd := cancelSeekCommand{userId, ch}
+	if err := s.sendCommand(cmd, gameId); err != nil {
+		return err
+	}
+	if err := <-ch; err != nil {
+		return err
+	}
+	s.CloseSession(gameId)
+	return nil
+}
+
+// Lists the seek in the lobby until it is joined, cancelled or expires
+func (s *GameService) openSeek(seek Seek) {
+	s.lobby.open(seek)
+	time.AfterFunc(seek.ExpiresAt.Sub(s.source.Now()), func() {
+		// Fails harmlessly if the game was joined or cancelled meanwhile
+		_ = s.expireSeek(seek.Id)
+	})
+}
+
+// Aborts the game if nobody joined it before it expired, closing its session
+func (s *GameService) expireSeek(gameId uuid.UUID) error {
+	ch := make(chan error)
+	if err := s.sendCommand(expireSeekCommand{ch}, gameId); err != nil {
+		return err
+	}
+	if err := <-ch; err != nil {
+		return err
+	}
+	s.CloseSession(gameId)
+	return nil
 }
 
-func (s *GameService) MakeMove(gameId uuid.UUID, userId uuid.UUID, move game.Move) error {
+func (s *GameService) MakeMove(gameId uuid.UUID, userId uuid.UUID, req MoveRequest) error {
 	ch := make(chan error)
-	cmd := moveCommand{userId, move, ch}
+	cmd := moveCommand{userId, req, ch}
 	if err := s.sendCommand(cmd, gameId); err != nil {
 		return err
 	}
@@ -81,6 +245,125 @@ func (s *GameService) SessionSnapshot(gameId uuid.UUID, userId uuid.UUID) (*Game
 	return result.snapshot, result.err
 }
 
+func (s *GameService) SessionPGN(gameId uuid.UUID, userId uuid.UUID) (string, error) {
+	ch := make(chan pgnResult)
+	cmd := pgnCommand{userId, ch}
+	if err := s.sendCommand(cmd, gameId); err != nil {
+		return "", err
+	}
+	result := <-ch
+	return result.pgn, result.err
+}
+
+func (s *GameService) Abort(gameId uuid.UUID, userId uuid.UUID) error {
+	ch := make(chan error)
+	cmd := abortCommand{userId, ch}
+	if err := s.sendCommand(cmd, gameId); err != nil {
+		return err
+	}
+	return <-ch
+}
+
+func (s *GameService) OfferDraw(gameId uuid.UUID, userId uuid.UUID) error {
+	ch := make(chan error)
+	cmd := offerDrawCommand{userId, ch}
+	if err := s.sendCommand(cmd, gameId); err != nil {
+		return err
+	}
+	return <-ch
+}
+
+func (s *GameService) AcceptDraw(gameId uuid.UUID, userId uuid.UUID) error {
+	ch := make(chan error)
+	cmd := acceptDrawCommand{userId, ch}
+	if err := s.sendCommand(cmd, gameId); err != nil {
+		return err
+	}
+	return <-ch
+}
+
+func (s *GameService) DeclineDraw(gameId uuid.UUID, userId uuid.UUID) error {
+	ch := make(chan error)
+	cmd := declineDrawCommand{userId, ch}
+	if err := s.sendCommand(cmd, gameId); err != nil {
+		return err
+	}
+	return <-ch
+}
+
+func (s *GameService) ClaimDraw(gameId uuid.UUID, userId uuid.UUID) error {
+	ch := make(chan error)
+	cmd := claimDrawCommand{userId, ch}
+	if err := s.sendCommand(cmd, gameId); err != nil {
+		return err
+	}
+	return <-ch
+}
+
+func (s *GameService) RequestTakeback(gameId uuid.UUID, userId uuid.UUID) error {
+	ch := make(chan error)
+	cmd := requestTakebackCommand{userId, ch}
+	if err := s.sendCommand(cmd, gameId); err != nil {
+		return err
+	}
+	return <-ch
+}
+
+func (s *GameService) AcceptTakeback(gameId uuid.UUID, userId uuid.UUID) error {
+	ch := make(chan error)
+	cmd := acceptTakebackCommand{userId, ch}
+	if err := s.sendCommand(cmd, gameId); err != nil {
+		return err
+	}
+	return <-ch
+}
+
+func (s *GameService) DeclineTakeback(gameId uuid.UUID, userId uuid.UUID) error {
+	ch := make(chan error)
+	cmd := declineTakebackCommand{userId, ch}
+	if err := s.sendCommand(cmd, gameId); err != nil {
+		return err
+	}
+	return <-ch
+}
+
+func (s *GameService) StartVacation(gameId uuid.UUID, userId uuid.UUID) error {
+	ch := make(chan error)
+	cmd := startVacationCommand{userId, ch}
+	if err := s.sendCommand(cmd, gameId); err != nil {
+		return err
+	}
+	return <-ch
+}
+
+func (s *GameService) EndVacation(gameId uuid.UUID, userId uuid.UUID) error {
+	ch := make(chan error)
+	cmd := endVacationCommand{userId, ch}
+	if err := s.sendCommand(cmd, gameId); err != nil {
+		return err
+	}
+	return <-ch
+}
+
+// Subscribe returns the game's events from now on. The channel is closed when the session
+// ends or the subscriber falls too far behind.
+func (s *GameService) Subscribe(gameId uuid.UUID, userId uuid.UUID) (<-chan GameEvent, error) {
+	events, _, err := s.subscribe(gameId, userId, nil)
+	return events, err
+}
+
+// Resume subscribes like Subscribe, also returning the events published after the last one
+// the subscriber saw. If those are no longer at hand, a single event syncing it with the
+// game as it stands is returned instead.
+func (s *GameService) Resume(gameId uuid.UUID, userId uuid.UUID, since int64) (<-chan GameEvent, []GameEvent, error) {
+	return s.subscribe(gameId, userId, &since)
+}
+
+func (s *GameService) Unsubscribe(gameId uuid.UUID, events <-chan GameEvent) {
+	// The session may already be gone, in which case the channel is closed
+	_ = s.sendCommand(unsubscribeCommand{events}, gameId)
+}
+
 func (s *GameService) CloseSession(gameId uuid.UUID) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -89,6 +372,17 @@ func (s *GameService) CloseSession(gameId uuid.UUID) {
 		g.Close()
 		delete(s.games, gameId)
 	}
+	s.lobby.remove(gameId)
+}
+
+func (s *GameService) subscribe(gameId uuid.UUID, userId uuid.UUID, since *int64) (<-chan GameEvent, []GameEvent, error) {
+	ch := make(chan subscribeResult)
+	cmd := subscribeCommand{userId, since, ch}
+	if err := s.sendCommand(cmd, gameId); err != nil {
+		return nil, nil, err
+	}
+	result := <-ch
+	return result.events, result.missed, result.err
 }
 
 func (s *GameService) sendCommand(cmd sessionCommand, gameId uuid.UUID) error {
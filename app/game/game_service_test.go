@@ -0,0 +1,352 @@
+package game
+
+import (
+	"errors"
+	"gochess/lib/engine"
+	"gochess/lib/game"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestRestoreLeavesOutGamesWhichFail(t *testing.T) {
+	white, black := uuid.New(), uuid.New()
+	store := newFakeStore()
+	restored := startedGame(white, black, "e2e4", "e7e5")
+	broken := startedGame(white, black, "e2e4", "e7e4")
+	store.games[restored.id] = &restored
+	store.games[broken.id] = &broken
+
+	s, err := restoreGameService(store, 0, game.NewFakeTimeSource(testStart))
+	if err != nil {
+		t.Fatalf("restoreGameService() failed unexpectedly: %v", err)
+	}
+	t.Cleanup(func() { closeSessions(s) })
+
+	snap, err := s.SessionSnapshot(restored.id, white)
+	if err != nil {
+		t.Fatalf("SessionSnapshot() of the restored game failed unexpectedly: %v", err)
+	}
+	if got := snap.Game.SANMoves; !slices.Equal(got, []string{"e4", "e5"}) {
+		t.Errorf("restored game moves got %v, want [e4 e5]", got)
+	}
+	if _, err := s.SessionSnapshot(broken.id, white); err == nil {
+		t.Errorf("game with an illegal move was restored")
+	}
+}
+
+func TestStoreFailureStopsSession(t *testing.T) {
+	white, black := uuid.New(), uuid.New()
+	store := newFakeStore()
+	s, source := newTestService(t, store)
+	id, err := s.NewMatchedGame(game.TimeControl_Hour, game.NewGameState(), game.DrawPolicy_Automatic, white, black)
+	if err != nil {
+		t.Fatalf("NewMatchedGame() failed unexpectedly: %v", err)
+	}
+	events, err := s.Subscribe(id, white)
+	if err != nil {
+		t.Fatalf("Subscribe() failed unexpectedly: %v", err)
+	}
+
+	source.Advance(time.Second)
+	mustMove(t, s, id, white, "e2e4")
+	expectEvents(t, events, GameEvent_Move)
+	if got := store.game(id).moves[0].playedAt; !got.Equal(source.Now()) {
+		t.Errorf("move stored as played at %v, want %v", got, source.Now())
+	}
+
+	store.failWrites(errors.New("connection lost"))
+	if err := s.MakeMove(id, black, MoveRequest{UCI: "e7e5"}); err == nil {
+		t.Errorf("MakeMove() succeeded without the move being stored")
+	}
+	if event, ok := <-events; ok {
+		t.Errorf("%s event published after the store failed", event.Type)
+	}
+
+	// Once stopped, the session takes no more commands even if the store recovers
+	store.failWrites(nil)
+	if err := s.MakeMove(id, black, MoveRequest{UCI: "e7e5"}); err == nil {
+		t.Errorf("MakeMove() succeeded after the session stopped")
+	}
+	if _, err := s.SessionSnapshot(id, white); err == nil {
+		t.Errorf("SessionSnapshot() succeeded after the session stopped")
+	}
+	if got := len(store.game(id).moves); got != 1 {
+		t.Errorf("store got %d moves, want 1", got)
+	}
+}
+
+func TestStoreFailureWithholdsResult(t *testing.T) {
+	white, black := uuid.New(), uuid.New()
+	store := newFakeStore()
+	s, _ := newTestService(t, store)
+	id, err := s.NewMatchedGame(game.TimeControl_Hour, game.NewGameState(), game.DrawPolicy_Automatic, white, black)
+	if err != nil {
+		t.Fatalf("NewMatchedGame() failed unexpectedly: %v", err)
+	}
+	events, err := s.Subscribe(id, black)
+	if err != nil {
+		t.Fatalf("Subscribe() failed unexpectedly: %v", err)
+	}
+
+	store.failWrites(errors.New("connection lost"))
+	if err := s.Resign(id, white); err == nil {
+		t.Errorf("Resign() succeeded without the result being stored")
+	}
+	if event, ok := <-events; ok {
+		t.Errorf("%s event published after the store failed", event.Type)
+	}
+	if result := store.result(id); result != nil {
+		t.Errorf("store got result %+v, want none", result)
+	}
+}
+
+func TestClosedSessionDropsEngineReplies(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		e, err := engine.NewEngine(engine.MinLevel)
+		if err != nil {
+			t.Fatalf("NewEngine() failed unexpectedly: %v", err)
+		}
+		s, err := NewEngineGameSession(uuid.New(), game.TimeControl_Hour, game.NewGameState(), game.DrawPolicy_Automatic,
+			uuid.New(), e, newFakeStore(), 0, game.NewFakeTimeSource(testStart))
+		if err != nil {
+			t.Fatalf("NewEngineGameSession() failed unexpectedly: %v", err)
+		}
+		s.Close()
+		synctest.Wait()
+
+		// Searches outlasting the session, as when moves are taken back mid-search, give up
+		// their replies rather than wait for a session no longer reading them
+		s.engineSide = s.game.MovingSide()
+		for range 3 {
+			s.think()
+		}
+	})
+}
+
+// Helpers
+
+// Keeps games in memory in place of Postgres. Every write fails while err is set.
+type fakeStore struct {
+	games   map[uuid.UUID]*storedGame
+	results map[uuid.UUID]*game.ResultData
+	// Sides each user played in their latest games, most recent first
+	sides map[uuid.UUID][]game.PieceColor
+	err   error
+	mu    sync.Mutex
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		games:   make(map[uuid.UUID]*storedGame),
+		results: make(map[uuid.UUID]*game.ResultData),
+		sides:   make(map[uuid.UUID][]game.PieceColor),
+	}
+}
+
+func (s *fakeStore) failWrites(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// The game as stored now
+func (s *fakeStore) game(id uuid.UUID) storedGame {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := *s.games[id]
+	g.moves = slices.Clone(g.moves)
+	g.vacations = slices.Clone(g.vacations)
+	return g
+}
+
+func (s *fakeStore) result(id uuid.UUID) *game.ResultData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.results[id]
+}
+
+func (s *fakeStore) createGame(g storedGame) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	g.users = maps.Clone(g.users)
+	s.games[g.id] = &g
+	return nil
+}
+
+func (s *fakeStore) startGame(id uuid.UUID, userId uuid.UUID, side game.PieceColor, startedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.games[id].users[userId] = side
+	s.games[id].startedAt = &startedAt
+	return nil
+}
+
+func (s *fakeStore) addMove(id uuid.UUID, ply int, move storedMove) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.games[id].moves = append(s.games[id].moves[:ply], move)
+	return nil
+}
+
+func (s *fakeStore) takeBack(id uuid.UUID, numMoves int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.games[id].moves = s.games[id].moves[:numMoves]
+	return nil
+}
+
+func (s *fakeStore) startVacation(id uuid.UUID, side game.PieceColor, startedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.games[id].vacations = append(s.games[id].vacations, game.Vacation{Side: side, Start: startedAt})
+	return nil
+}
+
+func (s *fakeStore) endVacation(id uuid.UUID, side game.PieceColor, endedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	for i, vacation := range s.games[id].vacations {
+		if vacation.Side == side && vacation.End == nil {
+			s.games[id].vacations[i].End = &endedAt
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) endGame(id uuid.UUID, result *game.ResultData, endedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.results[id] = result
+	return nil
+}
+
+func (s *fakeStore) activeGames() ([]storedGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var games []storedGame
+	for id, g := range s.games {
+		if _, ended := s.results[id]; !ended {
+			games = append(games, *g)
+		}
+	}
+	return games, nil
+}
+
+func (s *fakeStore) recentSides(userId uuid.UUID, limit int) ([]game.PieceColor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sides := s.sides[userId]
+	return sides[:min(limit, len(sides))], nil
+}
+
+// A service whose games tell the time by a fake time source, never aborting idle games
+func newTestService(t *testing.T, store *fakeStore) (*GameService, *game.FakeTimeSource) {
+	t.Helper()
+	source := game.NewFakeTimeSource(testStart)
+	s := newGameService(store, 0, source)
+	t.Cleanup(func() { closeSessions(s) })
+	return s, source
+}
+
+func closeSessions(s *GameService) {
+	s.mu.RLock()
+	ids := make([]uuid.UUID, 0, len(s.games))
+	for id := range s.games {
+		ids = append(ids, id)
+	}
+	s.mu.RUnlock()
+	for _, id := range ids {
+		s.CloseSession(id)
+	}
+}
+
+// A stored standard game between the users, started at testStart with the moves played
+func startedGame(white uuid.UUID, black uuid.UUID, uciMoves ...string) storedGame {
+	g := storedGame{
+		id:         uuid.New(),
+		users:      map[uuid.UUID]game.PieceColor{white: game.PieceColor_White, black: game.PieceColor_Black},
+		control:    game.TimeControl_Hour,
+		drawPolicy: game.DrawPolicy_Automatic,
+		variant:    game.Variant_Standard,
+		initialFEN: game.NewGameState().FEN(),
+		createdAt:  testStart,
+		startedAt:  &testStart,
+	}
+	for _, uci := range uciMoves {
+		g.moves = append(g.moves, storedMove{
+			uci: uci,
+			remaining: map[game.PieceColor]time.Duration{
+				game.PieceColor_White: time.Hour,
+				game.PieceColor_Black: time.Hour,
+			},
+			playedAt: testStart,
+		})
+	}
+	return g
+}
+
+func mustMove(t *testing.T, s *GameService, id uuid.UUID, userId uuid.UUID, uci string) {
+	t.Helper()
+	if err := s.MakeMove(id, userId, MoveRequest{UCI: uci}); err != nil {
+		t.Fatalf("MakeMove(%s) failed unexpectedly: %v", uci, err)
+	}
+}
+
+// Reads the events published so far, which should be of the types given in order
+func expectEvents(t *testing.T, events <-chan GameEvent, types ...string) []GameEvent {
+	t.Helper()
+	var got []GameEvent
+	var gotTypes []string
+	for range types {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("events got %v then closed, want %v", gotTypes, types)
+			}
+			got = append(got, event)
+			gotTypes = append(gotTypes, event.Type)
+		case <-time.After(time.Second):
+			t.Fatalf("events got %v, want %v", gotTypes, types)
+		}
+	}
+	if !slices.Equal(gotTypes, types) {
+		t.Errorf("events got %v, want %v", gotTypes, types)
+	}
+	select {
+	case event, ok := <-events:
+		if ok {
+			t.Errorf("events got %v then %s, want %v", gotTypes, event.Type, types)
+		}
+	default:
+	}
+	return got
+}
@@ -2,21 +2,70 @@ package game
 
 import (
 	"fmt"
+	"gochess/lib/engine"
 	"gochess/lib/game"
+	"log"
+	"maps"
 	"math/rand"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type GameSession struct {
+	id    uuid.UUID
 	game  *game.Game
 	users map[uuid.UUID]game.PieceColor
 	ch    chan sessionCommand
+	store gameStore
+
+	// Set when the opponent is the built-in engine
+	engine     *engine.Engine
+	engineSide game.PieceColor
+	// Replies are searched off the session goroutine and handed back here. Moves taken back
+	// while searching start another search, so more than one reply may be on its way.
+	engineReplies chan engineReply
+	// Closed once the session stops, so searches still running don't wait to hand back
+	// their replies
+	done chan struct{}
+
+	// Fires when the running clock runs out, the side to move misses the window for their
+	// first move or a side's vacation time runs out, so the game goes on even if neither
+	// player is active
+	deadlineTimer *time.Timer
+	deadlines     chan struct{}
+	abortWindow   time.Duration
+	// Open games expire some time after they are created
+	createdAt time.Time
+
+	subscribers map[chan GameEvent]struct{}
+	// The latest events published, for subscribers to resume from
+	eventLog    []GameEvent
+	lastEventId int64
+	// Set once the end of the game is stored and published
+	finished bool
+	// Set once the game was aborted before anyone joined, cancelled by its creator or expired
+	cancelled bool
+	// Set once the store failed to record a change to the game, after which the session
+	// stops rather than play on from a game other than the one stored
+	failed error
+
+	drawOffer       *DrawOffer
+	takebackRequest *TakebackRequest
 }
 
 type GameSessionSnapshot struct {
-	Game  game.GameSnapshot             `json:"game"`
-	Users map[uuid.UUID]game.PieceColor `json:"users"`
+	Game   game.GameSnapshot             `json:"game"`
+	Users  map[uuid.UUID]game.PieceColor `json:"users"`
+	Engine *EngineSnapshot               `json:"engine,omitempty"`
+	// Pending draw offer and takeback request, if any
+	DrawOffer       *DrawOffer       `json:"draw_offer,omitempty"`
+	TakebackRequest *TakebackRequest `json:"takeback_request,omitempty"`
+}
+
+type EngineSnapshot struct {
+	Side  game.PieceColor `json:"side"`
+	Level int             `json:"level"`
 }
 
 type sessionCommand interface{}
@@ -31,9 +80,14 @@ type snapshotCommand struct {
 	ch     chan<- snapshotResult
 }
 
+type pgnCommand struct {
+	userId uuid.UUID
+	ch     chan<- pgnResult
+}
+
 type moveCommand struct {
 	userId uuid.UUID
-	move   game.Move
+	req    MoveRequest
 	ch     chan<- error
 }
 
@@ -47,16 +101,164 @@ type snapshotResult struct {
 	err      error
 }
 
-func NewGameSession(ctrl game.TimeControl, userId uuid.UUID) *GameSession {
+type pgnResult struct {
+	pgn string
+	err error
+}
+
+type engineReply struct {
+	// Number of moves played and key of the position searched. Moves taken back may bring
+	// a different position to the same move number.
+	numMoves int
+	hash     uint64
+	move     game.Move
+	err      error
+}
+
+func NewGameSession(id uuid.UUID, ctrl game.TimeControl, initial *game.GameState, policy game.DrawPolicy, userId uuid.UUID, store gameStore, abortWindow time.Duration, source game.TimeSource) (*GameSession, error) {
 	session := GameSession{
-		game: game.NewGame(ctrl),
+		id:   id,
+		game: game.NewGameWithTimeSource(ctrl, initial, source),
 		users: map[uuid.UUID]game.PieceColor{
 			userId: game.PieceColor(rand.Intn(2)),
 		},
-		ch: make(chan sessionCommand),
+		ch:          make(chan sessionCommand),
+		done:        make(chan struct{}),
+		store:       store,
+		deadlines:   make(chan struct{}, 1),
+		abortWindow: abortWindow,
+		subscribers: make(map[chan GameEvent]struct{}),
+		createdAt:   source.Now(),
+		lastEventId: source.Now().UnixMicro(),
+	}
+	session.game.SetDrawPolicy(policy)
+	if err := store.createGame(session.stored()); err != nil {
+		return nil, err
+	}
+	go startSession(&session, session.ch)
+	return &session, nil
+}
+
+func NewEngineGameSession(id uuid.UUID, ctrl game.TimeControl, initial *game.GameState, policy game.DrawPolicy, userId uuid.UUID, e *engine.Engine, store gameStore, abortWindow time.Duration, source game.TimeSource) (*GameSession, error) {
+	side := game.PieceColor(rand.Intn(2))
+	session := GameSession{
+		id:   id,
+		game: game.NewGameWithTimeSource(ctrl, initial, source),
+		users: map[uuid.UUID]game.PieceColor{
+			userId: side,
+		},
+		ch:            make(chan sessionCommand),
+		done:          make(chan struct{}),
+		store:         store,
+		engine:        e,
+		engineSide:    side.Opponent(),
+		engineReplies: make(chan engineReply, 1),
+		deadlines:     make(chan struct{}, 1),
+		abortWindow:   abortWindow,
+		subscribers:   make(map[chan GameEvent]struct{}),
+		createdAt:     source.Now(),
+		lastEventId:   source.Now().UnixMicro(),
+	}
+	session.game.SetDrawPolicy(policy)
+	session.game.Start()
+	if err := store.createGame(session.stored()); err != nil {
+		return nil, err
+	}
+	session.armDeadlineTimer()
+	session.think()
+	go startSession(&session, session.ch)
+	return &session, nil
+}
+
+// NewMatchedGameSession starts a game straight away between two users paired by matchmaking
+func NewMatchedGameSession(id uuid.UUID, ctrl game.TimeControl, initial *game.GameState, policy game.DrawPolicy, white uuid.UUID, black uuid.UUID, store gameStore, abortWindow time.Duration, source game.TimeSource) (*GameSession, error) {
+	session := GameSession{
+		id:   id,
+		game: game.NewGameWithTimeSource(ctrl, initial, source),
+		users: map[uuid.UUID]game.PieceColor{
+			white: game.PieceColor_White,
+			black: game.PieceColor_Black,
+		},
+		ch:          make(chan sessionCommand),
+		done:        make(chan struct{}),
+		store:       store,
+		deadlines:   make(chan struct{}, 1),
+		abortWindow: abortWindow,
+		subscribers: make(map[chan GameEvent]struct{}),
+		createdAt:   source.Now(),
+		lastEventId: source.Now().UnixMicro(),
+	}
+	session.game.SetDrawPolicy(policy)
+	session.game.Start()
+	if err := store.createGame(session.stored()); err != nil {
+		return nil, err
+	}
+	session.armDeadlineTimer()
+	go startSession(&session, session.ch)
+	return &session, nil
+}
+
+// Restores the session of a stored game which hadn't ended by replaying its moves
+func restoreGameSession(stored storedGame, store gameStore, abortWindow time.Duration, source game.TimeSource) (*GameSession, error) {
+	initial, err := game.ParseVariantFEN(stored.initialFEN, stored.variant)
+	if err != nil {
+		return nil, err
+	}
+
+	session := GameSession{
+		id:          stored.id,
+		users:       stored.users,
+		ch:          make(chan sessionCommand),
+		done:        make(chan struct{}),
+		store:       store,
+		deadlines:   make(chan struct{}, 1),
+		abortWindow: abortWindow,
+		subscribers: make(map[chan GameEvent]struct{}),
+		createdAt:   stored.createdAt,
+		lastEventId: source.Now().UnixMicro(),
+	}
+	if stored.engineLevel != nil {
+		if session.engine, err = engine.NewEngine(*stored.engineLevel); err != nil {
+			return nil, err
+		}
+		session.engineSide = stored.engineSide
+		session.engineReplies = make(chan engineReply, 1)
 	}
+
+	if stored.startedAt == nil {
+		session.game = game.NewGameWithTimeSource(stored.control, initial, source)
+		session.game.SetDrawPolicy(stored.drawPolicy)
+	} else {
+		moves := make([]game.RestoredMove, len(stored.moves))
+		for i, move := range stored.moves {
+			uciMove, err := game.ParseUCIMove(move.uci)
+			if err != nil {
+				return nil, err
+			}
+			moves[i] = game.RestoredMove{Move: *uciMove, Remaining: move.remaining}
+		}
+		session.game, err = game.RestoreGameWithTimeSource(stored.control, stored.drawPolicy, initial, moves, *stored.startedAt, source)
+		if err != nil {
+			return nil, err
+		}
+		// Correspondence deadlines kept passing while the server was down
+		if stored.control.IsCorrespondence() {
+			turnStart := *stored.startedAt
+			if len(stored.moves) > 0 {
+				turnStart = stored.moves[len(stored.moves)-1].playedAt
+			}
+			session.game.RestoreCorrespondence(turnStart, stored.vacations)
+		}
+	}
+
+	// A clock may have run out before the restart without the game being ended
+	if err := session.finish(); err != nil {
+		return nil, err
+	}
+	session.armDeadlineTimer()
+	session.think()
 	go startSession(&session, session.ch)
-	return &session
+	return &session, nil
 }
 
 func (s *GameSession) Close() {
@@ -64,30 +266,136 @@ func (s *GameSession) Close() {
 }
 
 func startSession(s *GameSession, ch <-chan sessionCommand) {
-	for cmd := range ch {
+	for {
+		var cmd sessionCommand
+		select {
+		case c, ok := <-ch:
+			if !ok {
+				s.stopDeadlineTimer()
+				s.closeSubscribers()
+				close(s.done)
+				return
+			}
+			cmd = c
+		case reply := <-s.engineReplies:
+			if s.failed == nil {
+				s.playEngineMove(reply)
+			}
+			continue
+		case <-s.deadlines:
+			if s.failed == nil {
+				s.deadlinePassed()
+			}
+			continue
+		}
+
+		if s.failed != nil {
+			rejectCommand(cmd, s.failed)
+			continue
+		}
+
 		switch c := cmd.(type) {
 		case joinGameCommand:
 			c.ch <- s.joinGame(c.userId)
 		case snapshotCommand:
 			c.ch <- s.gameSnapshot(c.userId)
+		case pgnCommand:
+			c.ch <- s.gamePGN(c.userId)
 		case moveCommand:
-			c.ch <- s.makeMove(c.userId, c.move)
+			c.ch <- s.makeMove(c.userId, c.req)
 		case resignCommand:
 			c.ch <- s.resign(c.userId)
+		case abortCommand:
+			c.ch <- s.abort(c.userId)
+		case offerDrawCommand:
+			c.ch <- s.offerDraw(c.userId)
+		case acceptDrawCommand:
+			c.ch <- s.acceptDraw(c.userId)
+		case declineDrawCommand:
+			c.ch <- s.declineDraw(c.userId)
+		case claimDrawCommand:
+			c.ch <- s.claimDraw(c.userId)
+		case requestTakebackCommand:
+			c.ch <- s.requestTakeback(c.userId)
+		case acceptTakebackCommand:
+			c.ch <- s.acceptTakeback(c.userId)
+		case declineTakebackCommand:
+			c.ch <- s.declineTakeback(c.userId)
+		case cancelSeekCommand:
+			c.ch <- s.cancelSeek(c.userId)
+		case expireSeekCommand:
+			c.ch <- s.expireSeek()
+		case startVacationCommand:
+			c.ch <- s.startVacation(c.userId)
+		case endVacationCommand:
+			c.ch <- s.endVacation(c.userId)
+		case subscribeCommand:
+			c.ch <- s.subscribe(c.userId, c.since)
+		case unsubscribeCommand:
+			s.unsubscribe(c.events)
 		default:
 			panic(fmt.Sprintf("Unknown command send to game service: %v", c))
 		}
 	}
 }
 
+// Replies to the command with the error instead of running it
+func rejectCommand(cmd sessionCommand, err error) {
+	switch c := cmd.(type) {
+	case joinGameCommand:
+		c.ch <- err
+	case snapshotCommand:
+		c.ch <- snapshotResult{err: err}
+	case pgnCommand:
+		c.ch <- pgnResult{err: err}
+	case moveCommand:
+		c.ch <- err
+	case resignCommand:
+		c.ch <- err
+	case abortCommand:
+		c.ch <- err
+	case offerDrawCommand:
+		c.ch <- err
+	case acceptDrawCommand:
+		c.ch <- err
+	case declineDrawCommand:
+		c.ch <- err
+	case claimDrawCommand:
+		c.ch <- err
+	case requestTakebackCommand:
+		c.ch <- err
+	case acceptTakebackCommand:
+		c.ch <- err
+	case declineTakebackCommand:
+		c.ch <- err
+	case cancelSeekCommand:
+		c.ch <- err
+	case expireSeekCommand:
+		c.ch <- err
+	case startVacationCommand:
+		c.ch <- err
+	case endVacationCommand:
+		c.ch <- err
+	case subscribeCommand:
+		c.ch <- subscribeResult{err: err}
+	case unsubscribeCommand:
+		// The subscribers were closed when the session stopped
+	default:
+		panic(fmt.Sprintf("Unknown command send to game service: %v", c))
+	}
+}
+
 func (s *GameSession) joinGame(userId uuid.UUID) error {
-	if len(s.users) != 1 {
+	if len(s.users) != 1 || s.engine != nil {
 		return fmt.Errorf("game is already full")
 	}
 
 	if _, exists := s.users[userId]; exists {
 		return fmt.Errorf("user is already in the game")
 	}
+	if s.cancelled {
+		return fmt.Errorf("game was cancelled")
+	}
 
 	var side game.PieceColor
 	for _, v := range s.users {
@@ -95,9 +403,17 @@ func (s *GameSession) joinGame(userId uuid.UUID) error {
 		break
 	}
 
-	s.users[userId] = side.Opponent()
+	joined := side.Opponent()
+	s.users[userId] = joined
 	s.game.Start()
+	if err := s.store.startGame(s.id, userId, joined, s.game.StartTime()); err != nil {
+		return s.storeFailed(err)
+	}
+	s.armDeadlineTimer()
 
+	event := s.newEvent(GameEvent_Join)
+	event.Side = &joined
+	s.publish(event)
 	return nil
 }
 
@@ -106,17 +422,72 @@ func (s *GameSession) gameSnapshot(userId uuid.UUID) snapshotResult {
 		return snapshotResult{nil, fmt.Errorf("no permission to access game")}
 	}
 	snap := &GameSessionSnapshot{
-		Game:  s.game.Snapshot(),
-		Users: s.users,
+		Game: s.game.Snapshot(),
+		// Copied as the snapshot is read after the session goes on
+		Users:           maps.Clone(s.users),
+		DrawOffer:       s.drawOffer,
+		TakebackRequest: s.takebackRequest,
+	}
+	if s.engine != nil {
+		snap.Engine = &EngineSnapshot{Side: s.engineSide, Level: s.engine.Level()}
 	}
 	return snapshotResult{snapshot: snap}
 }
 
-func (s *GameSession) makeMove(userId uuid.UUID, move game.Move) error {
+func (s *GameSession) gamePGN(userId uuid.UUID) pgnResult {
+	if _, exists := s.users[userId]; !exists {
+		return pgnResult{"", fmt.Errorf("no permission to access game")}
+	}
+	var tags []game.PGNTag
+	for id, side := range s.users {
+		tags = append(tags, game.PGNTag{Name: pgnPlayerTag(side), Value: id.String()})
+	}
+	if s.engine != nil {
+		tags = append(tags, game.PGNTag{
+			Name:  pgnPlayerTag(s.engineSide),
+			Value: fmt.Sprintf("gochess engine level %d", s.engine.Level()),
+		})
+	}
+	return pgnResult{pgn: s.game.PGN(tags...)}
+}
+
+func (s *GameSession) makeMove(userId uuid.UUID, req MoveRequest) error {
 	if side, exists := s.users[userId]; !exists || side != s.game.MovingSide() {
 		return fmt.Errorf("user is not allowed to make this move")
 	}
-	return s.game.Move(move)
+	// Conflicting encodings are refused rather than one silently taking precedence. No move
+	// is ever from a square to itself, so zero coordinates are unset.
+	given := 0
+	for _, set := range []bool{req.Move != (game.Move{}), req.SAN != "", req.UCI != ""} {
+		if set {
+			given++
+		}
+	}
+	if given > 1 {
+		return fmt.Errorf("move must be given as only one of coordinates, SAN or UCI")
+	}
+	move := req.Move
+	switch {
+	case req.SAN != "":
+		var err error
+		if move, err = s.game.ParseSAN(req.SAN); err != nil {
+			return err
+		}
+	case req.UCI != "":
+		uciMove, err := game.ParseUCIMove(req.UCI)
+		if err != nil {
+			return err
+		}
+		move = *uciMove
+	}
+	if err := s.game.Move(move); err != nil {
+		return err
+	}
+	if err := s.moveMade(); err != nil {
+		return err
+	}
+	s.think()
+	return nil
 }
 
 func (s *GameSession) resign(userId uuid.UUID) error {
@@ -124,5 +495,197 @@ func (s *GameSession) resign(userId uuid.UUID) error {
 	if !exists {
 		return fmt.Errorf("user is not allowed to resign from this game")
 	}
-	return s.game.Resign(side)
+	if err := s.game.Resign(side); err != nil {
+		return err
+	}
+	event := s.newEvent(GameEvent_Resign)
+	event.Side = &side
+	return s.finishWith(&event)
+}
+
+// Stores and publishes the move just played, then the end of the game if it ended it
+func (s *GameSession) moveMade() error {
+	snap := s.game.Snapshot()
+	ply := len(snap.Moves) - 1
+	move := storedMove{
+		uci:       snap.UCIMoves[ply],
+		san:       snap.SANMoves[ply],
+		remaining: make(map[game.PieceColor]time.Duration),
+		playedAt:  s.game.TimeSource().Now(),
+	}
+	for side, ms := range snap.RemainingTime {
+		move.remaining[side] = time.Duration(ms) * time.Millisecond
+	}
+	if err := s.store.addMove(s.id, ply, move); err != nil {
+		return s.storeFailed(err)
+	}
+
+	event := s.moveEvent(snap, ply)
+	s.publish(event)
+	s.cancelDrawOffer()
+	s.moveCancelsTakeback()
+	if err := s.finish(); err != nil {
+		return err
+	}
+	s.armDeadlineTimer()
+	return nil
+}
+
+// Stores and publishes the end of the game once, if it has ended
+func (s *GameSession) finish() error {
+	return s.finishWith(nil)
+}
+
+// Publishes the event which ended the game, then stores and publishes the end of the game
+// once. Nothing is published if the end fails to be stored.
+func (s *GameSession) finishWith(cause *GameEvent) error {
+	result, ended := s.game.Result()
+	if !ended || s.finished {
+		if cause != nil {
+			s.publish(*cause)
+		}
+		return nil
+	}
+	if err := s.store.endGame(s.id, result, s.game.TimeSource().Now()); err != nil {
+		return s.storeFailed(err)
+	}
+	s.finished = true
+	s.drawOffer = nil
+	s.takebackRequest = nil
+	s.stopDeadlineTimer()
+	if cause != nil {
+		s.publish(*cause)
+	}
+	s.publish(s.newEvent(GameEvent_End))
+	return nil
+}
+
+// Stops the session once the store failed to record a change already made to the game,
+// so it isn't played on from a game the store doesn't have. Restarting brings the game back
+// as last stored.
+func (s *GameSession) storeFailed(err error) error {
+	log.Printf("Failed to store game %s, stopping its session: %v\n", s.id, err)
+	s.failed = fmt.Errorf("game is unavailable, failed to store it")
+	s.stopDeadlineTimer()
+	s.closeSubscribers()
+	return s.failed
+}
+
+// Arms the timer for the next deadline, flag fall, the end of the window to make a first
+// move or the end of a vacation, replacing any armed before
+func (s *GameSession) armDeadlineTimer() {
+	s.stopDeadlineTimer()
+	deadline, ok := s.game.TimeUntilFlag()
+	if ok {
+		if untilAbort, ok := s.timeUntilAbort(); ok {
+			deadline = min(deadline, untilAbort)
+		}
+	}
+	if _, untilReturn, away := s.game.TimeUntilVacationEnds(); away && s.game.InProgress() {
+		if !ok || untilReturn < deadline {
+			deadline = untilReturn
+		}
+		ok = true
+	}
+	if !ok {
+		return
+	}
+	s.deadlineTimer = time.AfterFunc(deadline, func() {
+		select {
+		case s.deadlines <- struct{}{}:
+		default:
+		}
+	})
+}
+
+func (s *GameSession) stopDeadlineTimer() {
+	if s.deadlineTimer != nil {
+		s.deadlineTimer.Stop()
+		s.deadlineTimer = nil
+	}
+}
+
+// Ends the game on time, aborts it or ends a vacation, unless the timer was stale. Failing
+// to store any of these stops the session, which then has no deadlines left.
+func (s *GameSession) deadlinePassed() {
+	if untilAbort, ok := s.timeUntilAbort(); ok && untilAbort <= 0 {
+		_ = s.abortGame(nil)
+	}
+	if side, untilReturn, away := s.game.TimeUntilVacationEnds(); away && untilReturn <= 0 && s.game.InProgress() && s.failed == nil {
+		_ = s.returnFromVacation(side)
+	}
+	if s.failed != nil || s.finish() != nil {
+		return
+	}
+	if !s.finished {
+		s.armDeadlineTimer()
+	}
+}
+
+// The game as first stored when the session is created
+func (s *GameSession) stored() storedGame {
+	stored := storedGame{
+		id:         s.id,
+		users:      s.users,
+		engineSide: s.engineSide,
+		control:    s.game.Control(),
+		drawPolicy: s.game.DrawPolicy(),
+		variant:    s.game.InitialState().Variant(),
+		initialFEN: s.game.InitialState().FEN(),
+		createdAt:  s.createdAt,
+	}
+	if s.engine != nil {
+		level := s.engine.Level()
+		stored.engineLevel = &level
+	}
+	if s.game.InProgress() {
+		startTime := s.game.StartTime()
+		stored.startedAt = &startTime
+	}
+	return stored
+}
+
+// Starts searching for the engine's reply if it is the engine's turn
+func (s *GameSession) think() {
+	if s.engine == nil || !s.game.InProgress() || s.game.MovingSide() != s.engineSide {
+		return
+	}
+	state, budget := s.game.State(), s.engine.Budget(s.game)
+	go func() {
+		result, err := s.engine.Search(state, budget)
+		reply := engineReply{numMoves: state.NumMoves(), hash: state.Hash(), err: err}
+		if err == nil {
+			reply.move = result.Move
+		}
+		select {
+		case s.engineReplies <- reply:
+		case <-s.done:
+		}
+	}()
+}
+
+func (s *GameSession) playEngineMove(reply engineReply) {
+	// The game may have ended, e.g. by resignation, or moves been taken back while the
+	// engine was thinking
+	state := s.game.State()
+	if !s.game.InProgress() || state.NumMoves() != reply.numMoves || state.Hash() != reply.hash {
+		return
+	}
+	if reply.err != nil {
+		log.Printf("Engine failed to find a move: %v\n", reply.err)
+		return
+	}
+	if err := s.game.Move(reply.move); err != nil {
+		log.Printf("Engine move %s rejected: %v\n", reply.move.UCI(), err)
+		return
+	}
+	// A failure to store the move has already stopped the session
+	_ = s.moveMade()
+}
+
+func pgnPlayerTag(side game.PieceColor) string {
+	if side == game.PieceColor_Black {
+		return "Black"
+	}
+	return "White"
 }
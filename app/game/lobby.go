@@ -0,0 +1,197 @@
+package game
+
+import (
+	"fmt"
+	"gochess/lib/game"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// A game was created and waits for an opponent
+	LobbyEvent_Seek = "seek"
+	// The game was joined, cancelled or expired, and is no longer open
+	LobbyEvent_SeekRemove = "seek_remove"
+)
+
+// How long a game waits for an opponent before it is aborted
+const seekExpiry = 24 * time.Hour
+
+// Seek is a game waiting for a second player to join
+type Seek struct {
+	Id      uuid.UUID `json:"id"`
+	Creator uuid.UUID `json:"creator"`
+	// Side the creator plays, the one joining taking the other
+	Side        game.PieceColor          `json:"side"`
+	TimeStages  []game.TimeStageSnapshot `json:"time_stages"`
+	DaysPerMove int                      `json:"days_per_move,omitempty"`
+	Variant     game.Variant             `json:"variant"`
+	DrawPolicy  game.DrawPolicy          `json:"draw_policy"`
+	CreatedAt   time.Time                `json:"created_at"`
+	ExpiresAt   time.Time                `json:"expires_at"`
+}
+
+type cancelSeekCommand struct {
+	userId uuid.UUID
+	ch     chan<- error
+}
+
+type expireSeekCommand struct {
+	ch chan<- error
+}
+
+type LobbyEvent struct {
+	Type string    `json:"type"`
+	Id   uuid.UUID `json:"id"`
+	// The seek opened, unset once removed
+	Seek *Seek `json:"seek,omitempty"`
+}
+
+// Lobby keeps the seeks open and streams them appearing and disappearing. Users aren't
+// shown their own seeks.
+type Lobby struct {
+	seeks map[uuid.UUID]Seek
+	// The user each subscriber streams the seeks of others for
+	subscribers map[chan LobbyEvent]uuid.UUID
+	mu          sync.Mutex
+}
+
+func NewLobby() *Lobby {
+	return &Lobby{
+		seeks:       make(map[uuid.UUID]Seek),
+		subscribers: make(map[chan LobbyEvent]uuid.UUID),
+	}
+}
+
+// Seeks returns the seeks open to the user, oldest first
+func (l *Lobby) Seeks(userId uuid.UUID) []Seek {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seeksFor(userId)
+}
+
+// Subscribe returns the changes to the seeks open to the user from now on, along with the
+// seeks open now. The channel is closed when the subscriber falls too far behind.
+func (l *Lobby) Subscribe(userId uuid.UUID) (<-chan LobbyEvent, []Seek) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := make(chan LobbyEvent, subscriberBufferSize)
+	l.subscribers[events] = userId
+	return events, l.seeksFor(userId)
+}
+
+func (l *Lobby) Unsubscribe(events <-chan LobbyEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.subscribers {
+		if ch == events {
+			delete(l.subscribers, ch)
+			close(ch)
+			return
+		}
+	}
+}
+
+func (l *Lobby) open(seek Seek) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seeks[seek.Id] = seek
+	l.publish(LobbyEvent{Type: LobbyEvent_Seek, Id: seek.Id, Seek: &seek}, seek.Creator)
+}
+
+// Removes the seek, if it is open
+func (l *Lobby) remove(id uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seek, ok := l.seeks[id]
+	if !ok {
+		return
+	}
+	delete(l.seeks, id)
+	l.publish(LobbyEvent{Type: LobbyEvent_SeekRemove, Id: id}, seek.Creator)
+}
+
+// Cancels the game before anyone joined, which only its creator may do. The game is
+// stored as aborted.
+func (s *GameSession) cancelSeek(userId uuid.UUID) error {
+	side, exists := s.users[userId]
+	if !exists {
+		return fmt.Errorf("user is not allowed to cancel this game")
+	}
+	return s.closeSeek(&side)
+}
+
+// Aborts the game once nobody has joined it in time
+func (s *GameSession) expireSeek() error {
+	if s.game.TimeSource().Now().Before(s.createdAt.Add(seekExpiry)) {
+		return fmt.Errorf("game has not expired")
+	}
+	return s.closeSeek(nil)
+}
+
+// Stores the game nobody joined as aborted, by the creator's side given or on expiry when
+// nil
+func (s *GameSession) closeSeek(side *game.PieceColor) error {
+	if len(s.users) != 1 || s.engine != nil || s.cancelled {
+		return fmt.Errorf("game is not open")
+	}
+	result := &game.ResultData{Result: game.GameResult_Aborted}
+	if err := s.store.endGame(s.id, result, s.game.TimeSource().Now()); err != nil {
+		return s.storeFailed(err)
+	}
+	s.cancelled = true
+	event := s.newEvent(GameEvent_Abort)
+	event.Side = side
+	event.Result = result
+	s.publish(event)
+	return nil
+}
+
+// Helpers
+
+func (l *Lobby) seeksFor(userId uuid.UUID) []Seek {
+	seeks := []Seek{}
+	for _, seek := range l.seeks {
+		if seek.Creator != userId {
+			seeks = append(seeks, seek)
+		}
+	}
+	slices.SortFunc(seeks, func(a, b Seek) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return seeks
+}
+
+// Sends the event to every subscriber but the creator of the seek
+func (l *Lobby) publish(event LobbyEvent, creator uuid.UUID) {
+	for ch, userId := range l.subscribers {
+		if userId == creator {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+			delete(l.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func newSeek(id uuid.UUID, users map[uuid.UUID]game.PieceColor, ctrl game.TimeControl, variant game.Variant, policy game.DrawPolicy, createdAt time.Time) Seek {
+	seek := Seek{
+		Id:          id,
+		TimeStages:  ctrl.StageSnapshots(),
+		DaysPerMove: ctrl.DaysPerMove,
+		Variant:     variant,
+		DrawPolicy:  policy,
+		CreatedAt:   createdAt,
+		ExpiresAt:   createdAt.Add(seekExpiry),
+	}
+	for userId, side := range users {
+		seek.Creator, seek.Side = userId, side
+	}
+	return seek
+}
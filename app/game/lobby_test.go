@@ -0,0 +1,131 @@
+package game
+
+import (
+	"gochess/lib/game"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCancelSeek(t *testing.T) {
+	creator, other := uuid.New(), uuid.New()
+	store := newFakeStore()
+	s, _ := newTestService(t, store)
+	id, err := s.NewGame(game.TimeControl_Hour, game.NewGameState(), game.DrawPolicy_Automatic, creator)
+	if err != nil {
+		t.Fatalf("NewGame() failed unexpectedly: %v", err)
+	}
+	lobby, seeks := s.SubscribeLobby(other)
+	defer s.UnsubscribeLobby(lobby)
+	if len(seeks) != 1 || seeks[0].Id != id {
+		t.Fatalf("seeks got %+v, want the game created", seeks)
+	}
+
+	if err := s.CancelSeek(id, other); err == nil {
+		t.Errorf("CancelSeek() by another user succeeded")
+	}
+	if err := s.CancelSeek(id, creator); err != nil {
+		t.Fatalf("CancelSeek() failed unexpectedly: %v", err)
+	}
+	if event := <-lobby; event.Type != LobbyEvent_SeekRemove || event.Id != id {
+		t.Errorf("lobby event got %+v, want the seek removed", event)
+	}
+	if seeks := s.OpenSeeks(other); len(seeks) != 0 {
+		t.Errorf("seeks got %+v after cancelling, want none", seeks)
+	}
+	if result := store.result(id); result == nil || result.Result != game.GameResult_Aborted {
+		t.Errorf("store got result %+v, want aborted", result)
+	}
+	if err := s.JoinGame(id, other); err == nil {
+		t.Errorf("JoinGame() succeeded after the seek was cancelled")
+	}
+}
+
+func TestCancelSeekAfterJoining(t *testing.T) {
+	creator, other := uuid.New(), uuid.New()
+	s, _ := newTestService(t, newFakeStore())
+	id, err := s.NewGame(game.TimeControl_Hour, game.NewGameState(), game.DrawPolicy_Automatic, creator)
+	if err != nil {
+		t.Fatalf("NewGame() failed unexpectedly: %v", err)
+	}
+	if err := s.JoinGame(id, other); err != nil {
+		t.Fatalf("JoinGame() failed unexpectedly: %v", err)
+	}
+	if err := s.CancelSeek(id, creator); err == nil {
+		t.Errorf("CancelSeek() succeeded after the game was joined")
+	}
+}
+
+func TestSeekExpires(t *testing.T) {
+	creator, other := uuid.New(), uuid.New()
+	store := newFakeStore()
+	s, source := newTestService(t, store)
+	id, err := s.NewGame(game.TimeControl_Hour, game.NewGameState(), game.DrawPolicy_Automatic, creator)
+	if err != nil {
+		t.Fatalf("NewGame() failed unexpectedly: %v", err)
+	}
+	if seeks := s.OpenSeeks(other); len(seeks) != 1 || !seeks[0].ExpiresAt.Equal(testStart.Add(seekExpiry)) {
+		t.Fatalf("seeks got %+v, want one expiring at %v", seeks, testStart.Add(seekExpiry))
+	}
+
+	source.Advance(seekExpiry - time.Second)
+	if err := s.expireSeek(id); err == nil {
+		t.Errorf("expireSeek() succeeded before the seek expired")
+	}
+	source.Advance(time.Second)
+	if err := s.expireSeek(id); err != nil {
+		t.Fatalf("expireSeek() failed unexpectedly: %v", err)
+	}
+	if seeks := s.OpenSeeks(other); len(seeks) != 0 {
+		t.Errorf("seeks got %+v after expiring, want none", seeks)
+	}
+	if result := store.result(id); result == nil || result.Result != game.GameResult_Aborted {
+		t.Errorf("store got result %+v, want aborted", result)
+	}
+}
+
+func TestRestoreReopensSeeks(t *testing.T) {
+	creator, other := uuid.New(), uuid.New()
+	store := newFakeStore()
+	live := openGame(creator, testStart.Add(-time.Hour))
+	expired := openGame(creator, testStart.Add(-seekExpiry))
+	cancelled := openGame(creator, testStart.Add(-time.Hour))
+	for _, g := range []*storedGame{&live, &expired, &cancelled} {
+		store.games[g.id] = g
+	}
+	store.results[cancelled.id] = &game.ResultData{Result: game.GameResult_Aborted}
+
+	s, err := restoreGameService(store, 0, game.NewFakeTimeSource(testStart))
+	if err != nil {
+		t.Fatalf("restoreGameService() failed unexpectedly: %v", err)
+	}
+	t.Cleanup(func() { closeSessions(s) })
+
+	if seeks := s.OpenSeeks(other); len(seeks) != 1 || seeks[0].Id != live.id {
+		t.Errorf("seeks got %+v, want only the live one", seeks)
+	}
+	if result := store.result(expired.id); result == nil || result.Result != game.GameResult_Aborted {
+		t.Errorf("store got result %+v for the expired seek, want aborted", result)
+	}
+	for _, id := range []uuid.UUID{expired.id, cancelled.id} {
+		if err := s.JoinGame(id, other); err == nil {
+			t.Errorf("JoinGame() succeeded for a seek no longer open")
+		}
+	}
+}
+
+// Helpers
+
+// A stored game created by the user, which nobody has joined
+func openGame(creator uuid.UUID, createdAt time.Time) storedGame {
+	return storedGame{
+		id:         uuid.New(),
+		users:      map[uuid.UUID]game.PieceColor{creator: game.PieceColor_White},
+		control:    game.TimeControl_Hour,
+		drawPolicy: game.DrawPolicy_Automatic,
+		variant:    game.Variant_Standard,
+		initialFEN: game.NewGameState().FEN(),
+		createdAt:  createdAt,
+	}
+}
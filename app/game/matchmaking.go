@@ -0,0 +1,264 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"gochess/lib/game"
+	"log"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Users aren't rated yet, so every player counts as this until they are. Until then rating
+// ranges don't decide who plays whom, only keeping a player whose range leaves this out
+// waiting until it widens to take it in.
+const DefaultRating = 1500
+
+const (
+	// How long a player waits in the pool before giving up on finding an opponent. The
+	// request is held open all the while, so proxies in front must wait longer, see
+	// ui/nginx.conf.
+	matchmakingTimeout = 2 * time.Minute
+	// Rating ranges widen by the step every interval a player waits, up to the most
+	ratingRangeStep     = 50
+	ratingRangeInterval = 10 * time.Second
+	maxRatingWidening   = 500
+	// Games looked back on to balance the colours each player gets
+	recentSideGames = 10
+)
+
+// Matchmaker pairs players waiting for an opponent with the same time control, variant and
+// draw policy, and starts their game
+type Matchmaker struct {
+	service *GameService
+	// Looks up each player's rating
+	rating func(uuid.UUID) int
+	// Players waiting, longest first
+	tickets []*ticket
+	mu      sync.Mutex
+}
+
+// RatingRange bounds the ratings a player accepts for their opponent
+type RatingRange struct {
+	Min *int
+	Max *int
+}
+
+// A player waiting in the pool
+type ticket struct {
+	userId      uuid.UUID
+	control     game.TimeControl
+	variant     game.Variant
+	policy      game.DrawPolicy
+	rating      int
+	ratingRange RatingRange
+	joinedAt    time.Time
+	// Receives the match once paired, or why the player left the pool
+	ch chan matchResult
+}
+
+type matchResult struct {
+	gameId uuid.UUID
+	side   game.PieceColor
+	err    error
+}
+
+// NewMatchmaker starts games for the service, looking up each player's rating with the
+// function given
+func NewMatchmaker(service *GameService, rating func(uuid.UUID) int) *Matchmaker {
+	return &Matchmaker{service: service, rating: rating}
+}
+
+// Enter puts the user in the pool for the game until they are paired, returning the game
+// started and their side. Giving up when the context ends, the user cancels or no opponent
+// is found in time takes them out of the pool.
+func (m *Matchmaker) Enter(ctx context.Context, userId uuid.UUID, ctrl game.TimeControl, variant game.Variant, policy game.DrawPolicy, ratingRange RatingRange) (uuid.UUID, game.PieceColor, error) {
+	if !ctrl.Validate() {
+		return uuid.Nil, 0, fmt.Errorf("game: invalid time control")
+	}
+	t := &ticket{
+		userId:      userId,
+		control:     ctrl,
+		variant:     variant,
+		policy:      policy,
+		rating:      m.rating(userId),
+		ratingRange: ratingRange,
+		joinedAt:    m.service.source.Now(),
+		ch:          make(chan matchResult, 1),
+	}
+	if err := m.add(t); err != nil {
+		return uuid.Nil, 0, err
+	}
+
+	timeout := time.NewTimer(matchmakingTimeout)
+	defer timeout.Stop()
+	// Ranges widen while waiting, which may bring opponents in range. The widening itself
+	// goes by the service's time source, the ticker only prompts pairing again.
+	widen := time.NewTicker(ratingRangeInterval)
+	defer widen.Stop()
+
+	m.pair()
+	for {
+		select {
+		case result := <-t.ch:
+			return result.gameId, result.side, result.err
+		case <-widen.C:
+			m.pair()
+			continue
+		case <-timeout.C:
+			m.remove(userId, fmt.Errorf("no opponent found"))
+		case <-ctx.Done():
+			m.remove(userId, ctx.Err())
+		}
+		// Unless the player was paired or cancelled meanwhile, removing them sent the reason
+		result := <-t.ch
+		return result.gameId, result.side, result.err
+	}
+}
+
+// Cancel takes the user out of the pool
+func (m *Matchmaker) Cancel(userId uuid.UUID) error {
+	if !m.remove(userId, fmt.Errorf("matchmaking cancelled")) {
+		return fmt.Errorf("user is not waiting for an opponent")
+	}
+	return nil
+}
+
+// Helpers
+
+func (m *Matchmaker) add(t *ticket) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, other := range m.tickets {
+		if other.userId == t.userId {
+			return fmt.Errorf("user is already waiting for an opponent")
+		}
+	}
+	m.tickets = append(m.tickets, t)
+	return nil
+}
+
+// Takes the user out of the pool, telling them why, unless they already left it
+func (m *Matchmaker) remove(userId uuid.UUID, err error) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, t := range m.tickets {
+		if t.userId == userId {
+			m.tickets = slices.Delete(m.tickets, i, i+1)
+			t.ch <- matchResult{err: err}
+			return true
+		}
+	}
+	return false
+}
+
+// Pairs off the players who accept each other, those waiting longest first, and starts
+// their games
+func (m *Matchmaker) pair() {
+	now := m.service.source.Now()
+	var pairs [][2]*ticket
+
+	m.mu.Lock()
+	for i := 0; i < len(m.tickets); i++ {
+		for j := i + 1; j < len(m.tickets); j++ {
+			a, b := m.tickets[i], m.tickets[j]
+			if !a.sameGame(b) || !a.accepts(b.rating, now) || !b.accepts(a.rating, now) {
+				continue
+			}
+			pairs = append(pairs, [2]*ticket{a, b})
+			m.tickets = slices.Delete(m.tickets, j, j+1)
+			m.tickets = slices.Delete(m.tickets, i, i+1)
+			i--
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	for _, pair := range pairs {
+		m.start(pair[0], pair[1])
+	}
+}
+
+// Starts the game between the players, giving white to whoever is due it
+func (m *Matchmaker) start(a *ticket, b *ticket) {
+	white, black := a, b
+	if !m.dueWhite(a.userId, b.userId) {
+		white, black = b, a
+	}
+	gameId, err := m.startGame(white, black)
+	if err != nil {
+		log.Printf("Failed to start matched game: %v\n", err)
+	}
+	white.ch <- matchResult{gameId: gameId, side: game.PieceColor_White, err: err}
+	black.ch <- matchResult{gameId: gameId, side: game.PieceColor_Black, err: err}
+}
+
+// Chess960 games start from a random position, the same for both players
+func (m *Matchmaker) startGame(white *ticket, black *ticket) (uuid.UUID, error) {
+	initial := game.NewGameState()
+	if white.variant == game.Variant_Chess960 {
+		var err error
+		if initial, err = game.NewChess960GameState(game.RandomChess960Index()); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	return m.service.NewMatchedGame(white.control, initial, white.policy, white.userId, black.userId)
+}
+
+// Whether the first player should have white over the second: the one who has had black
+// more often in their recent games, else the one who had black last, else either
+func (m *Matchmaker) dueWhite(a uuid.UUID, b uuid.UUID) bool {
+	aSides, err := m.service.store.recentSides(a, recentSideGames)
+	if err != nil {
+		log.Printf("Failed to balance colours: %v\n", err)
+	}
+	bSides, err := m.service.store.recentSides(b, recentSideGames)
+	if err != nil {
+		log.Printf("Failed to balance colours: %v\n", err)
+	}
+
+	if aWhites, bWhites := whiteBalance(aSides), whiteBalance(bSides); aWhites != bWhites {
+		return aWhites < bWhites
+	}
+	aBlackLast := len(aSides) > 0 && aSides[0] == game.PieceColor_Black
+	bBlackLast := len(bSides) > 0 && bSides[0] == game.PieceColor_Black
+	if aBlackLast != bBlackLast {
+		return aBlackLast
+	}
+	return rand.Intn(2) == 0
+}
+
+// Whether the players want the same game, and so may be paired
+func (t *ticket) sameGame(other *ticket) bool {
+	return t.control.Equal(other.control) && t.variant == other.variant && t.policy == other.policy
+}
+
+// Whether the player accepts an opponent of the rating, their range having widened with
+// the time they have waited
+func (t *ticket) accepts(rating int, now time.Time) bool {
+	widening := min(int(now.Sub(t.joinedAt)/ratingRangeInterval)*ratingRangeStep, maxRatingWidening)
+	if t.ratingRange.Min != nil && rating < *t.ratingRange.Min-widening {
+		return false
+	}
+	if t.ratingRange.Max != nil && rating > *t.ratingRange.Max+widening {
+		return false
+	}
+	return true
+}
+
+// Games played as white less those played as black
+func whiteBalance(sides []game.PieceColor) int {
+	balance := 0
+	for _, side := range sides {
+		if side == game.PieceColor_White {
+			balance++
+		} else {
+			balance--
+		}
+	}
+	return balance
+}
@@ -0,0 +1,344 @@
+package game
+
+import (
+	"context"
+	"gochess/lib/game"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestTicketAccepts(t *testing.T) {
+	tests := map[string]struct {
+		ratingRange RatingRange
+		waited      time.Duration
+		rating      int
+		want        bool
+	}{
+		"Unbounded":                  {rating: 3000, want: true},
+		"Within the range":           {ratingRange: RatingRange{Min: intPtr(1400), Max: intPtr(1600)}, rating: 1600, want: true},
+		"Below the range":            {ratingRange: RatingRange{Min: intPtr(1400)}, rating: 1399, want: false},
+		"Above the range":            {ratingRange: RatingRange{Max: intPtr(1600)}, rating: 1601, want: false},
+		"Not yet widened":            {ratingRange: RatingRange{Max: intPtr(1600)}, waited: ratingRangeInterval - time.Second, rating: 1601, want: false},
+		"Widened once":               {ratingRange: RatingRange{Max: intPtr(1600)}, waited: ratingRangeInterval, rating: 1650, want: true},
+		"Widened once, still beyond": {ratingRange: RatingRange{Max: intPtr(1600)}, waited: ratingRangeInterval, rating: 1651, want: false},
+		"Widened down":               {ratingRange: RatingRange{Min: intPtr(1400)}, waited: 3 * ratingRangeInterval, rating: 1250, want: true},
+		"Widened the most":           {ratingRange: RatingRange{Max: intPtr(1600)}, waited: time.Hour, rating: 1600 + maxRatingWidening, want: true},
+		"Beyond the most widening":   {ratingRange: RatingRange{Max: intPtr(1600)}, waited: time.Hour, rating: 1601 + maxRatingWidening, want: false},
+	}
+	for name, test := range tests {
+		tk := ticket{ratingRange: test.ratingRange, joinedAt: testStart}
+		if got := tk.accepts(test.rating, testStart.Add(test.waited)); got != test.want {
+			t.Errorf("%s: accepts(%d) got %t, want %t", name, test.rating, got, test.want)
+		}
+	}
+}
+
+func TestWhiteBalance(t *testing.T) {
+	w, b := game.PieceColor_White, game.PieceColor_Black
+	tests := map[string]struct {
+		sides []game.PieceColor
+		want  int
+	}{
+		"No games":   {sides: nil, want: 0},
+		"Even":       {sides: []game.PieceColor{w, b, b, w}, want: 0},
+		"More white": {sides: []game.PieceColor{w, w, b}, want: 1},
+		"More black": {sides: []game.PieceColor{b, b, b}, want: -3},
+	}
+	for name, test := range tests {
+		if got := whiteBalance(test.sides); got != test.want {
+			t.Errorf("%s: whiteBalance() got %d, want %d", name, got, test.want)
+		}
+	}
+}
+
+func TestDueWhite(t *testing.T) {
+	w, b := game.PieceColor_White, game.PieceColor_Black
+	tests := map[string]struct {
+		// Sides the players had in their recent games, most recent first
+		a, b []game.PieceColor
+		want bool
+	}{
+		"Fewer whites":           {a: []game.PieceColor{b, w, b}, b: []game.PieceColor{w}, want: true},
+		"More whites":            {a: []game.PieceColor{w, w}, b: nil, want: false},
+		"Even, black last":       {a: []game.PieceColor{b, w}, b: []game.PieceColor{w, b}, want: true},
+		"Even, white last":       {a: []game.PieceColor{w, b}, b: []game.PieceColor{b, w}, want: false},
+		"New against black last": {a: nil, b: []game.PieceColor{b, w}, want: false},
+	}
+	for name, test := range tests {
+		a, b := uuid.New(), uuid.New()
+		store := newFakeStore()
+		store.sides[a], store.sides[b] = test.a, test.b
+		s, _ := newTestService(t, store)
+		if got := s.matchmaker.dueWhite(a, b); got != test.want {
+			t.Errorf("%s: dueWhite() got %t, want %t", name, got, test.want)
+		}
+	}
+}
+
+func TestPair(t *testing.T) {
+	blitz := game.TimeControl_ThreeTwo
+	standard, chess960 := game.Variant_Standard, game.Variant_Chess960
+	automatic, claimable := game.DrawPolicy_Automatic, game.DrawPolicy_Claimable
+	tests := map[string]struct {
+		tickets []ticket
+		// Indexes of the tickets paired with each other, the rest left waiting
+		want [][2]int
+	}{
+		"Same game": {
+			tickets: []ticket{
+				{control: blitz, variant: standard, policy: automatic},
+				{control: blitz, variant: standard, policy: automatic},
+			},
+			want: [][2]int{{0, 1}},
+		},
+		"Different time controls": {
+			tickets: []ticket{
+				{control: blitz, variant: standard, policy: automatic},
+				{control: game.TimeControl_Hour, variant: standard, policy: automatic},
+			},
+		},
+		"Different variants": {
+			tickets: []ticket{
+				{control: blitz, variant: standard, policy: automatic},
+				{control: blitz, variant: chess960, policy: automatic},
+			},
+		},
+		"Different draw policies": {
+			tickets: []ticket{
+				{control: blitz, variant: standard, policy: automatic},
+				{control: blitz, variant: standard, policy: claimable},
+			},
+		},
+		"Chess960": {
+			tickets: []ticket{
+				{control: blitz, variant: chess960, policy: claimable},
+				{control: blitz, variant: chess960, policy: claimable},
+			},
+			want: [][2]int{{0, 1}},
+		},
+		"Out of range": {
+			tickets: []ticket{
+				{control: blitz, rating: 1500, ratingRange: RatingRange{Max: intPtr(1700)}},
+				{control: blitz, rating: 1800},
+			},
+		},
+		"Longest waiting first": {
+			tickets: []ticket{
+				{control: blitz},
+				{control: blitz},
+				{control: blitz},
+			},
+			want: [][2]int{{0, 1}},
+		},
+		"Range passed over": {
+			tickets: []ticket{
+				{control: blitz, rating: 1500, ratingRange: RatingRange{Min: intPtr(1600)}},
+				{control: blitz, rating: 1500},
+				{control: blitz, rating: 1600},
+				{control: blitz, rating: 1600},
+			},
+			want: [][2]int{{0, 2}, {1, 3}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := newFakeStore()
+			s, source := newTestService(t, store)
+			tickets := make([]*ticket, len(test.tickets))
+			for i := range test.tickets {
+				tk := test.tickets[i]
+				tk.userId = uuid.New()
+				tk.joinedAt = source.Now()
+				tk.ch = make(chan matchResult, 1)
+				tickets[i] = &tk
+				if err := s.matchmaker.add(&tk); err != nil {
+					t.Fatalf("add() failed unexpectedly: %v", err)
+				}
+			}
+			s.matchmaker.pair()
+
+			paired := make(map[int]bool)
+			for _, pair := range test.want {
+				a, b := tickets[pair[0]], tickets[pair[1]]
+				resultA, resultB := <-a.ch, <-b.ch
+				if resultA.err != nil || resultB.err != nil {
+					t.Fatalf("pair %v got errors %v and %v", pair, resultA.err, resultB.err)
+				}
+				if resultA.gameId != resultB.gameId || resultA.side == resultB.side {
+					t.Errorf("pair %v got %+v and %+v, want opposite sides of one game", pair, resultA, resultB)
+				}
+				stored := store.game(resultA.gameId)
+				if !stored.control.Equal(a.control) || stored.variant != a.variant || stored.drawPolicy != a.policy {
+					t.Errorf("pair %v started %v %v %v, want %v %v %v", pair, stored.control, stored.variant, stored.drawPolicy, a.control, a.variant, a.policy)
+				}
+				paired[pair[0]], paired[pair[1]] = true, true
+			}
+			for i, tk := range tickets {
+				if paired[i] {
+					continue
+				}
+				select {
+				case result := <-tk.ch:
+					t.Errorf("ticket %d got %+v, want it left waiting", i, result)
+				default:
+				}
+			}
+			s.matchmaker.mu.Lock()
+			defer s.matchmaker.mu.Unlock()
+			if got, want := len(s.matchmaker.tickets), len(tickets)-len(paired); got != want {
+				t.Errorf("pool got %d players waiting, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestMatchmakingWidensRange(t *testing.T) {
+	low, high := uuid.New(), uuid.New()
+	ratings := map[uuid.UUID]int{low: 1500, high: 1700}
+	s, source := newTestService(t, newFakeStore())
+	s.matchmaker = NewMatchmaker(s, func(userId uuid.UUID) int { return ratings[userId] })
+
+	results := make(chan matchResult, 2)
+	for _, user := range []uuid.UUID{low, high} {
+		go func() {
+			// The lower rated player only accepts up to 100 above them at first
+			gameId, side, err := s.matchmaker.Enter(context.Background(), user, game.TimeControl_ThreeTwo, game.Variant_Standard, game.DrawPolicy_Automatic,
+				RatingRange{Max: intPtr(ratings[user] + 100)})
+			results <- matchResult{gameId, side, err}
+		}()
+	}
+	waitForTickets(t, s.matchmaker, 2)
+
+	// Pairing again once the range has widened by less than the difference leaves both waiting
+	source.Advance(ratingRangeInterval)
+	s.matchmaker.pair()
+	select {
+	case result := <-results:
+		t.Fatalf("Matchmake() got %+v before the range widened enough", result)
+	default:
+	}
+
+	source.Advance(ratingRangeInterval)
+	s.matchmaker.pair()
+	a, b := <-results, <-results
+	if a.err != nil || b.err != nil {
+		t.Fatalf("Matchmake() failed unexpectedly: %v, %v", a.err, b.err)
+	}
+	if a.gameId != b.gameId {
+		t.Errorf("Matchmake() got games %s and %s, want the same", a.gameId, b.gameId)
+	}
+}
+
+func TestCancelMatchmaking(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		user := uuid.New()
+		s := newGameService(newFakeStore(), 0, game.SystemTime)
+		errs := make(chan error, 1)
+		go func() {
+			_, _, err := s.Matchmake(context.Background(), user, game.TimeControl_ThreeTwo, game.Variant_Standard, game.DrawPolicy_Automatic, RatingRange{})
+			errs <- err
+		}()
+		synctest.Wait()
+
+		if err := s.CancelMatchmaking(user); err != nil {
+			t.Fatalf("CancelMatchmaking() failed unexpectedly: %v", err)
+		}
+		if err := <-errs; err == nil {
+			t.Errorf("Matchmake() succeeded after cancelling")
+		}
+		if err := s.CancelMatchmaking(user); err == nil {
+			t.Errorf("CancelMatchmaking() succeeded once out of the pool")
+		}
+	})
+}
+
+func TestCancelMatchmakingAfterPairing(t *testing.T) {
+	first, second := uuid.New(), uuid.New()
+	s, _ := newTestService(t, newFakeStore())
+	results := make(chan matchResult, 2)
+	for _, user := range []uuid.UUID{first, second} {
+		go func() {
+			gameId, side, err := s.Matchmake(context.Background(), user, game.TimeControl_ThreeTwo, game.Variant_Standard, game.DrawPolicy_Automatic, RatingRange{})
+			results <- matchResult{gameId, side, err}
+		}()
+	}
+	a, b := <-results, <-results
+	if a.err != nil || b.err != nil {
+		t.Fatalf("Matchmake() failed unexpectedly: %v, %v", a.err, b.err)
+	}
+	if a.gameId != b.gameId {
+		t.Errorf("Matchmake() got games %s and %s, want the same", a.gameId, b.gameId)
+	}
+
+	// The game has already started
+	if err := s.CancelMatchmaking(first); err == nil {
+		t.Errorf("CancelMatchmaking() succeeded after pairing")
+	}
+	if _, err := s.SessionSnapshot(a.gameId, first); err != nil {
+		t.Errorf("SessionSnapshot() failed unexpectedly: %v", err)
+	}
+}
+
+func TestMatchmakingGivesUp(t *testing.T) {
+	tests := map[string]struct {
+		// How long until the context ends, never when zero
+		deadline time.Duration
+		want     time.Duration
+	}{
+		"No opponent in time": {want: matchmakingTimeout},
+		"Context ends":        {deadline: time.Minute, want: time.Minute},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			synctest.Test(t, func(t *testing.T) {
+				s := newGameService(newFakeStore(), 0, game.SystemTime)
+				ctx := context.Background()
+				if test.deadline > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, test.deadline)
+					defer cancel()
+				}
+				start := time.Now()
+				_, _, err := s.Matchmake(ctx, uuid.New(), game.TimeControl_ThreeTwo, game.Variant_Standard, game.DrawPolicy_Automatic, RatingRange{})
+				if err == nil {
+					t.Fatalf("Matchmake() succeeded without an opponent")
+				}
+				if got := time.Since(start); got != test.want {
+					t.Errorf("Matchmake() gave up after %v, want %v", got, test.want)
+				}
+				s.matchmaker.mu.Lock()
+				defer s.matchmaker.mu.Unlock()
+				if got := len(s.matchmaker.tickets); got != 0 {
+					t.Errorf("pool got %d players waiting, want none", got)
+				}
+			})
+		})
+	}
+}
+
+// Helpers
+
+// Waits until the players have entered the pool
+func waitForTickets(t *testing.T, m *Matchmaker, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mu.Lock()
+		waiting := len(m.tickets)
+		m.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pool got %d players waiting, want %d", waiting, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -1,14 +1,123 @@
 package game
 
 import (
+	"gochess/lib/game"
+
 	"github.com/google/uuid"
 )
 
+const (
+	Opponent_Human  = "human"
+	Opponent_Engine = "engine"
+)
+
+// Games listed in the lobby, which for now are only those waiting for an opponent
+const GameStatus_Open = "open"
+
+const (
+	SocketCommand_Move        = "move"
+	SocketCommand_Resign      = "resign"
+	SocketCommand_Abort       = "abort"
+	SocketCommand_OfferDraw   = "offer_draw"
+	SocketCommand_AcceptDraw  = "accept_draw"
+	SocketCommand_DeclineDraw = "decline_draw"
+	SocketCommand_ClaimDraw   = "claim_draw"
+
+	SocketCommand_RequestTakeback = "request_takeback"
+	SocketCommand_AcceptTakeback  = "accept_takeback"
+	SocketCommand_DeclineTakeback = "decline_takeback"
+
+	SocketCommand_StartVacation = "start_vacation"
+	SocketCommand_EndVacation   = "end_vacation"
+
+	SocketReply_Type = "reply"
+)
+
 type StartGameRequest struct {
+	TimeControlRequest
+	// Either another user joining later, the default, or the built-in engine
+	Opponent string `json:"opponent,omitempty"`
+	// Strength of the engine opponent
+	Level int `json:"level,omitempty"`
+	// Either standard, the default, or chess960
+	Variant string `json:"variant,omitempty"`
+	// Chess960 starting position, random when omitted
+	Chess960Index *int `json:"chess960_index,omitempty"`
+	// Either automatic, the default, where threefold repetition and the 50-move rule end
+	// the game, or claimable, where the player on move has to claim those draws
+	DrawPolicy string `json:"draw_policy,omitempty"`
+}
+
+// The time control of a game, shared by the requests starting one
+type TimeControlRequest struct {
 	DurationMillis  int64 `json:"duration_millis"`
 	IncrementMillis int64 `json:"increment_millis"`
+	// Delay on each move instead of an increment, either simple or bronstein
+	DelayMillis int64  `json:"delay_millis,omitempty"`
+	DelayMode   string `json:"delay_mode,omitempty"`
+	// Moves in the first stage when later stages follow, e.g. 40 moves in 90 minutes
+	Moves  int                `json:"moves,omitempty"`
+	Stages []TimeStageRequest `json:"stages,omitempty"`
+	// Days for every move of a correspondence game, in place of the times above, and the
+	// days of vacation each side may take
+	DaysPerMove  int `json:"days_per_move,omitempty"`
+	VacationDays int `json:"vacation_days,omitempty"`
+}
+
+// A stage of the time control after the first, the last one lasting the rest of the game
+type TimeStageRequest struct {
+	Moves           int    `json:"moves,omitempty"`
+	DurationMillis  int64  `json:"duration_millis"`
+	IncrementMillis int64  `json:"increment_millis,omitempty"`
+	DelayMillis     int64  `json:"delay_millis,omitempty"`
+	DelayMode       string `json:"delay_mode,omitempty"`
+}
+
+type MatchmakingRequest struct {
+	TimeControlRequest
+	// Players are only paired for the same variant and draw policy, as when starting a game.
+	// Chess960 games start from a random position.
+	Variant    string `json:"variant,omitempty"`
+	DrawPolicy string `json:"draw_policy,omitempty"`
+	// Ratings accepted for the opponent, unbounded when omitted. The range widens the
+	// longer no opponent is found. Users aren't rated yet, so every opponent is taken to
+	// have the default rating.
+	RatingMin *int `json:"rating_min,omitempty"`
+	RatingMax *int `json:"rating_max,omitempty"`
+}
+
+// The game matchmaking paired the user into, and the side they play
+type MatchmakingResponse struct {
+	Id   uuid.UUID       `json:"id"`
+	Side game.PieceColor `json:"side"`
+}
+
+type ListGamesResponse struct {
+	Games []Seek `json:"games"`
 }
 
 type StartGameResponse struct {
 	Id uuid.UUID `json:"id"`
 }
+
+// One of the coordinates of the move, its Standard Algebraic Notation or UCI notation
+type MoveRequest struct {
+	game.Move
+	SAN string `json:"san,omitempty"`
+	UCI string `json:"uci,omitempty"`
+}
+
+// Command sent by the client over the game's WebSocket
+type SocketCommand struct {
+	Type string `json:"type"`
+	// Echoed back in the reply so the client can match it to the command
+	Id   string       `json:"id,omitempty"`
+	Move *MoveRequest `json:"move,omitempty"`
+}
+
+// Sent back for every command, with the error if it failed
+type SocketReply struct {
+	Type  string `json:"type"`
+	Id    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
@@ -4,12 +4,30 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
-func RegisterRoutes(r chi.Router) {
-	c := NewController()
+func RegisterRoutes(r chi.Router, service *GameService) {
+	c := NewController(service)
 
+	r.Get("/games", c.listGamesHandler)
+	r.Get("/games/events", c.lobbyEventsHandler)
 	r.Post("/game/start", c.startGameHandler)
+	r.Post("/matchmaking", c.matchmakingHandler)
+	r.Post("/matchmaking/cancel", c.cancelMatchmakingHandler)
 	r.Post("/game/{id}/join", c.joinGameHandler)
 	r.Get("/game/{id}", c.gameSnapshotHandler)
+	r.Get("/game/{id}/pgn", c.gamePGNHandler)
 	r.Post("/game/{id}/move", c.gameMoveHandler)
 	r.Post("/game/{id}/resign", c.gameResignHandler)
+	r.Post("/game/{id}/abort", c.gameAbortHandler)
+	r.Post("/game/{id}/cancel", c.gameCancelHandler)
+	r.Post("/game/{id}/draw/offer", c.gameOfferDrawHandler)
+	r.Post("/game/{id}/draw/accept", c.gameAcceptDrawHandler)
+	r.Post("/game/{id}/draw/decline", c.gameDeclineDrawHandler)
+	r.Post("/game/{id}/draw/claim", c.gameClaimDrawHandler)
+	r.Post("/game/{id}/takeback/request", c.gameRequestTakebackHandler)
+	r.Post("/game/{id}/takeback/accept", c.gameAcceptTakebackHandler)
+	r.Post("/game/{id}/takeback/decline", c.gameDeclineTakebackHandler)
+	r.Post("/game/{id}/vacation/start", c.gameStartVacationHandler)
+	r.Post("/game/{id}/vacation/end", c.gameEndVacationHandler)
+	r.Get("/game/{id}/socket", c.gameSocketHandler)
+	r.Get("/game/{id}/events", c.gameEventsHandler)
 }
@@ -0,0 +1,170 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	socketWriteTimeout = 10 * time.Second
+	// Pings keep the connection open through proxies timing out idle connections
+	socketPingInterval = 30 * time.Second
+	socketPongTimeout  = socketPingInterval * 2
+	socketMaxMessage   = 4096
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// Streams the game's events to the client and plays the moves and resignations it sends
+// back. Only this goroutine writes to the connection.
+func (c *Controller) serveSocket(conn *websocket.Conn, gameId uuid.UUID, userId uuid.UUID, events <-chan GameEvent) {
+	defer conn.Close()
+	defer c.service.Unsubscribe(gameId, events)
+
+	replies := make(chan SocketReply)
+	done, stop := make(chan struct{}), make(chan struct{})
+	defer close(stop)
+	go c.readSocket(conn, gameId, userId, replies, done, stop)
+
+	ping := time.NewTicker(socketPingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case event, ok := <-events:
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, "game closed"),
+					time.Now().Add(socketWriteTimeout))
+				return
+			}
+			err = writeSocketJSON(conn, event)
+		case reply := <-replies:
+			err = writeSocketJSON(conn, reply)
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteTimeout))
+		case <-done:
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to write to socket for game %s: %v\n", gameId, err)
+			return
+		}
+	}
+}
+
+func (c *Controller) readSocket(conn *websocket.Conn, gameId uuid.UUID, userId uuid.UUID, replies chan<- SocketReply, done chan<- struct{}, stop <-chan struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(socketMaxMessage)
+	_ = conn.SetReadDeadline(time.Now().Add(socketPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(socketPongTimeout))
+	})
+
+	for {
+		var cmd SocketCommand
+		if err := conn.ReadJSON(&cmd); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("Failed to read from socket for game %s: %v\n", gameId, err)
+			}
+			return
+		}
+
+		reply := SocketReply{Type: SocketReply_Type, Id: cmd.Id}
+		if err := c.runSocketCommand(gameId, userId, cmd); err != nil {
+			reply.Error = err.Error()
+		}
+		select {
+		case replies <- reply:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (c *Controller) runSocketCommand(gameId uuid.UUID, userId uuid.UUID, cmd SocketCommand) error {
+	switch cmd.Type {
+	case SocketCommand_Move:
+		if cmd.Move == nil {
+			return fmt.Errorf("move command is missing the move")
+		}
+		if err := c.service.MakeMove(gameId, userId, *cmd.Move); err != nil {
+			return err
+		}
+		log.Printf("User %s made move %v in game %s\n", userId, *cmd.Move, gameId)
+	case SocketCommand_Resign:
+		if err := c.service.Resign(gameId, userId); err != nil {
+			return err
+		}
+		log.Printf("User %s resigned from in game %s\n", userId, gameId)
+	case SocketCommand_Abort:
+		if err := c.service.Abort(gameId, userId); err != nil {
+			return err
+		}
+		log.Printf("User %s aborted game %s\n", userId, gameId)
+	case SocketCommand_OfferDraw:
+		if err := c.service.OfferDraw(gameId, userId); err != nil {
+			return err
+		}
+		log.Printf("User %s offered a draw in game %s\n", userId, gameId)
+	case SocketCommand_AcceptDraw:
+		if err := c.service.AcceptDraw(gameId, userId); err != nil {
+			return err
+		}
+		log.Printf("User %s accepted a draw in game %s\n", userId, gameId)
+	case SocketCommand_DeclineDraw:
+		if err := c.service.DeclineDraw(gameId, userId); err != nil {
+			return err
+		}
+		log.Printf("User %s declined a draw in game %s\n", userId, gameId)
+	case SocketCommand_ClaimDraw:
+		if err := c.service.ClaimDraw(gameId, userId); err != nil {
+			return err
+		}
+		log.Printf("User %s claimed a draw in game %s\n", userId, gameId)
+	case SocketCommand_RequestTakeback:
+		if err := c.service.RequestTakeback(gameId, userId); err != nil {
+			return err
+		}
+		log.Printf("User %s requested a takeback in game %s\n", userId, gameId)
+	case SocketCommand_AcceptTakeback:
+		if err := c.service.AcceptTakeback(gameId, userId); err != nil {
+			return err
+		}
+		log.Printf("User %s accepted a takeback in game %s\n", userId, gameId)
+	case SocketCommand_DeclineTakeback:
+		if err := c.service.DeclineTakeback(gameId, userId); err != nil {
+			return err
+		}
+		log.Printf("User %s declined a takeback in game %s\n", userId, gameId)
+	case SocketCommand_StartVacation:
+		if err := c.service.StartVacation(gameId, userId); err != nil {
+			return err
+		}
+		log.Printf("User %s went on vacation from game %s\n", userId, gameId)
+	case SocketCommand_EndVacation:
+		if err := c.service.EndVacation(gameId, userId); err != nil {
+			return err
+		}
+		log.Printf("User %s returned from vacation in game %s\n", userId, gameId)
+	default:
+		return fmt.Errorf("unknown command %q", cmd.Type)
+	}
+	return nil
+}
+
+func writeSocketJSON(conn *websocket.Conn, v any) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout)); err != nil {
+		return err
+	}
+	return conn.WriteJSON(v)
+}
@@ -0,0 +1,144 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Comments keep the stream open through proxies timing out idle connections
+const sseKeepAliveInterval = 30 * time.Second
+
+// Parses the Last-Event-ID header sent by reconnecting clients, the id of the last event
+// they saw
+func lastEventId(r *http.Request) (int64, bool, error) {
+	header := r.Header.Get("Last-Event-ID")
+	if header == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseInt(header, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false, fmt.Errorf("invalid Last-Event-ID %q", header)
+	}
+	return id, true, nil
+}
+
+// Writes the events as a Server-Sent Events stream until the client goes away or the
+// subscription is closed
+func (c *Controller) serveEvents(w http.ResponseWriter, r *http.Request, gameId uuid.UUID, events <-chan GameEvent, missed []GameEvent) {
+	defer c.service.Unsubscribe(gameId, events)
+
+	flusher, ok := startEventStream(w)
+	if !ok {
+		return
+	}
+
+	for _, event := range missed {
+		if err := writeSSE(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		var err error
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			err = writeSSE(w, event)
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// Writes the seeks open as events, then their changes until the client goes away or the
+// subscription is closed
+func (c *Controller) serveLobbyEvents(w http.ResponseWriter, r *http.Request, events <-chan LobbyEvent, seeks []Seek) {
+	defer c.service.UnsubscribeLobby(events)
+
+	flusher, ok := startEventStream(w)
+	if !ok {
+		return
+	}
+
+	for _, seek := range seeks {
+		if err := writeLobbySSE(w, LobbyEvent{Type: LobbyEvent_Seek, Id: seek.Id, Seek: &seek}); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		var err error
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			err = writeLobbySSE(w, event)
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// Replies with the headers of an event stream, unless the response can't be streamed
+func startEventStream(w http.ResponseWriter) (http.Flusher, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stops nginx buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	return flusher, true
+}
+
+func writeSSE(w http.ResponseWriter, event GameEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data)
+	return err
+}
+
+// Lobby events carry no ID, as a reconnecting client is sent the seeks open anew
+func writeLobbySSE(w http.ResponseWriter, event LobbyEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
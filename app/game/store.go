@@ -0,0 +1,386 @@
+package game
+
+import (
+	"database/sql"
+	"fmt"
+	"gochess/lib/game"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GameStore persists games and their moves so sessions survive a restart
+type GameStore struct {
+	db *sql.DB
+}
+
+// What the service and its sessions keep their games in, a GameStore outside of tests
+type gameStore interface {
+	createGame(g storedGame) error
+	startGame(id uuid.UUID, userId uuid.UUID, side game.PieceColor, startedAt time.Time) error
+	addMove(id uuid.UUID, ply int, move storedMove) error
+	takeBack(id uuid.UUID, numMoves int) error
+	startVacation(id uuid.UUID, side game.PieceColor, startedAt time.Time) error
+	endVacation(id uuid.UUID, side game.PieceColor, endedAt time.Time) error
+	endGame(id uuid.UUID, result *game.ResultData, endedAt time.Time) error
+	activeGames() ([]storedGame, error)
+	recentSides(userId uuid.UUID, limit int) ([]game.PieceColor, error)
+}
+
+// A game as stored, enough to restore its session
+type storedGame struct {
+	id          uuid.UUID
+	users       map[uuid.UUID]game.PieceColor
+	engineLevel *int
+	engineSide  game.PieceColor
+	control     game.TimeControl
+	drawPolicy  game.DrawPolicy
+	variant     game.Variant
+	initialFEN  string
+	createdAt   time.Time
+	startedAt   *time.Time
+	moves       []storedMove
+	vacations   []game.Vacation
+}
+
+type storedMove struct {
+	uci       string
+	san       string
+	remaining map[game.PieceColor]time.Duration
+	playedAt  time.Time
+}
+
+func NewGameStore(db *sql.DB) *GameStore {
+	return &GameStore{db: db}
+}
+
+func (s *GameStore) createGame(g storedGame) error {
+	white, black := sideUserIds(g.users)
+	var engineSide *game.PieceColor
+	if g.engineLevel != nil {
+		engineSide = &g.engineSide
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("store: failed to create game %s: %v", g.id, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO game (id, white_id, black_id, engine_level, engine_side, duration_ms,
+			increment_ms, delay_ms, delay_mode, stage_moves, days_per_move, vacation_days,
+			draw_policy, variant, initial_fen, created_at, started_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
+		g.id, white, black, g.engineLevel, engineSide, g.control.Total.Milliseconds(),
+		g.control.Increment.Milliseconds(), g.control.Delay.Milliseconds(), g.control.DelayMode.String(),
+		g.control.Moves, g.control.DaysPerMove, g.control.VacationDays, g.drawPolicy.String(),
+		g.variant.String(), g.initialFEN, g.createdAt, g.startedAt)
+	if err != nil {
+		return fmt.Errorf("store: failed to create game %s: %v", g.id, err)
+	}
+	for i, stage := range g.control.Later {
+		_, err := tx.Exec(`
+			INSERT INTO game_time_stage (game_id, stage, moves, duration_ms, increment_ms, delay_ms,
+				delay_mode)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			g.id, i+1, stage.Moves, stage.Time.Milliseconds(), stage.Increment.Milliseconds(),
+			stage.Delay.Milliseconds(), stage.DelayMode.String())
+		if err != nil {
+			return fmt.Errorf("store: failed to add time stage %d to game %s: %v", i+1, g.id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("store: failed to create game %s: %v", g.id, err)
+	}
+	return nil
+}
+
+// Records the user joining on the side, starting the game
+func (s *GameStore) startGame(id uuid.UUID, userId uuid.UUID, side game.PieceColor, startedAt time.Time) error {
+	column := "white_id"
+	if side == game.PieceColor_Black {
+		column = "black_id"
+	}
+	_, err := s.db.Exec(
+		fmt.Sprintf(`UPDATE game SET %s = $2, started_at = $3 WHERE id = $1`, column),
+		id, userId, startedAt)
+	if err != nil {
+		return fmt.Errorf("store: failed to start game %s: %v", id, err)
+	}
+	return nil
+}
+
+func (s *GameStore) addMove(id uuid.UUID, ply int, move storedMove) error {
+	_, err := s.db.Exec(`
+		INSERT INTO game_move (game_id, ply, uci, san, white_remaining_ms, black_remaining_ms, played_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		id, ply, move.uci, move.san, move.remaining[game.PieceColor_White].Milliseconds(),
+		move.remaining[game.PieceColor_Black].Milliseconds(), move.playedAt)
+	if err != nil {
+		return fmt.Errorf("store: failed to add move %d to game %s: %v", ply, id, err)
+	}
+	return nil
+}
+
+// Removes the moves taken back, keeping the first numMoves
+func (s *GameStore) takeBack(id uuid.UUID, numMoves int) error {
+	_, err := s.db.Exec(`DELETE FROM game_move WHERE game_id = $1 AND ply >= $2`, id, numMoves)
+	if err != nil {
+		return fmt.Errorf("store: failed to take back moves of game %s: %v", id, err)
+	}
+	return nil
+}
+
+func (s *GameStore) startVacation(id uuid.UUID, side game.PieceColor, startedAt time.Time) error {
+	_, err := s.db.Exec(`
+		INSERT INTO game_vacation (game_id, side, started_at) VALUES ($1, $2, $3)`,
+		id, side, startedAt)
+	if err != nil {
+		return fmt.Errorf("store: failed to start vacation in game %s: %v", id, err)
+	}
+	return nil
+}
+
+func (s *GameStore) endVacation(id uuid.UUID, side game.PieceColor, endedAt time.Time) error {
+	_, err := s.db.Exec(`
+		UPDATE game_vacation SET ended_at = $3 WHERE game_id = $1 AND side = $2 AND ended_at IS NULL`,
+		id, side, endedAt)
+	if err != nil {
+		return fmt.Errorf("store: failed to end vacation in game %s: %v", id, err)
+	}
+	return nil
+}
+
+func (s *GameStore) endGame(id uuid.UUID, result *game.ResultData, endedAt time.Time) error {
+	_, err := s.db.Exec(`
+		UPDATE game SET result = $2, draw_reason = $3, winner = $4, ended_at = $5 WHERE id = $1`,
+		id, result.Result, result.DrawReason, result.Winner, endedAt)
+	if err != nil {
+		return fmt.Errorf("store: failed to end game %s: %v", id, err)
+	}
+	return nil
+}
+
+// Loads the games which haven't ended, with their moves in order
+func (s *GameStore) activeGames() ([]storedGame, error) {
+	rows, err := s.db.Query(`
+		SELECT id, white_id, black_id, engine_level, engine_side, duration_ms, increment_ms,
+			delay_ms, delay_mode, stage_moves, days_per_move, vacation_days, draw_policy, variant,
+			initial_fen, created_at, started_at
+		FROM game
+		WHERE ended_at IS NULL
+		ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to load active games: %v", err)
+	}
+	defer rows.Close()
+
+	var games []storedGame
+	for rows.Next() {
+		var g storedGame
+		var white, black uuid.NullUUID
+		var engineLevel, engineSide sql.NullInt64
+		var durationMs, incrementMs, delayMs int64
+		var stageMoves, daysPerMove, vacationDays int
+		var delayMode, drawPolicy, variant string
+		var startedAt sql.NullTime
+		if err := rows.Scan(&g.id, &white, &black, &engineLevel, &engineSide, &durationMs,
+			&incrementMs, &delayMs, &delayMode, &stageMoves, &daysPerMove, &vacationDays, &drawPolicy,
+			&variant, &g.initialFEN, &g.createdAt, &startedAt); err != nil {
+			return nil, fmt.Errorf("store: failed to read game: %v", err)
+		}
+
+		g.users = make(map[uuid.UUID]game.PieceColor)
+		if white.Valid {
+			g.users[white.UUID] = game.PieceColor_White
+		}
+		if black.Valid {
+			g.users[black.UUID] = game.PieceColor_Black
+		}
+		if engineLevel.Valid {
+			level := int(engineLevel.Int64)
+			g.engineLevel = &level
+			g.engineSide = game.PieceColor(engineSide.Int64)
+		}
+		g.control = game.TimeControl{
+			Total:     time.Duration(durationMs) * time.Millisecond,
+			Increment: time.Duration(incrementMs) * time.Millisecond,
+			Delay:     time.Duration(delayMs) * time.Millisecond,
+			Moves:     stageMoves,
+
+			DaysPerMove:  daysPerMove,
+			VacationDays: vacationDays,
+		}
+		var ok bool
+		if g.control.DelayMode, ok = game.ParseDelayMode(delayMode); !ok {
+			return nil, fmt.Errorf("store: game %s has unknown delay mode %q", g.id, delayMode)
+		}
+		if g.drawPolicy, ok = game.ParseDrawPolicy(drawPolicy); !ok {
+			return nil, fmt.Errorf("store: game %s has unknown draw policy %q", g.id, drawPolicy)
+		}
+		if g.variant, ok = game.ParseVariant(variant); !ok {
+			return nil, fmt.Errorf("store: game %s has unknown variant %q", g.id, variant)
+		}
+		if startedAt.Valid {
+			g.startedAt = &startedAt.Time
+		}
+		games = append(games, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: failed to load active games: %v", err)
+	}
+
+	for i := range games {
+		if games[i].control.Later, err = s.timeStages(games[i].id); err != nil {
+			return nil, err
+		}
+		if games[i].moves, err = s.moves(games[i].id); err != nil {
+			return nil, err
+		}
+		if games[i].vacations, err = s.vacations(games[i].id); err != nil {
+			return nil, err
+		}
+	}
+	return games, nil
+}
+
+// Loads the stages of the game's time control after the first, in order
+func (s *GameStore) timeStages(id uuid.UUID) ([]game.TimeStage, error) {
+	rows, err := s.db.Query(`
+		SELECT moves, duration_ms, increment_ms, delay_ms, delay_mode
+		FROM game_time_stage
+		WHERE game_id = $1
+		ORDER BY stage`, id)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to load time stages of game %s: %v", id, err)
+	}
+	defer rows.Close()
+
+	var stages []game.TimeStage
+	for rows.Next() {
+		var stage game.TimeStage
+		var durationMs, incrementMs, delayMs int64
+		var delayMode string
+		if err := rows.Scan(&stage.Moves, &durationMs, &incrementMs, &delayMs, &delayMode); err != nil {
+			return nil, fmt.Errorf("store: failed to read time stage of game %s: %v", id, err)
+		}
+		stage.Time = time.Duration(durationMs) * time.Millisecond
+		stage.Increment = time.Duration(incrementMs) * time.Millisecond
+		stage.Delay = time.Duration(delayMs) * time.Millisecond
+		var ok bool
+		if stage.DelayMode, ok = game.ParseDelayMode(delayMode); !ok {
+			return nil, fmt.Errorf("store: game %s has unknown delay mode %q", id, delayMode)
+		}
+		stages = append(stages, stage)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: failed to load time stages of game %s: %v", id, err)
+	}
+	return stages, nil
+}
+
+func (s *GameStore) moves(id uuid.UUID) ([]storedMove, error) {
+	rows, err := s.db.Query(`
+		SELECT uci, san, white_remaining_ms, black_remaining_ms, played_at
+		FROM game_move
+		WHERE game_id = $1
+		ORDER BY ply`, id)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to load moves of game %s: %v", id, err)
+	}
+	defer rows.Close()
+
+	var moves []storedMove
+	for rows.Next() {
+		var move storedMove
+		var whiteMs, blackMs int64
+		if err := rows.Scan(&move.uci, &move.san, &whiteMs, &blackMs, &move.playedAt); err != nil {
+			return nil, fmt.Errorf("store: failed to read move of game %s: %v", id, err)
+		}
+		move.remaining = map[game.PieceColor]time.Duration{
+			game.PieceColor_White: time.Duration(whiteMs) * time.Millisecond,
+			game.PieceColor_Black: time.Duration(blackMs) * time.Millisecond,
+		}
+		moves = append(moves, move)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: failed to load moves of game %s: %v", id, err)
+	}
+	return moves, nil
+}
+
+// Loads the vacations taken in the game, in the order they began
+func (s *GameStore) vacations(id uuid.UUID) ([]game.Vacation, error) {
+	rows, err := s.db.Query(`
+		SELECT side, started_at, ended_at
+		FROM game_vacation
+		WHERE game_id = $1
+		ORDER BY started_at`, id)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to load vacations of game %s: %v", id, err)
+	}
+	defer rows.Close()
+
+	var vacations []game.Vacation
+	for rows.Next() {
+		var vacation game.Vacation
+		var side int64
+		var endedAt sql.NullTime
+		if err := rows.Scan(&side, &vacation.Start, &endedAt); err != nil {
+			return nil, fmt.Errorf("store: failed to read vacation of game %s: %v", id, err)
+		}
+		vacation.Side = game.PieceColor(side)
+		if endedAt.Valid {
+			vacation.End = &endedAt.Time
+		}
+		vacations = append(vacations, vacation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: failed to load vacations of game %s: %v", id, err)
+	}
+	return vacations, nil
+}
+
+// The sides the user played in their latest games which started, most recent first
+func (s *GameStore) recentSides(userId uuid.UUID, limit int) ([]game.PieceColor, error) {
+	rows, err := s.db.Query(`
+		SELECT white_id = $1
+		FROM game
+		WHERE (white_id = $1 OR black_id = $1) AND started_at IS NOT NULL
+		ORDER BY started_at DESC
+		LIMIT $2`, userId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to load recent games of user %s: %v", userId, err)
+	}
+	defer rows.Close()
+
+	var sides []game.PieceColor
+	for rows.Next() {
+		var white bool
+		if err := rows.Scan(&white); err != nil {
+			return nil, fmt.Errorf("store: failed to read recent game of user %s: %v", userId, err)
+		}
+		if white {
+			sides = append(sides, game.PieceColor_White)
+		} else {
+			sides = append(sides, game.PieceColor_Black)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: failed to load recent games of user %s: %v", userId, err)
+	}
+	return sides, nil
+}
+
+// Helpers
+
+func sideUserIds(users map[uuid.UUID]game.PieceColor) (white uuid.NullUUID, black uuid.NullUUID) {
+	for id, side := range users {
+		if side == game.PieceColor_White {
+			white = uuid.NullUUID{UUID: id, Valid: true}
+		} else {
+			black = uuid.NullUUID{UUID: id, Valid: true}
+		}
+	}
+	return white, black
+}
@@ -0,0 +1,117 @@
+package game
+
+import (
+	"fmt"
+	"gochess/lib/game"
+
+	"github.com/google/uuid"
+)
+
+// A side asking to take back their last move, waiting for the opponent to agree. If the
+// opponent has already replied, their reply is taken back too. Any move cancels it.
+type TakebackRequest struct {
+	Side game.PieceColor `json:"side"`
+	// Number of moves played when the request was made
+	Ply int `json:"ply"`
+}
+
+type requestTakebackCommand struct {
+	userId uuid.UUID
+	ch     chan<- error
+}
+
+type acceptTakebackCommand struct {
+	userId uuid.UUID
+	ch     chan<- error
+}
+
+type declineTakebackCommand struct {
+	userId uuid.UUID
+	ch     chan<- error
+}
+
+func (s *GameSession) requestTakeback(userId uuid.UUID) error {
+	side, exists := s.users[userId]
+	if !exists {
+		return fmt.Errorf("user is not allowed to request a takeback in this game")
+	}
+	if !s.game.InProgress() {
+		return fmt.Errorf("can't request a takeback, game not in progress")
+	}
+	if s.takebackRequest != nil {
+		return fmt.Errorf("a takeback has already been requested")
+	}
+	if s.takebackMoves(side) > len(s.game.Snapshot().Moves) {
+		return fmt.Errorf("no move to take back")
+	}
+
+	s.takebackRequest = &TakebackRequest{Side: side, Ply: len(s.game.Snapshot().Moves)}
+	event := s.newEvent(GameEvent_TakebackRequest)
+	event.Side = &side
+	s.publish(event)
+
+	// The engine always obliges
+	if s.engine != nil {
+		s.takeBack()
+	}
+	return nil
+}
+
+func (s *GameSession) acceptTakeback(userId uuid.UUID) error {
+	side, exists := s.users[userId]
+	if !exists || s.takebackRequest == nil || s.takebackRequest.Side == side {
+		return fmt.Errorf("there is no takeback request to accept")
+	}
+	return s.takeBack()
+}
+
+func (s *GameSession) declineTakeback(userId uuid.UUID) error {
+	side, exists := s.users[userId]
+	if !exists || s.takebackRequest == nil || s.takebackRequest.Side == side {
+		return fmt.Errorf("there is no takeback request to decline")
+	}
+	s.takebackRequest = nil
+	event := s.newEvent(GameEvent_TakebackDecline)
+	event.Side = &side
+	s.publish(event)
+	return nil
+}
+
+// Takes back the moves requested, up to and including the requesting side's last move
+func (s *GameSession) takeBack() error {
+	n := s.takebackMoves(s.takebackRequest.Side)
+	if err := s.game.Takeback(n); err != nil {
+		return err
+	}
+	s.takebackRequest = nil
+
+	numMoves := len(s.game.Snapshot().Moves)
+	if err := s.store.takeBack(s.id, numMoves); err != nil {
+		return s.storeFailed(err)
+	}
+	s.publish(s.newEvent(GameEvent_Takeback))
+	// Offers made in positions which were taken back no longer stand
+	s.cancelDrawOffer()
+	s.armDeadlineTimer()
+	s.think()
+	return nil
+}
+
+// Number of moves to take back for the side's last move to be undone
+func (s *GameSession) takebackMoves(side game.PieceColor) int {
+	if s.game.MovingSide() == side {
+		return 2
+	}
+	return 1
+}
+
+func (s *GameSession) moveCancelsTakeback() {
+	if s.takebackRequest == nil {
+		return
+	}
+	side := s.takebackRequest.Side
+	s.takebackRequest = nil
+	event := s.newEvent(GameEvent_TakebackCancel)
+	event.Side = &side
+	s.publish(event)
+}
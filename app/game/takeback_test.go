@@ -0,0 +1,79 @@
+package game
+
+import (
+	"gochess/lib/game"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestTakebackMoves(t *testing.T) {
+	tests := map[string]struct {
+		moves     []string
+		requester game.PieceColor
+		wantMoves []string
+	}{
+		"Before the opponent replies": {
+			moves:     []string{"e2e4", "e7e5", "g1f3"},
+			requester: game.PieceColor_White,
+			wantMoves: []string{"e4", "e5"},
+		},
+		"After the opponent replied": {
+			moves:     []string{"e2e4", "e7e5", "g1f3", "b8c6"},
+			requester: game.PieceColor_White,
+			wantMoves: []string{"e4", "e5"},
+		},
+		"First move": {
+			moves:     []string{"e2e4"},
+			requester: game.PieceColor_White,
+			wantMoves: nil,
+		},
+		"Black's first move after white replied": {
+			moves:     []string{"e2e4", "e7e5", "g1f3"},
+			requester: game.PieceColor_Black,
+			wantMoves: []string{"e4"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			white, black := uuid.New(), uuid.New()
+			users := map[game.PieceColor]uuid.UUID{game.PieceColor_White: white, game.PieceColor_Black: black}
+			store := newFakeStore()
+			s, _ := newTestService(t, store)
+			id, _ := matchedGame(t, s, white, black)
+			for i, uci := range test.moves {
+				mustMove(t, s, id, users[game.PieceColor(i%2)], uci)
+			}
+
+			if err := s.RequestTakeback(id, users[test.requester]); err != nil {
+				t.Fatalf("RequestTakeback() failed unexpectedly: %v", err)
+			}
+			if err := s.AcceptTakeback(id, users[test.requester.Opponent()]); err != nil {
+				t.Fatalf("AcceptTakeback() failed unexpectedly: %v", err)
+			}
+			snap, err := s.SessionSnapshot(id, white)
+			if err != nil {
+				t.Fatalf("SessionSnapshot() failed unexpectedly: %v", err)
+			}
+			if got := snap.Game.SANMoves; !slices.Equal(got, test.wantMoves) {
+				t.Errorf("moves got %v, want %v", got, test.wantMoves)
+			}
+			if got := len(store.game(id).moves); got != len(test.wantMoves) {
+				t.Errorf("store got %d moves, want %d", got, len(test.wantMoves))
+			}
+		})
+	}
+}
+
+func TestTakebackWithoutMove(t *testing.T) {
+	white, black := uuid.New(), uuid.New()
+	s, _ := newTestService(t, newFakeStore())
+	id, _ := matchedGame(t, s, white, black)
+	mustMove(t, s, id, white, "e2e4")
+
+	// Black has no move of their own to take back yet
+	if err := s.RequestTakeback(id, black); err == nil {
+		t.Errorf("RequestTakeback() before black moved succeeded")
+	}
+}
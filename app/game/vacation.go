@@ -0,0 +1,71 @@
+package game
+
+import (
+	"fmt"
+	"gochess/lib/game"
+
+	"github.com/google/uuid"
+)
+
+type startVacationCommand struct {
+	userId uuid.UUID
+	ch     chan<- error
+}
+
+type endVacationCommand struct {
+	userId uuid.UUID
+	ch     chan<- error
+}
+
+// Stops the player's clock in a correspondence game until they return or their vacation
+// time runs out
+func (s *GameSession) startVacation(userId uuid.UUID) error {
+	side, exists := s.users[userId]
+	if !exists {
+		return fmt.Errorf("user is not allowed to take a vacation from this game")
+	}
+	if err := s.game.StartVacation(side); err != nil {
+		return err
+	}
+	// Stored as started when the game has it, so it ends on time once restored
+	vacations := s.game.Vacations()
+	if err := s.store.startVacation(s.id, side, vacations[len(vacations)-1].Start); err != nil {
+		return s.storeFailed(err)
+	}
+	event := s.newEvent(GameEvent_VacationStart)
+	event.Side = &side
+	s.publish(event)
+	s.armDeadlineTimer()
+	return nil
+}
+
+func (s *GameSession) endVacation(userId uuid.UUID) error {
+	side, exists := s.users[userId]
+	if !exists {
+		return fmt.Errorf("user is not allowed to return from vacation in this game")
+	}
+	return s.returnFromVacation(side)
+}
+
+// Ends the side's vacation, whether they returned or their vacation time ran out
+func (s *GameSession) returnFromVacation(side game.PieceColor) error {
+	if err := s.game.EndVacation(side); err != nil {
+		return err
+	}
+	// The vacation just ended is the side's last, and ended early if its time ran out
+	vacations := s.game.Vacations()
+	for i := len(vacations) - 1; i >= 0; i-- {
+		if vacations[i].Side != side {
+			continue
+		}
+		if err := s.store.endVacation(s.id, side, *vacations[i].End); err != nil {
+			return s.storeFailed(err)
+		}
+		break
+	}
+	event := s.newEvent(GameEvent_VacationEnd)
+	event.Side = &side
+	s.publish(event)
+	s.armDeadlineTimer()
+	return nil
+}
@@ -0,0 +1,39 @@
+package game
+
+import (
+	"gochess/lib/game"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestVacationStoredAsTaken(t *testing.T) {
+	white, black := uuid.New(), uuid.New()
+	store := newFakeStore()
+	s, source := newTestService(t, store)
+	id, err := s.NewMatchedGame(game.TimeControl{DaysPerMove: 3, VacationDays: 7}, game.NewGameState(), game.DrawPolicy_Automatic, white, black)
+	if err != nil {
+		t.Fatalf("NewMatchedGame() failed unexpectedly: %v", err)
+	}
+	mustMove(t, s, id, white, "e2e4")
+	mustMove(t, s, id, black, "e7e5")
+
+	source.Advance(time.Hour)
+	start := source.Now()
+	if err := s.StartVacation(id, white); err != nil {
+		t.Fatalf("StartVacation() failed unexpectedly: %v", err)
+	}
+	source.Advance(24 * time.Hour)
+	if err := s.EndVacation(id, white); err != nil {
+		t.Fatalf("EndVacation() failed unexpectedly: %v", err)
+	}
+
+	vacations := store.game(id).vacations
+	if len(vacations) != 1 {
+		t.Fatalf("store got vacations %+v, want one", vacations)
+	}
+	if got := vacations[0]; got.Side != game.PieceColor_White || !got.Start.Equal(start) || got.End == nil || !got.End.Equal(source.Now()) {
+		t.Errorf("store got vacation %+v, want white's from %v to %v", got, start, source.Now())
+	}
+}
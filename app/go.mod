@@ -1,8 +1,6 @@
 module gochess
 
-go 1.23.0
-
-toolchain go1.24.6
+go 1.25.0
 
 require golang.org/x/exp v0.0.0-20230905200255-921286631fa9
 
@@ -11,6 +9,7 @@ require (
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/golang-migrate/migrate/v4 v4.18.3
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/lib/pq v1.10.9
 	github.com/samber/lo v1.38.1
 )
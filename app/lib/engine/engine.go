@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"fmt"
+	"gochess/lib/game"
+	"time"
+)
+
+const (
+	MinLevel = 1
+	MaxLevel = 8
+)
+
+type levelConfig struct {
+	maxDepth int
+	maxTime  time.Duration
+	// Random noise in centipawns added to evaluations to weaken play
+	noise int
+}
+
+var levels = map[int]levelConfig{
+	1: {maxDepth: 1, maxTime: 100 * time.Millisecond, noise: 300},
+	2: {maxDepth: 2, maxTime: 200 * time.Millisecond, noise: 150},
+	3: {maxDepth: 3, maxTime: 500 * time.Millisecond, noise: 80},
+	4: {maxDepth: 4, maxTime: time.Second, noise: 40},
+	5: {maxDepth: 5, maxTime: 2 * time.Second, noise: 15},
+	6: {maxDepth: 6, maxTime: 3 * time.Second},
+	7: {maxDepth: 8, maxTime: 5 * time.Second},
+	8: {maxDepth: maxPly, maxTime: 10 * time.Second},
+}
+
+// Engine picks moves with an iterative deepening alpha-beta search. Its settings are
+// fixed once created, so it may search several positions concurrently.
+type Engine struct {
+	level      int
+	config     levelConfig
+	evaluation *Evaluation
+}
+
+type SearchResult struct {
+	Move  game.Move
+	Score int
+	// Deepest fully searched ply
+	Depth int
+	Nodes int
+}
+
+func NewEngine(level int) (*Engine, error) {
+	config, ok := levels[level]
+	if !ok {
+		return nil, fmt.Errorf("engine: level must be between %d and %d, got %d", MinLevel, MaxLevel, level)
+	}
+	return &Engine{level: level, config: config, evaluation: &DefaultEvaluation}, nil
+}
+
+func (e *Engine) Level() int {
+	return e.level
+}
+
+// WithEvaluation returns a copy of the engine scoring positions with the given evaluation
+func (e *Engine) WithEvaluation(evaluation *Evaluation) *Engine {
+	copy := *e
+	copy.evaluation = evaluation
+	return &copy
+}
+
+// Budget returns how long to think for the side to move in a game, a share of its
+// remaining clock time plus most of the increment or delay of the current stage, capped by
+// the level
+func (e *Engine) Budget(g *game.Game) time.Duration {
+	side := g.MovingSide()
+	remaining := g.RemainingTime(side)
+	stage := g.Control().Stages()[g.Stage(side)]
+	return min(TimeBudget(remaining, stage.Increment+stage.Delay), e.config.maxTime)
+}
+
+func TimeBudget(remaining time.Duration, increment time.Duration) time.Duration {
+	budget := remaining/40 + increment*3/4
+	// Never use the bulk of what's left when low on time
+	return max(min(budget, remaining/4), 0)
+}
+
+// Search finds the best move in the position within the time budget. A search to depth
+// one is always completed so a move is returned even if the budget runs out.
+func (e *Engine) Search(state *game.GameState, budget time.Duration) (*SearchResult, error) {
+	s := newSearcher(e.evaluation, e.config.noise, time.Now().Add(budget))
+	result := s.iterate(state, e.config.maxDepth)
+	if result == nil {
+		return nil, fmt.Errorf("engine: no legal moves in position")
+	}
+	return result, nil
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"gochess/lib/game"
+	"testing"
+	"time"
+)
+
+func TestSearchFindsBestMove(t *testing.T) {
+	tests := map[string]struct {
+		fen  string
+		want string
+	}{
+		"Back rank mate":         {"6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1", "a1a8"},
+		"Smothered mate":         {"6rk/6pp/8/6N1/8/8/8/6K1 w - - 0 1", "g5f7"},
+		"Black mates":            {"r5k1/8/8/8/8/8/5PPP/6K1 b - - 0 1", "a8a1"},
+		"Take the hanging queen": {"4k3/8/8/3q4/8/8/8/3RK3 w - - 0 1", "d1d5"},
+		"Promote the pawn":       {"8/4P3/8/8/8/k7/8/K7 w - - 0 1", "e7e8q"},
+	}
+
+	e, err := NewEngine(MaxLevel)
+	if err != nil {
+		t.Fatalf("NewEngine(%d) failed unexpectedly: %v", MaxLevel, err)
+	}
+	for title, test := range tests {
+		result, err := e.Search(game.MustParseFEN(test.fen), time.Second)
+		if err != nil {
+			t.Errorf("%s: Search() errored: %v", title, err)
+			continue
+		}
+		if got := result.Move.UCI(); got != test.want {
+			t.Errorf("%s: Search() got %s, want %s", title, got, test.want)
+		}
+	}
+}
+
+func TestSearchReportsMate(t *testing.T) {
+	e, _ := NewEngine(MaxLevel)
+	result, err := e.Search(game.MustParseFEN("6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1"), time.Second)
+	if err != nil {
+		t.Fatalf("Search() failed unexpectedly: %v", err)
+	}
+	if result.Score != mateScore-1 {
+		t.Errorf("Search() got score %d, want mate in one %d", result.Score, mateScore-1)
+	}
+}
+
+func TestSearchWithoutLegalMoves(t *testing.T) {
+	e, _ := NewEngine(MinLevel)
+	for _, fen := range []string{
+		"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", // Stalemate
+		"7k/6Q1/6K1/8/8/8/8/8 b - - 0 1", // Checkmate
+	} {
+		if result, err := e.Search(game.MustParseFEN(fen), time.Second); err == nil {
+			t.Errorf("Search(%s) got %s, want an error", fen, result.Move.UCI())
+		}
+	}
+}
+
+func TestSearchRespectsBudget(t *testing.T) {
+	e, _ := NewEngine(MaxLevel)
+	start := time.Now()
+	result, err := e.Search(game.MustParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Search() failed unexpectedly: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Search() took %v with a budget of %v", elapsed, 200*time.Millisecond)
+	}
+	if result.Depth < 1 {
+		t.Errorf("Search() completed depth %d, want at least 1", result.Depth)
+	}
+}
+
+func TestNewEngineLevels(t *testing.T) {
+	for level := MinLevel; level <= MaxLevel; level++ {
+		if e, err := NewEngine(level); err != nil || e.Level() != level {
+			t.Errorf("NewEngine(%d) failed: %v", level, err)
+		}
+	}
+	for _, level := range []int{MinLevel - 1, MaxLevel + 1} {
+		if _, err := NewEngine(level); err == nil {
+			t.Errorf("NewEngine(%d) accepted an invalid level", level)
+		}
+	}
+}
+
+func TestTimeBudget(t *testing.T) {
+	tests := map[string]struct {
+		remaining, increment, want time.Duration
+	}{
+		"Share of the clock":    {40 * time.Second, 0, time.Second},
+		"Most of the increment": {40 * time.Second, 4 * time.Second, 4 * time.Second},
+		"Low on time":           {2 * time.Second, 10 * time.Second, 500 * time.Millisecond},
+		"Flagged":               {0, 0, 0},
+	}
+	for title, test := range tests {
+		if got := TimeBudget(test.remaining, test.increment); got != test.want {
+			t.Errorf("%s: TimeBudget(%v, %v) got %v, want %v", title, test.remaining, test.increment, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"gochess/lib/game"
+)
+
+// Evaluation scores positions in centipawns from material and piece-square tables.
+// Tables are written from white's point of view with a8 first, as the board is usually
+// printed, and are mirrored for black.
+type Evaluation struct {
+	Material [6]int
+	// Indexed by game.PieceType
+	PieceSquare [6][64]int
+	// Replaces the king's piece-square table as the pieces come off the board
+	KingEndgame [64]int
+}
+
+// Weight of each piece type towards the game phase, the king table is blended from
+// middlegame to endgame as the total drops from phaseTotal to zero
+var phaseWeights = [6]int{
+	game.PieceType_Queen:  4,
+	game.PieceType_Rook:   2,
+	game.PieceType_Bishop: 1,
+	game.PieceType_Knight: 1,
+}
+
+const phaseTotal = 24
+
+// Based on the Simplified Evaluation Function by Tomasz Michniewski
+var DefaultEvaluation = Evaluation{
+	Material: [6]int{
+		game.PieceType_King:   0,
+		game.PieceType_Queen:  900,
+		game.PieceType_Rook:   500,
+		game.PieceType_Bishop: 330,
+		game.PieceType_Knight: 320,
+		game.PieceType_Pawn:   100,
+	},
+	PieceSquare: [6][64]int{
+		game.PieceType_King: {
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-20, -30, -30, -40, -40, -30, -30, -20,
+			-10, -20, -20, -20, -20, -20, -20, -10,
+			20, 20, 0, 0, 0, 0, 20, 20,
+			20, 30, 10, 0, 0, 10, 30, 20,
+		},
+		game.PieceType_Queen: {
+			-20, -10, -10, -5, -5, -10, -10, -20,
+			-10, 0, 0, 0, 0, 0, 0, -10,
+			-10, 0, 5, 5, 5, 5, 0, -10,
+			-5, 0, 5, 5, 5, 5, 0, -5,
+			0, 0, 5, 5, 5, 5, 0, -5,
+			-10, 5, 5, 5, 5, 5, 0, -10,
+			-10, 0, 5, 0, 0, 0, 0, -10,
+			-20, -10, -10, -5, -5, -10, -10, -20,
+		},
+		game.PieceType_Rook: {
+			0, 0, 0, 0, 0, 0, 0, 0,
+			5, 10, 10, 10, 10, 10, 10, 5,
+			-5, 0, 0, 0, 0, 0, 0, -5,
+			-5, 0, 0, 0, 0, 0, 0, -5,
+			-5, 0, 0, 0, 0, 0, 0, -5,
+			-5, 0, 0, 0, 0, 0, 0, -5,
+			-5, 0, 0, 0, 0, 0, 0, -5,
+			0, 0, 0, 5, 5, 0, 0, 0,
+		},
+		game.PieceType_Bishop: {
+			-20, -10, -10, -10, -10, -10, -10, -20,
+			-10, 0, 0, 0, 0, 0, 0, -10,
+			-10, 0, 5, 10, 10, 5, 0, -10,
+			-10, 5, 5, 10, 10, 5, 5, -10,
+			-10, 0, 10, 10, 10, 10, 0, -10,
+			-10, 10, 10, 10, 10, 10, 10, -10,
+			-10, 5, 0, 0, 0, 0, 5, -10,
+			-20, -10, -10, -10, -10, -10, -10, -20,
+		},
+		game.PieceType_Knight: {
+			-50, -40, -30, -30, -30, -30, -40, -50,
+			-40, -20, 0, 0, 0, 0, -20, -40,
+			-30, 0, 10, 15, 15, 10, 0, -30,
+			-30, 5, 15, 20, 20, 15, 5, -30,
+			-30, 0, 15, 20, 20, 15, 0, -30,
+			-30, 5, 10, 15, 15, 10, 5, -30,
+			-40, -20, 0, 5, 5, 0, -20, -40,
+			-50, -40, -30, -30, -30, -30, -40, -50,
+		},
+		game.PieceType_Pawn: {
+			0, 0, 0, 0, 0, 0, 0, 0,
+			50, 50, 50, 50, 50, 50, 50, 50,
+			10, 10, 20, 30, 30, 20, 10, 10,
+			5, 5, 10, 25, 25, 10, 5, 5,
+			0, 0, 0, 20, 20, 0, 0, 0,
+			5, -5, -10, 0, 0, -10, -5, 5,
+			5, 10, 10, -20, -20, 10, 10, 5,
+			0, 0, 0, 0, 0, 0, 0, 0,
+		},
+	},
+	KingEndgame: [64]int{
+		-50, -40, -30, -20, -20, -30, -40, -50,
+		-30, -20, -10, 0, 0, -10, -20, -30,
+		-30, -10, 20, 30, 30, 20, -10, -30,
+		-30, -10, 30, 40, 40, 30, -10, -30,
+		-30, -10, 30, 40, 40, 30, -10, -30,
+		-30, -10, 20, 30, 30, 20, -10, -30,
+		-30, -30, 0, 0, 0, 0, -30, -30,
+		-50, -30, -30, -30, -30, -30, -30, -50,
+	},
+}
+
+// Evaluate scores the position for the side to move, positive when it is ahead
+func (e *Evaluation) Evaluate(state *game.GameState) int {
+	var score, kingMiddlegame, kingEndgame [2]int
+	phase := 0
+	for square, piece := range state.Board().Pieces() {
+		color, t := piece.Color(), piece.Type()
+		index := tableIndex(square, color)
+		phase += phaseWeights[t]
+		if t == game.PieceType_King {
+			kingMiddlegame[color] = e.PieceSquare[t][index]
+			kingEndgame[color] = e.KingEndgame[index]
+			continue
+		}
+		score[color] += e.Material[t] + e.PieceSquare[t][index]
+	}
+
+	phase = min(phase, phaseTotal)
+	for color := range score {
+		score[color] += (kingMiddlegame[color]*phase + kingEndgame[color]*(phaseTotal-phase)) / phaseTotal
+	}
+
+	side := state.MovingSide()
+	return score[side] - score[side.Opponent()]
+}
+
+// Helpers
+
+func tableIndex(square game.Square, color game.PieceColor) int {
+	if color == game.PieceColor_White {
+		return (7-square.Rank)*8 + square.File
+	}
+	return square.Rank*8 + square.File
+}
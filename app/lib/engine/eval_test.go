@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"gochess/lib/game"
+	"testing"
+)
+
+func TestEvaluateSymmetric(t *testing.T) {
+	tests := map[string]struct {
+		fen, mirrored string
+	}{
+		"Starting position": {
+			game.FEN_StartingPosition,
+			game.FEN_StartingPosition,
+		},
+		"Kiwipete": {
+			"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+			"r3k2r/pppbbppp/2n2q1P/1P2p3/3pn3/BN2PNP1/P1PPQPB1/R3K2R b KQkq - 0 1",
+		},
+		"King endgame": {
+			"8/8/4k3/8/8/8/8/K7 w - - 0 1",
+			"k7/8/8/8/8/4K3/8/8 b - - 0 1",
+		},
+	}
+
+	for title, test := range tests {
+		score := DefaultEvaluation.Evaluate(game.MustParseFEN(test.fen))
+		mirrored := DefaultEvaluation.Evaluate(game.MustParseFEN(test.mirrored))
+		if score != mirrored {
+			t.Errorf("%s: Evaluate() got %d, mirrored position got %d", title, score, mirrored)
+		}
+	}
+}
+
+func TestEvaluatePrefersSideAhead(t *testing.T) {
+	tests := map[string]struct {
+		fen      string
+		positive bool
+	}{
+		"Extra queen to move":      {"4k3/8/8/8/8/8/8/3QK3 w - - 0 1", true},
+		"Extra queen for opponent": {"4k3/8/8/8/8/8/8/3QK3 b - - 0 1", false},
+		"Centralised king late on": {"8/8/8/3k4/8/8/8/K7 b - - 0 1", true},
+		"Developed knight":         {"rnbqkbnr/pppppppp/8/8/8/5N2/PPPPPPPP/RNBQKB1R w KQkq - 0 1", true},
+	}
+
+	for title, test := range tests {
+		score := DefaultEvaluation.Evaluate(game.MustParseFEN(test.fen))
+		if (score > 0) != test.positive {
+			t.Errorf("%s: Evaluate() got %d, want positive = %v", title, score, test.positive)
+		}
+	}
+}
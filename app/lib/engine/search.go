@@ -0,0 +1,331 @@
+package engine
+
+import (
+	"gochess/lib/game"
+	"slices"
+	"time"
+)
+
+const (
+	maxPly = 64
+
+	mateScore = 100000
+	// Scores beyond this are mates, stored in the transposition table relative to the node
+	mateThreshold = mateScore - maxPly
+	infinity      = mateScore + 1
+
+	transpositionTableSize = 1 << 20
+	// How often the clock is read, in nodes
+	deadlineCheckInterval = 1024
+)
+
+type boundType int
+
+const (
+	boundExact boundType = iota
+	boundLower
+	boundUpper
+)
+
+type transposition struct {
+	depth int
+	score int
+	bound boundType
+	move  *game.Move
+}
+
+type searcher struct {
+	evaluation *Evaluation
+	noise      int
+	deadline   time.Time
+	// Set once the deadline passes, unwinding the search
+	stopped bool
+	// Depth one is completed regardless of the deadline
+	mustComplete bool
+	nodes        int
+
+	table   map[uint64]transposition
+	killers [maxPly + 1][2]*game.Move
+	// Hashes of the positions leading to the current node, for repetition draws
+	path []uint64
+}
+
+func newSearcher(evaluation *Evaluation, noise int, deadline time.Time) *searcher {
+	return &searcher{
+		evaluation: evaluation,
+		noise:      noise,
+		deadline:   deadline,
+		table:      make(map[uint64]transposition),
+	}
+}
+
+func (s *searcher) iterate(root *game.GameState, maxDepth int) *SearchResult {
+	if len(root.LegalMoves(root.MovingSide())) == 0 {
+		return nil
+	}
+
+	var best *SearchResult
+	for depth := 1; depth <= maxDepth; depth++ {
+		s.mustComplete = depth == 1
+		s.path = s.path[:0]
+		score := s.negamax(root, depth, 0, -infinity, infinity)
+		if s.stopped {
+			break
+		}
+		entry := s.table[root.Hash()]
+		best = &SearchResult{Move: *entry.move, Score: score, Depth: depth, Nodes: s.nodes}
+		// No need to look further once a forced mate is found
+		if score > mateThreshold || score < -mateThreshold {
+			break
+		}
+		if time.Now().After(s.deadline) {
+			break
+		}
+	}
+	best.Nodes = s.nodes
+	return best
+}
+
+func (s *searcher) negamax(state *game.GameState, depth int, ply int, alpha int, beta int) int {
+	if s.checkDeadline() {
+		return 0
+	}
+	hash := state.Hash()
+	if ply > 0 && s.repeats(hash) {
+		return 0
+	}
+
+	side := state.MovingSide()
+	inCheck := state.IsSideInCheck(side)
+	if inCheck && ply < maxPly {
+		depth++
+	}
+	if depth <= 0 || ply >= maxPly {
+		return s.quiescence(state, ply, alpha, beta)
+	}
+
+	var hashMove *game.Move
+	if entry, ok := s.table[hash]; ok {
+		hashMove = entry.move
+		if ply > 0 && entry.depth >= depth {
+			score := fromTableScore(entry.score, ply)
+			switch {
+			case entry.bound == boundExact,
+				entry.bound == boundLower && score >= beta,
+				entry.bound == boundUpper && score <= alpha:
+				return score
+			}
+		}
+	}
+
+	moves := state.LegalMoves(side)
+	if len(moves) == 0 {
+		if inCheck {
+			return -mateScore + ply
+		}
+		return 0
+	}
+	s.orderMoves(state, moves, hashMove, ply)
+
+	s.path = append(s.path, hash)
+	defer func() { s.path = s.path[:len(s.path)-1] }()
+
+	originalAlpha := alpha
+	bestScore, bestMove := -infinity, moves[0]
+	for _, move := range moves {
+		next, err := state.WithMove(move)
+		if err != nil {
+			continue
+		}
+		score := -s.negamax(next, depth-1, ply+1, -beta, -alpha)
+		if s.stopped {
+			return 0
+		}
+		if score > bestScore {
+			bestScore, bestMove = score, move
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			if !isCapture(state, move) {
+				s.addKiller(move, ply)
+			}
+			break
+		}
+	}
+
+	bound := boundExact
+	if bestScore <= originalAlpha {
+		bound = boundUpper
+	} else if bestScore >= beta {
+		bound = boundLower
+	}
+	s.store(hash, transposition{depth: depth, score: toTableScore(bestScore, ply), bound: bound, move: &bestMove})
+	return bestScore
+}
+
+// Searches captures until the position is quiet so a hanging piece isn't missed at the
+// search horizon
+func (s *searcher) quiescence(state *game.GameState, ply int, alpha int, beta int) int {
+	if s.checkDeadline() {
+		return 0
+	}
+
+	side := state.MovingSide()
+	moves := state.LegalMoves(side)
+	if len(moves) == 0 {
+		if state.IsSideInCheck(side) {
+			return -mateScore + ply
+		}
+		return 0
+	}
+
+	standPat := s.evaluate(state)
+	if standPat >= beta || ply >= maxPly {
+		return standPat
+	}
+	alpha = max(alpha, standPat)
+
+	captures := slices.DeleteFunc(moves, func(move game.Move) bool {
+		return !isCapture(state, move) && move.Promotion == nil
+	})
+	s.orderMoves(state, captures, nil, ply)
+	for _, move := range captures {
+		next, err := state.WithMove(move)
+		if err != nil {
+			continue
+		}
+		score := -s.quiescence(next, ply+1, -beta, -alpha)
+		if s.stopped {
+			return 0
+		}
+		if score >= beta {
+			return score
+		}
+		alpha = max(alpha, score)
+	}
+	return alpha
+}
+
+func (s *searcher) evaluate(state *game.GameState) int {
+	score := s.evaluation.Evaluate(state)
+	if s.noise > 0 {
+		// Derived from the position so a repeated evaluation gives the same answer
+		score += int(state.Hash()%uint64(2*s.noise+1)) - s.noise
+	}
+	return score
+}
+
+func (s *searcher) checkDeadline() bool {
+	s.nodes++
+	if !s.stopped && !s.mustComplete && s.nodes%deadlineCheckInterval == 0 && time.Now().After(s.deadline) {
+		s.stopped = true
+	}
+	return s.stopped
+}
+
+func (s *searcher) repeats(hash uint64) bool {
+	// Only positions with the same side to move can repeat
+	for i := len(s.path) - 2; i >= 0; i -= 2 {
+		if s.path[i] == hash {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *searcher) store(hash uint64, entry transposition) {
+	if len(s.table) >= transpositionTableSize {
+		clear(s.table)
+	}
+	s.table[hash] = entry
+}
+
+func (s *searcher) addKiller(move game.Move, ply int) {
+	if sameMove(s.killers[ply][0], move) {
+		return
+	}
+	s.killers[ply][1] = s.killers[ply][0]
+	s.killers[ply][0] = &move
+}
+
+// Move ordering: the transposition table's best move, captures of the most valuable
+// piece by the least valuable attacker, promotions, then killer moves
+func (s *searcher) orderMoves(state *game.GameState, moves []game.Move, hashMove *game.Move, ply int) {
+	type scoredMove struct {
+		move  game.Move
+		score int
+	}
+	scored := make([]scoredMove, len(moves))
+	for i, move := range moves {
+		score := 0
+		switch {
+		case sameMove(hashMove, move):
+			score = 1 << 20
+		case isCapture(state, move):
+			victim := game.PieceType_Pawn
+			if piece, ok := state.Board().GetPiece(move.To); ok {
+				victim = piece.Type()
+			}
+			attacker, _ := state.Board().GetPiece(move.From)
+			score = 1<<16 + s.evaluation.Material[victim]*16 - s.evaluation.Material[attacker.Type()]/16
+		case sameMove(s.killers[ply][0], move):
+			score = 1 << 12
+		case sameMove(s.killers[ply][1], move):
+			score = 1<<12 - 1
+		}
+		if move.Promotion != nil {
+			score += s.evaluation.Material[*move.Promotion] * 16
+		}
+		scored[i] = scoredMove{move, score}
+	}
+	slices.SortStableFunc(scored, func(a, b scoredMove) int {
+		return b.score - a.score
+	})
+	for i := range scored {
+		moves[i] = scored[i].move
+	}
+}
+
+// Helpers
+
+func isCapture(state *game.GameState, move game.Move) bool {
+	piece, _ := state.Board().GetPiece(move.From)
+	// Chess960 castling is written as the king taking its own rook
+	if target, ok := state.Board().GetPiece(move.To); ok {
+		return target.Color() != piece.Color()
+	}
+	// En-passant, the only pawn move changing file onto an empty square
+	return piece.Type() == game.PieceType_Pawn && move.From.File != move.To.File
+}
+
+func sameMove(a *game.Move, b game.Move) bool {
+	if a == nil || a.From != b.From || a.To != b.To {
+		return false
+	}
+	if a.Promotion == nil || b.Promotion == nil {
+		return a.Promotion == b.Promotion
+	}
+	return *a.Promotion == *b.Promotion
+}
+
+func toTableScore(score int, ply int) int {
+	switch {
+	case score > mateThreshold:
+		return score + ply
+	case score < -mateThreshold:
+		return score - ply
+	}
+	return score
+}
+
+func fromTableScore(score int, ply int) int {
+	switch {
+	case score > mateThreshold:
+		return score - ply
+	case score < -mateThreshold:
+		return score + ply
+	}
+	return score
+}
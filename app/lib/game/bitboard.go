@@ -0,0 +1,176 @@
+package game
+
+import (
+	"iter"
+	"math/bits"
+)
+
+// Bitboard is a set of squares with bit (rank * 8 + file) set for each member
+type Bitboard uint64
+
+const numSquares = boardNumFiles * boardNumRanks
+
+func squareIndex(square Square) int {
+	return square.Rank*boardNumFiles + square.File
+}
+
+func indexSquare(index int) Square {
+	return Square{File: index % boardNumFiles, Rank: index / boardNumFiles}
+}
+
+func squareBit(square Square) Bitboard {
+	return Bitboard(1) << squareIndex(square)
+}
+
+func (b Bitboard) Has(square Square) bool {
+	return b&squareBit(square) != 0
+}
+
+func (b Bitboard) Count() int {
+	return bits.OnesCount64(uint64(b))
+}
+
+// Squares iterates over the members of the set from a1 to h8
+func (b Bitboard) Squares() iter.Seq[Square] {
+	return func(yield func(Square) bool) {
+		for rest := b; rest != 0; rest &= rest - 1 {
+			if !yield(indexSquare(bits.TrailingZeros64(uint64(rest)))) {
+				return
+			}
+		}
+	}
+}
+
+// Precomputed attack tables
+
+type slidingRay struct {
+	// Whether square indices increase along the ray
+	ascending bool
+	squares   [numSquares]Bitboard
+}
+
+var (
+	knightAttackTable [numSquares]Bitboard
+	kingAttackTable   [numSquares]Bitboard
+	pawnAttackTable   [2][numSquares]Bitboard
+	rookRays          []slidingRay
+	bishopRays        []slidingRay
+)
+
+// Built from the delta tables which are ready before any init function runs
+func init() {
+	stepAttacks := func(index int, deltas []Square) Bitboard {
+		var attacks Bitboard
+		from := indexSquare(index)
+		for _, delta := range deltas {
+			if to := from.Adding(delta); (&Board{}).ContainsSquare(to) {
+				attacks |= squareBit(to)
+			}
+		}
+		return attacks
+	}
+	rays := func(deltas []Square) []slidingRay {
+		var out []slidingRay
+		for _, delta := range deltas {
+			ray := slidingRay{ascending: delta.Rank > 0 || (delta.Rank == 0 && delta.File > 0)}
+			for index := range ray.squares {
+				from := indexSquare(index)
+				for to := from.Adding(delta); (&Board{}).ContainsSquare(to); to = to.Adding(delta) {
+					ray.squares[index] |= squareBit(to)
+				}
+			}
+			out = append(out, ray)
+		}
+		return out
+	}
+
+	kingDeltas := append(append([]Square{}, perpendicularDeltas...), diagonalDeltas...)
+	for index := 0; index < numSquares; index++ {
+		knightAttackTable[index] = stepAttacks(index, knightDeltas)
+		kingAttackTable[index] = stepAttacks(index, kingDeltas)
+		pawnAttackTable[PieceColor_White][index] = stepAttacks(index, []Square{{File: -1, Rank: 1}, {File: 1, Rank: 1}})
+		pawnAttackTable[PieceColor_Black][index] = stepAttacks(index, []Square{{File: -1, Rank: -1}, {File: 1, Rank: -1}})
+	}
+	rookRays = rays(perpendicularDeltas)
+	bishopRays = rays(diagonalDeltas)
+}
+
+// Squares reachable along the rays up to and including the first blocker
+func slidingAttacks(index int, occupied Bitboard, rays []slidingRay) Bitboard {
+	var attacks Bitboard
+	for i := range rays {
+		ray := &rays[i]
+		attacks |= ray.squares[index]
+		blockers := ray.squares[index] & occupied
+		if blockers == 0 {
+			continue
+		}
+		var first int
+		if ray.ascending {
+			first = bits.TrailingZeros64(uint64(blockers))
+		} else {
+			first = 63 - bits.LeadingZeros64(uint64(blockers))
+		}
+		attacks &^= ray.squares[first]
+	}
+	return attacks
+}
+
+func rookAttacks(index int, occupied Bitboard) Bitboard {
+	return slidingAttacks(index, occupied, rookRays)
+}
+
+func bishopAttacks(index int, occupied Bitboard) Bitboard {
+	return slidingAttacks(index, occupied, bishopRays)
+}
+
+// Piece placement as sets of squares, cheap to copy when testing moves for legality
+type bitboards struct {
+	colors [2]Bitboard
+	types  [6]Bitboard
+}
+
+func (b *bitboards) occupied() Bitboard {
+	return b.colors[PieceColor_White] | b.colors[PieceColor_Black]
+}
+
+func (b *bitboards) pieces(t PieceType, color PieceColor) Bitboard {
+	return b.types[t] & b.colors[color]
+}
+
+func (b *bitboards) set(index int, t PieceType, color PieceColor) {
+	bit := Bitboard(1) << index
+	b.colors[color] |= bit
+	b.types[t] |= bit
+}
+
+func (b *bitboards) clear(index int) {
+	mask := ^(Bitboard(1) << index)
+	for i := range b.colors {
+		b.colors[i] &= mask
+	}
+	for i := range b.types {
+		b.types[i] &= mask
+	}
+}
+
+func (b *bitboards) isAttacked(index int, by PieceColor) bool {
+	occupied := b.occupied()
+	attackers := b.colors[by]
+	// A pawn of 'by' attacks the square if a pawn of the opponent on it would attack back
+	if pawnAttackTable[by.Opponent()][index]&b.types[PieceType_Pawn]&attackers != 0 {
+		return true
+	}
+	if knightAttackTable[index]&b.types[PieceType_Knight]&attackers != 0 {
+		return true
+	}
+	if kingAttackTable[index]&b.types[PieceType_King]&attackers != 0 {
+		return true
+	}
+	straight := (b.types[PieceType_Rook] | b.types[PieceType_Queen]) & attackers
+	if straight != 0 && rookAttacks(index, occupied)&straight != 0 {
+		return true
+	}
+	diagonal := (b.types[PieceType_Bishop] | b.types[PieceType_Queen]) & attackers
+	return diagonal != 0 && bishopAttacks(index, occupied)&diagonal != 0
+}
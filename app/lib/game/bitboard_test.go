@@ -0,0 +1,172 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestAttackTables(t *testing.T) {
+	tests := map[string]struct {
+		attacks Bitboard
+		want    []string
+	}{
+		"Knight in the corner":   {knightAttackTable[squareIndex(sq("a1"))], []string{"b3", "c2"}},
+		"Knight in the centre":   {knightAttackTable[squareIndex(sq("e4"))], []string{"c3", "c5", "d2", "d6", "f2", "f6", "g3", "g5"}},
+		"King on the edge":       {kingAttackTable[squareIndex(sq("h5"))], []string{"g4", "g5", "g6", "h4", "h6"}},
+		"White pawn on a-file":   {pawnAttackTable[PieceColor_White][squareIndex(sq("a2"))], []string{"b3"}},
+		"Black pawn":             {pawnAttackTable[PieceColor_Black][squareIndex(sq("e7"))], []string{"d6", "f6"}},
+		"Rook on an empty board": {rookAttacks(squareIndex(sq("a1")), 0), []string{"a2", "a3", "a4", "a5", "a6", "a7", "a8", "b1", "c1", "d1", "e1", "f1", "g1", "h1"}},
+		"Rook with blockers": {
+			rookAttacks(squareIndex(sq("d4")), squareBit(sq("d6"))|squareBit(sq("b4"))|squareBit(sq("d2"))|squareBit(sq("h8"))),
+			[]string{"b4", "c4", "d2", "d3", "d5", "d6", "e4", "f4", "g4", "h4"},
+		},
+		"Bishop with blockers": {
+			bishopAttacks(squareIndex(sq("c1")), squareBit(sq("e3"))|squareBit(sq("b2"))),
+			[]string{"b2", "d2", "e3"},
+		},
+	}
+
+	for title, test := range tests {
+		got := make(map[Square]bool)
+		for square := range test.attacks.Squares() {
+			got[square] = true
+		}
+		assertSquareMapEquals(title, got, test.want, t)
+	}
+}
+
+func TestBoardBitboardsFollowPieces(t *testing.T) {
+	board := NewGameState().Board().Clone()
+	board.jumpPiece(sq("e2"), sq("e4"))
+	board.jumpPiece(sq("d8"), sq("e4"))
+	board.clearSquare(sq("a1"))
+
+	if n := board.NumPieces(); n != 30 {
+		t.Errorf("NumPieces() got %d, want %d", n, 30)
+	}
+	if piece, ok := board.GetPiece(sq("e4")); !ok || piece.Type() != PieceType_Queen || piece.Color() != PieceColor_Black {
+		t.Errorf("GetPiece(e4) got %v, want the black queen", piece)
+	}
+	if board.pieces(PieceType_Pawn, PieceColor_White).Has(sq("e4")) {
+		t.Errorf("captured pawn is still in the pawn bitboard")
+	}
+	if board.occupied().Has(sq("a1")) || board.occupied().Has(sq("e2")) {
+		t.Errorf("cleared squares are still occupied")
+	}
+	if _, ok := board.GetPiece(Square{File: 8, Rank: 0}); ok {
+		t.Errorf("GetPiece() found a piece off the board")
+	}
+	for square, piece := range board.Pieces() {
+		if !board.colors[piece.Color()].Has(square) || !board.types[piece.Type()].Has(square) {
+			t.Errorf("%s: %v missing from the bitboards", square, piece)
+		}
+	}
+}
+
+func TestMoveGeneratorMatchesPieceMovers(t *testing.T) {
+	for title, fen := range fenCorpus {
+		g := MustParseFEN(fen)
+		got := movePlanStrings(g.PlanPossibleMovesForSide(g.MovingSide()))
+		want := movePlanStrings(piecePlanPossibleMovesForSide(g, g.MovingSide()))
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: generator got %v, piece movers got %v", title, got, want)
+		}
+	}
+}
+
+func TestMoveGeneratorPositions(t *testing.T) {
+	for title, fen := range fenCorpus {
+		g := MustParseFEN(fen)
+		for _, plan := range g.PlanPossibleMovesForSide(g.MovingSide()) {
+			next, err := g.WithMove(plan.Move)
+			if err != nil {
+				t.Errorf("%s: WithMove(%s) errored: %v", title, plan.UCI(), err)
+				continue
+			}
+			if next.FEN() != plan.Game.FEN() {
+				t.Errorf("%s: %s planned %s, played %s", title, plan.UCI(), plan.Game.FEN(), next.FEN())
+			}
+		}
+	}
+}
+
+// The generator is compared with the per-piece movers it replaced, both on the same board
+// and positions: each piece planning its moves, with a full attack map per resulting
+// position to look for checks
+func BenchmarkMoveGeneration(b *testing.B) {
+	for _, title := range []string{"Starting position", "Kiwipete", "Rook endgame", "Partial castling"} {
+		g := MustParseFEN(fenCorpus[title])
+		for _, gen := range moveGenerators {
+			b.Run(title+"/"+gen.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					gen.plan(g, g.MovingSide())
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkMoveTree(b *testing.B) {
+	for _, title := range []string{"Starting position", "Kiwipete", "Rook endgame", "Partial castling"} {
+		g := MustParseFEN(fenCorpus[title])
+		for _, gen := range moveGenerators {
+			b.Run(title+"/"+gen.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					countMoveTree(g, 2, gen.plan)
+				}
+			})
+		}
+	}
+}
+
+// Helpers
+
+// The move generators benchmarked against each other
+var moveGenerators = []struct {
+	name string
+	plan func(*GameState, PieceColor) []MovePlan
+}{
+	{"generator", (*GameState).PlanPossibleMovesForSide},
+	{"piece movers", piecePlanPossibleMovesForSide},
+}
+
+// Move generation as before the generator: each piece plans its moves, then every
+// resulting position recomputes the full attack map to look for checks
+func piecePlanPossibleMovesForSide(g *GameState, color PieceColor) []MovePlan {
+	var out []MovePlan
+	for from, piece := range g.Board().Pieces() {
+		if piece.Color() != color {
+			continue
+		}
+		for _, plan := range piece.PlanPossibleMovesLocally(from, g) {
+			king, ok := plan.Game.Board().getKingSquare(color)
+			if ok && plan.Game.ComputeSquaresAttackedBySide(color.Opponent())[*king] {
+				continue
+			}
+			out = append(out, plan)
+		}
+	}
+	return out
+}
+
+func countMoveTree(g *GameState, depth int, plan func(*GameState, PieceColor) []MovePlan) int {
+	plans := plan(g, g.MovingSide())
+	if depth == 1 {
+		return len(plans)
+	}
+	nodes := 0
+	for _, p := range plans {
+		nodes += countMoveTree(p.Game, depth-1, plan)
+	}
+	return nodes
+}
+
+func movePlanStrings(plans []MovePlan) []string {
+	var out []string
+	for _, plan := range plans {
+		out = append(out, plan.UCI()+" "+plan.Game.FEN())
+	}
+	sort.Strings(out)
+	return out
+}
@@ -1,21 +1,48 @@
 package game
 
+import (
+	"iter"
+	"math/bits"
+)
+
 const (
 	boardNumFiles = 8
 	boardNumRanks = 8
 )
 
 type Board struct {
-	pieces map[Square]Piece
+	squares [numSquares]Piece
+	bitboards
+	// Zobrist key of the pieces, updated as they are placed and removed
+	zobrist uint64
 }
 
 func NewBoard() *Board {
-	return &Board{pieces: make(map[Square]Piece)}
+	return &Board{}
 }
 
 func (board *Board) GetPiece(square Square) (Piece, bool) {
-	piece, exists := board.pieces[square]
-	return piece, exists
+	if !board.ContainsSquare(square) {
+		return nil, false
+	}
+	piece := board.squares[squareIndex(square)]
+	return piece, piece != nil
+}
+
+// Pieces iterates over the occupied squares from a1 to h8
+func (board *Board) Pieces() iter.Seq2[Square, Piece] {
+	return func(yield func(Square, Piece) bool) {
+		for rest := board.occupied(); rest != 0; rest &= rest - 1 {
+			index := bits.TrailingZeros64(uint64(rest))
+			if !yield(indexSquare(index), board.squares[index]) {
+				return
+			}
+		}
+	}
+}
+
+func (board *Board) NumPieces() int {
+	return board.occupied().Count()
 }
 
 func (board *Board) NumRanks() int {
@@ -27,11 +54,8 @@ func (board *Board) NumFiles() int {
 }
 
 func (board *Board) Clone() *Board {
-	copy := NewBoard()
-	for k, v := range board.pieces {
-		copy.pieces[k] = v
-	}
-	return copy
+	copy := *board
+	return &copy
 }
 
 func (board *Board) ContainsSquare(square Square) bool {
@@ -42,11 +66,23 @@ func (board *Board) ContainsSquare(square Square) bool {
 // Helpers
 
 func (board *Board) setPiece(piece Piece, square Square) {
-	board.pieces[square] = piece
+	board.clearSquare(square)
+	index := squareIndex(square)
+	board.squares[index] = piece
+	board.bitboards.set(index, piece.Type(), piece.Color())
+	board.zobrist ^= zobristPiece(piece, index)
 }
 
 func (board *Board) clearSquare(square Square) {
-	delete(board.pieces, square)
+	if !board.ContainsSquare(square) {
+		return
+	}
+	index := squareIndex(square)
+	if piece := board.squares[index]; piece != nil {
+		board.zobrist ^= zobristPiece(piece, index)
+	}
+	board.bitboards.clear(index)
+	board.squares[index] = nil
 }
 
 func (board *Board) jumpPiece(start Square, end Square) {
@@ -58,10 +94,14 @@ func (board *Board) jumpPiece(start Square, end Square) {
 }
 
 func (board *Board) getKingSquare(color PieceColor) (*Square, bool) {
-	for square, piece := range board.pieces {
-		if k, ok := piece.(King); ok && k.Color() == color {
-			return &square, true
-		}
+	kings := board.pieces(PieceType_King, color)
+	if kings == 0 {
+		return nil, false
 	}
-	return nil, false
+	square := indexSquare(bits.TrailingZeros64(uint64(kings)))
+	return &square, true
+}
+
+func (board *Board) isSquareAttacked(square Square, by PieceColor) bool {
+	return board.isAttacked(squareIndex(square), by)
 }
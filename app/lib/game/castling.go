@@ -0,0 +1,119 @@
+package game
+
+import (
+	"slices"
+)
+
+// Wherever the king and rook start, castling leaves them on the same files as in
+// standard chess
+const (
+	castleKingSideKingFile  = 6
+	castleKingSideRookFile  = 5
+	castleQueenSideKingFile = 2
+	castleQueenSideRookFile = 3
+)
+
+// A king and rook which haven't moved and may still castle, when the way is clear
+type castlingRight struct {
+	color    PieceColor
+	kingSide bool
+	king     Square
+	rook     Square
+}
+
+func (r castlingRight) kingTarget() Square {
+	if r.kingSide {
+		return Square{File: castleKingSideKingFile, Rank: r.king.Rank}
+	}
+	return Square{File: castleQueenSideKingFile, Rank: r.king.Rank}
+}
+
+func (r castlingRight) rookTarget() Square {
+	if r.kingSide {
+		return Square{File: castleKingSideRookFile, Rank: r.king.Rank}
+	}
+	return Square{File: castleQueenSideRookFile, Rank: r.king.Rank}
+}
+
+// Index of the right among the four a position may have, in FEN order KQkq
+func (r castlingRight) index() int {
+	index := int(r.color) * 2
+	if !r.kingSide {
+		index++
+	}
+	return index
+}
+
+// The side's castling rights, king-side first. Rights belong to the unmoved rooks on
+// the unmoved king's rank, the outermost rook on each side if there are several.
+func (g *GameState) castlingRights(color PieceColor) []castlingRight {
+	king, ok := g.board.getKingSquare(color)
+	if !ok || !g.isUnmovedCastlingSquare(*king) {
+		return nil
+	}
+
+	var kingSide, queenSide *castlingRight
+	rooks := g.board.pieces(PieceType_Rook, color)
+	for rook := range rooks.Squares() {
+		if rook.Rank != king.Rank || !g.isUnmovedCastlingSquare(rook) {
+			continue
+		}
+		right := castlingRight{color: color, kingSide: rook.File > king.File, king: *king, rook: rook}
+		if right.kingSide && (kingSide == nil || rook.File > kingSide.rook.File) {
+			kingSide = &right
+		}
+		if !right.kingSide && (queenSide == nil || rook.File < queenSide.rook.File) {
+			queenSide = &right
+		}
+	}
+
+	var out []castlingRight
+	for _, right := range []*castlingRight{kingSide, queenSide} {
+		if right != nil {
+			out = append(out, *right)
+		}
+	}
+	return out
+}
+
+func (g *GameState) allCastlingRights() []castlingRight {
+	return append(g.castlingRights(PieceColor_White), g.castlingRights(PieceColor_Black)...)
+}
+
+func (g *GameState) isUnmovedCastlingSquare(square Square) bool {
+	status, tracked := g.castlingSquares[square]
+	return tracked && status == SquareMovementStatus_Unmoved
+}
+
+// The move castling with the right. Chess960 writes it as the king taking its own rook,
+// since the king may only move one square or not at all.
+func (g *GameState) castlingMove(right castlingRight) Move {
+	if g.variant == Variant_Chess960 {
+		return Move{From: right.king, To: right.rook}
+	}
+	return Move{From: right.king, To: right.kingTarget()}
+}
+
+// Returns the right a move castles with, if it is a castling move
+func (g *GameState) castlingRightOf(move Move) (*castlingRight, bool) {
+	piece, exists := g.board.GetPiece(move.From)
+	if !exists || piece.Type() != PieceType_King {
+		return nil, false
+	}
+	for _, right := range g.castlingRights(piece.Color()) {
+		if castle := g.castlingMove(right); castle.From == move.From && castle.To == move.To {
+			return &right, true
+		}
+	}
+	return nil, false
+}
+
+// Squares from a to b on the same rank, both included
+func squaresSpanning(a Square, b Square) Bitboard {
+	files := []int{a.File, b.File}
+	var span Bitboard
+	for file := slices.Min(files); file <= slices.Max(files); file++ {
+		span |= squareBit(Square{File: file, Rank: a.Rank})
+	}
+	return span
+}
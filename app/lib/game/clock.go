@@ -3,20 +3,37 @@ package game
 import "time"
 
 type Clock struct {
+	source      TimeSource
 	running     bool
 	restartTime time.Time
 	remaining   time.Duration
+	delay       time.Duration
+	delayMode   DelayMode
 }
 
 func NewClock(duration time.Duration) *Clock {
-	return &Clock{remaining: duration}
+	return NewClockWithTimeSource(duration, SystemTime)
+}
+
+func NewClockWithTimeSource(duration time.Duration, source TimeSource) *Clock {
+	return &Clock{source: source, remaining: duration}
+}
+
+// NewDelayClock creates a clock allowing the delay on each turn, applied as the mode says
+func NewDelayClock(duration time.Duration, mode DelayMode, delay time.Duration, source TimeSource) *Clock {
+	return &Clock{source: source, remaining: duration, delay: delay, delayMode: mode}
 }
 
 func (c *Clock) Start() {
+	c.startAt(c.source.Now())
+}
+
+// Starts the clock as if at the time given, which may have passed
+func (c *Clock) startAt(t time.Time) {
 	if c.running {
 		return
 	}
-	c.restartTime = time.Now()
+	c.restartTime = t
 	c.running = true
 }
 
@@ -28,17 +45,48 @@ func (c *Clock) Increment(duration time.Duration) {
 	c.remaining += duration
 }
 
+// Changes the delay from the next turn on, as a new stage of the time control begins
+func (c *Clock) setDelay(mode DelayMode, delay time.Duration) {
+	c.delayMode = mode
+	c.delay = delay
+}
+
 func (c *Clock) RemainingTime() time.Duration {
 	if c.running {
-		return max(c.remaining-time.Now().Sub(c.restartTime), 0)
+		elapsed := c.source.Now().Sub(c.restartTime)
+		if c.delayMode == DelayMode_Simple {
+			elapsed = max(elapsed-c.delay, 0)
+		}
+		return max(c.remaining-elapsed, 0)
 	}
 	return max(c.remaining, 0)
 }
 
+// TimeUntilFlag returns how long until the clock runs out, which under simple delay
+// includes what is left of the delay
+func (c *Clock) TimeUntilFlag() time.Duration {
+	remaining := c.RemainingTime()
+	if c.running && c.delayMode == DelayMode_Simple {
+		remaining += max(c.delay-c.source.Now().Sub(c.restartTime), 0)
+	}
+	return remaining
+}
+
 func (c *Clock) Stop() {
 	if !c.running {
 		return
 	}
-	c.remaining -= time.Now().Sub(c.restartTime)
+	elapsed := c.source.Now().Sub(c.restartTime)
+	switch c.delayMode {
+	case DelayMode_Simple:
+		c.remaining -= max(elapsed-c.delay, 0)
+	case DelayMode_Bronstein:
+		// Nothing is given back once the flag has fallen
+		if c.remaining -= elapsed; c.remaining > 0 {
+			c.remaining += min(elapsed, c.delay)
+		}
+	default:
+		c.remaining -= elapsed
+	}
 	c.running = false
 }
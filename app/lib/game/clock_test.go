@@ -74,3 +74,107 @@ func TestClockStartStop(t *testing.T) {
 		}
 	})
 }
+
+func TestClockTimeSource(t *testing.T) {
+	source := NewFakeTimeSource(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := NewClockWithTimeSource(time.Minute, source)
+
+	c.Start()
+	source.Advance(45 * time.Second)
+	if got, want := c.RemainingTime(), 15*time.Second; got != want {
+		t.Errorf("RemainingTime() got %s, want %s", got, want)
+	}
+
+	source.Advance(time.Minute)
+	if got := c.RemainingTime(); got != 0 {
+		t.Errorf("RemainingTime() after flag fall got %s, want 0", got)
+	}
+}
+
+func TestClockDelay(t *testing.T) {
+	type step struct {
+		// Time spent with the clock running before it is stopped
+		think time.Duration
+		// Remaining time while still running, then once stopped
+		running time.Duration
+		stopped time.Duration
+		// Time until the flag falls while still running
+		untilFlag time.Duration
+	}
+	tests := map[string]struct {
+		mode  DelayMode
+		steps []step
+	}{
+		"No delay": {
+			mode: DelayMode_None,
+			steps: []step{
+				{think: 3 * time.Second, running: 57 * time.Second, stopped: 57 * time.Second, untilFlag: 57 * time.Second},
+				{think: 8 * time.Second, running: 49 * time.Second, stopped: 49 * time.Second, untilFlag: 49 * time.Second},
+			},
+		},
+		"Simple delay": {
+			mode: DelayMode_Simple,
+			steps: []step{
+				{think: 3 * time.Second, running: 60 * time.Second, stopped: 60 * time.Second, untilFlag: 62 * time.Second},
+				{think: 8 * time.Second, running: 57 * time.Second, stopped: 57 * time.Second, untilFlag: 57 * time.Second},
+				{think: 5 * time.Second, running: 57 * time.Second, stopped: 57 * time.Second, untilFlag: 57 * time.Second},
+			},
+		},
+		"Bronstein delay": {
+			mode: DelayMode_Bronstein,
+			steps: []step{
+				{think: 3 * time.Second, running: 57 * time.Second, stopped: 60 * time.Second, untilFlag: 57 * time.Second},
+				{think: 8 * time.Second, running: 52 * time.Second, stopped: 57 * time.Second, untilFlag: 52 * time.Second},
+				{think: 5 * time.Second, running: 52 * time.Second, stopped: 57 * time.Second, untilFlag: 52 * time.Second},
+			},
+		},
+	}
+
+	for title, test := range tests {
+		source := NewFakeTimeSource(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		c := NewDelayClock(time.Minute, test.mode, 5*time.Second, source)
+		for i, step := range test.steps {
+			c.Start()
+			source.Advance(step.think)
+			if got := c.RemainingTime(); got != step.running {
+				t.Errorf("%s: move %d: RemainingTime() while running got %s, want %s", title, i, got, step.running)
+			}
+			if got := c.TimeUntilFlag(); got != step.untilFlag {
+				t.Errorf("%s: move %d: TimeUntilFlag() got %s, want %s", title, i, got, step.untilFlag)
+			}
+			c.Stop()
+			if got := c.RemainingTime(); got != step.stopped {
+				t.Errorf("%s: move %d: RemainingTime() once stopped got %s, want %s", title, i, got, step.stopped)
+			}
+		}
+	}
+}
+
+func TestClockDelayFlagFall(t *testing.T) {
+	tests := map[string]struct {
+		mode DelayMode
+		// Time which may be spent on the move before the flag falls
+		untilFlag time.Duration
+	}{
+		"Simple delay":    {mode: DelayMode_Simple, untilFlag: 15 * time.Second},
+		"Bronstein delay": {mode: DelayMode_Bronstein, untilFlag: 10 * time.Second},
+	}
+
+	for title, test := range tests {
+		source := NewFakeTimeSource(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		c := NewDelayClock(10*time.Second, test.mode, 5*time.Second, source)
+		c.Start()
+		if got := c.TimeUntilFlag(); got != test.untilFlag {
+			t.Errorf("%s: TimeUntilFlag() got %s, want %s", title, got, test.untilFlag)
+		}
+		source.Advance(test.untilFlag - time.Millisecond)
+		if got := c.RemainingTime(); got <= 0 {
+			t.Errorf("%s: RemainingTime() just before the flag got %s, want time left", title, got)
+		}
+		source.Advance(time.Millisecond)
+		c.Stop()
+		if got := c.RemainingTime(); got != 0 {
+			t.Errorf("%s: RemainingTime() after flag fall got %s, want 0", title, got)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package game
+
+import (
+	"fmt"
+	"time"
+)
+
+// Correspondence games give each side days for every move instead of a clock for the game.
+// A side may go on vacation for up to the days the time control allows, during which its
+// clock is stopped and it can't move. The deadlines pass whether or not the game is being
+// watched, so a restored game charges the time since the turn began.
+
+// A vacation taken by a side in a correspondence game
+type Vacation struct {
+	Side  PieceColor `json:"side"`
+	Start time.Time  `json:"start"`
+	// Unset until the side returns or its vacation time runs out
+	End *time.Time `json:"end,omitempty"`
+}
+
+// StartVacation stops the side's clock until it returns. Vacations may only be taken in
+// correspondence games once they can no longer be aborted.
+func (g *Game) StartVacation(side PieceColor) error {
+	if !g.control.IsCorrespondence() {
+		return fmt.Errorf("game: can't take a vacation, not a correspondence game")
+	}
+	if !g.InProgress() || g.CanAbort() {
+		return fmt.Errorf("game: can't take a vacation before both sides have moved or once the game is over")
+	}
+	if g.OnVacation(side) {
+		return fmt.Errorf("game: already on vacation")
+	}
+	if g.VacationLeft(side) <= 0 {
+		return fmt.Errorf("game: no vacation time left")
+	}
+	g.vacations = append(g.vacations, Vacation{Side: side, Start: g.source.Now()})
+	g.clocks[side].Stop()
+	return nil
+}
+
+// EndVacation returns the side from vacation, restarting its clock if it is on move. A
+// vacation overrunning the time left ends when it ran out.
+func (g *Game) EndVacation(side PieceColor) error {
+	vacation := g.currentVacation(side)
+	if vacation == nil {
+		return fmt.Errorf("game: not on vacation")
+	}
+	now := g.source.Now()
+	end := now.Add(-max(g.vacationTaken(side, now)-g.control.VacationTime(), 0))
+	vacation.End = &end
+	if g.InProgress() && g.MovingSide() == side {
+		g.clocks[side].startAt(end)
+	}
+	return nil
+}
+
+func (g *Game) OnVacation(side PieceColor) bool {
+	return g.currentVacation(side) != nil
+}
+
+// VacationLeft returns how much of its vacation time the side has yet to take
+func (g *Game) VacationLeft(side PieceColor) time.Duration {
+	return max(g.control.VacationTime()-g.vacationTaken(side, g.source.Now()), 0)
+}
+
+// Vacations returns the vacations taken so far, in the order they began
+func (g *Game) Vacations() []Vacation {
+	return g.vacations
+}
+
+// TimeUntilVacationEnds returns how long until the first side on vacation runs out of
+// vacation time, when EndVacation should be called for it
+func (g *Game) TimeUntilVacationEnds() (PieceColor, time.Duration, bool) {
+	var side PieceColor
+	var until time.Duration
+	found := false
+	for _, color := range []PieceColor{PieceColor_White, PieceColor_Black} {
+		if !g.OnVacation(color) {
+			continue
+		}
+		if left := g.VacationLeft(color); !found || left < until {
+			side, until, found = color, left, true
+		}
+	}
+	return side, until, found
+}
+
+// RestoreCorrespondence resumes a restored correspondence game whose side to move began its
+// turn at turnStart, with the vacations taken. Unlike over-the-board games, the side to move
+// is charged for the time since, less any of it spent on vacation.
+func (g *Game) RestoreCorrespondence(turnStart time.Time, vacations []Vacation) {
+	now := g.source.Now()
+	g.vacations = nil
+	for _, vacation := range vacations {
+		// A vacation still open when its time ran out ended then
+		if vacation.End == nil {
+			if end := vacation.Start.Add(g.VacationLeft(vacation.Side)); end.Before(now) {
+				vacation.End = &end
+			}
+		}
+		g.vacations = append(g.vacations, vacation)
+	}
+
+	side := g.MovingSide()
+	clock := g.clocks[side]
+	running := clock.Running()
+	clock.Stop()
+	elapsed := now.Sub(turnStart)
+	for _, vacation := range g.vacations {
+		if vacation.Side != side {
+			continue
+		}
+		if start, end := later(vacation.Start, turnStart), vacation.until(now); end.After(start) {
+			elapsed -= end.Sub(start)
+		}
+	}
+	clock.remaining -= elapsed
+	g.turnStartTime = turnStart
+	if running && !g.OnVacation(side) {
+		clock.Start()
+	}
+	g.result, _ = g.computeResult()
+}
+
+// Helpers
+
+func (g *Game) currentVacation(side PieceColor) *Vacation {
+	for i := range g.vacations {
+		if vacation := &g.vacations[i]; vacation.Side == side && vacation.End == nil {
+			return vacation
+		}
+	}
+	return nil
+}
+
+// Vacation time the side has taken by now, which may overrun what it had
+func (g *Game) vacationTaken(side PieceColor, now time.Time) time.Duration {
+	var taken time.Duration
+	for _, vacation := range g.vacations {
+		if vacation.Side == side {
+			taken += vacation.until(now).Sub(vacation.Start)
+		}
+	}
+	return taken
+}
+
+// When the vacation ended, or now if it hasn't
+func (v *Vacation) until(now time.Time) time.Time {
+	if v.End != nil {
+		return *v.End
+	}
+	return now
+}
+
+func later(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
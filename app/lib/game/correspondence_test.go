@@ -0,0 +1,178 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// Plays the knights out and back, each move taking the time given
+func playCorrespondenceMoves(t *testing.T, g *Game, source *FakeTimeSource, n int, thinking time.Duration) {
+	t.Helper()
+	cycle := []Move{
+		{From: sq("g1"), To: sq("f3")},
+		{From: sq("g8"), To: sq("f6")},
+		{From: sq("f3"), To: sq("g1")},
+		{From: sq("f6"), To: sq("g8")},
+	}
+	for i := range n {
+		source.Advance(thinking)
+		if err := g.Move(cycle[len(g.moves)%len(cycle)]); err != nil {
+			t.Fatalf("move %d failed unexpectedly: %v", i, err)
+		}
+	}
+}
+
+func TestCorrespondenceClocks(t *testing.T) {
+	source := NewFakeTimeSource(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	g := NewGameWithTimeSource(TimeControl_ThreeDays, NewGameState(), source)
+	g.Start()
+
+	// Every move has the full three days, however long the last took
+	playCorrespondenceMoves(t, g, source, 3, 2*day)
+	if got, want := g.RemainingTime(PieceColor_White), 3*day; got != want {
+		t.Errorf("white remaining got %s, want %s", got, want)
+	}
+	if got, want := g.RemainingTime(PieceColor_Black), 3*day; got != want {
+		t.Errorf("black remaining got %s, want %s", got, want)
+	}
+
+	source.Advance(2 * day)
+	if got, ok := g.TimeUntilFlag(); !ok || got != day {
+		t.Errorf("TimeUntilFlag() got %s, %t, want %s, true", got, ok, day)
+	}
+	source.Advance(day)
+	result, ended := g.Result()
+	if !ended || result.Result != GameResult_Timeout || *result.Winner != PieceColor_White {
+		t.Errorf("Result() got %+v, %t, want a timeout won by white", result, ended)
+	}
+}
+
+func TestVacation(t *testing.T) {
+	source := NewFakeTimeSource(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	g := NewGameWithTimeSource(TimeControl_ThreeDays, NewGameState(), source)
+	g.Start()
+	if err := g.StartVacation(PieceColor_White); err == nil {
+		t.Errorf("StartVacation() before both sides moved succeeded unexpectedly")
+	}
+	playCorrespondenceMoves(t, g, source, 2, time.Hour)
+
+	// White's clock stops while away, and white can't move
+	source.Advance(day)
+	if err := g.StartVacation(PieceColor_White); err != nil {
+		t.Fatalf("StartVacation() failed unexpectedly: %v", err)
+	}
+	if err := g.StartVacation(PieceColor_White); err == nil {
+		t.Errorf("StartVacation() while on vacation succeeded unexpectedly")
+	}
+	source.Advance(5 * day)
+	if _, ok := g.TimeUntilFlag(); ok {
+		t.Errorf("TimeUntilFlag() reported a running clock while on vacation")
+	}
+	if side, got, ok := g.TimeUntilVacationEnds(); !ok || side != PieceColor_White || got != 9*day {
+		t.Errorf("TimeUntilVacationEnds() got %d, %s, %t, want white, %s, true", side, got, ok, 9*day)
+	}
+	if err := g.Move(Move{From: sq("e2"), To: sq("e4")}); err == nil {
+		t.Errorf("Move() while on vacation succeeded unexpectedly")
+	}
+
+	if err := g.EndVacation(PieceColor_White); err != nil {
+		t.Fatalf("EndVacation() failed unexpectedly: %v", err)
+	}
+	if got, want := g.RemainingTime(PieceColor_White), 2*day; got != want {
+		t.Errorf("white remaining got %s, want %s", got, want)
+	}
+	if got, want := g.VacationLeft(PieceColor_White), 9*day; got != want {
+		t.Errorf("white vacation left got %s, want %s", got, want)
+	}
+
+	// Overrunning the vacation time left ends the vacation when it ran out
+	playCorrespondenceMoves(t, g, source, 1, time.Hour)
+	if err := g.StartVacation(PieceColor_White); err != nil {
+		t.Fatalf("StartVacation() failed unexpectedly: %v", err)
+	}
+	playCorrespondenceMoves(t, g, source, 1, time.Hour)
+	source.Advance(10 * day)
+	if err := g.EndVacation(PieceColor_White); err != nil {
+		t.Fatalf("EndVacation() failed unexpectedly: %v", err)
+	}
+	if got := g.VacationLeft(PieceColor_White); got != 0 {
+		t.Errorf("white vacation left got %s, want none", got)
+	}
+	if got, want := g.RemainingTime(PieceColor_White), 3*day-day-time.Hour; got != want {
+		t.Errorf("white remaining after overrun got %s, want %s", got, want)
+	}
+	if err := g.StartVacation(PieceColor_White); err == nil {
+		t.Errorf("StartVacation() without vacation time left succeeded unexpectedly")
+	}
+}
+
+func TestVacationOutsideCorrespondence(t *testing.T) {
+	g := NewGame(TimeControl_Hour)
+	g.Start()
+	if err := g.StartVacation(PieceColor_White); err == nil {
+		t.Errorf("StartVacation() in a game against the clock succeeded unexpectedly")
+	}
+}
+
+func TestRestoreCorrespondence(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		vacations []Vacation
+		remaining time.Duration
+		// Whether white is still on vacation
+		away bool
+	}{
+		"No vacation": {
+			remaining: day,
+		},
+		"Vacation during the turn": {
+			vacations: []Vacation{{Side: PieceColor_White, Start: now.Add(-36 * time.Hour), End: timePtr(now.Add(-12 * time.Hour))}},
+			remaining: 2 * day,
+		},
+		"Vacation before the turn": {
+			vacations: []Vacation{{Side: PieceColor_White, Start: now.Add(-4 * day), End: timePtr(now.Add(-3 * day))}},
+			remaining: day,
+		},
+		"Opponent's vacation": {
+			vacations: []Vacation{{Side: PieceColor_Black, Start: now.Add(-36 * time.Hour)}},
+			remaining: day,
+		},
+		"Still on vacation": {
+			vacations: []Vacation{{Side: PieceColor_White, Start: now.Add(-day)}},
+			remaining: 2 * day,
+			away:      true,
+		},
+		"Vacation ran out": {
+			vacations: []Vacation{
+				{Side: PieceColor_White, Start: now.Add(-30 * day), End: timePtr(now.Add(-17 * day))},
+				{Side: PieceColor_White, Start: now.Add(-60 * time.Hour)},
+			},
+			remaining: day + 12*time.Hour,
+		},
+	}
+
+	for title, test := range tests {
+		source := NewFakeTimeSource(now)
+		g := NewGameWithTimeSource(TimeControl_ThreeDays, NewGameState(), source)
+		g.Start()
+		playCorrespondenceMoves(t, g, source, 2, 0)
+
+		// White's turn began two days before the restart
+		g.RestoreCorrespondence(now.Add(-2*day), test.vacations)
+		if got := g.RemainingTime(PieceColor_White); got != test.remaining {
+			t.Errorf("%s: white remaining got %s, want %s", title, got, test.remaining)
+		}
+		if got := g.OnVacation(PieceColor_White); got != test.away {
+			t.Errorf("%s: OnVacation() got %t, want %t", title, got, test.away)
+		}
+		if _, running := g.TimeUntilFlag(); running == test.away {
+			t.Errorf("%s: TimeUntilFlag() got running %t, want %t", title, running, !test.away)
+		}
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,126 @@
+package game
+
+import (
+	"math/bits"
+)
+
+// A position is dead when no sequence of legal moves leads to either side checkmating the
+// other, however cooperative the opponent. The game is drawn then, and a player who runs
+// out of time only loses if the opponent could still checkmate them.
+
+const (
+	// Plies searched for a helpmate before mate is assumed possible
+	helpmateSearchDepth = 6
+	// Positions searched at most, past which mate is likewise assumed possible
+	helpmateSearchNodes = 2000
+)
+
+// CanCheckmate reports whether some sequence of legal moves ends with the side checkmating
+// its opponent. It errs towards mate being possible when the search for one runs out.
+func (g *GameState) CanCheckmate(color PieceColor) bool {
+	budget := helpmateSearchNodes
+	return g.canHelpmate(color, helpmateSearchDepth, &budget)
+}
+
+// IsDeadPosition reports whether neither side can checkmate the other by any sequence of
+// legal moves
+func (g *GameState) IsDeadPosition() bool {
+	return !g.CanCheckmate(PieceColor_White) && !g.CanCheckmate(PieceColor_Black)
+}
+
+// Helpers
+
+// Searches the moves of both sides for a line ending in mate by the side. Lines still going
+// at the depth or once the budget is spent count as mating, so the position is only found
+// dead once every line has been seen to end without mate.
+func (g *GameState) canHelpmate(color PieceColor, depth int, budget *int) bool {
+	if !g.hasMatingMaterial(color) || g.isPawnFortress() {
+		return false
+	}
+	side := g.MovingSide()
+	moves := g.LegalMoves(side)
+	if len(moves) == 0 {
+		return side != color && g.IsSideInCheck(side)
+	}
+	if depth == 0 || *budget <= 0 {
+		return true
+	}
+	*budget--
+	for _, move := range moves {
+		if g.applyMove(move).canHelpmate(color, depth-1, budget) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the side has the pieces to ever checkmate, given what both sides have left. A lone
+// king can't, nor a lone knight against a bare king, nor any number of bishops when every
+// piece besides the kings is a bishop on the same colour of square.
+func (g *GameState) hasMatingMaterial(color PieceColor) bool {
+	b := &g.board.bitboards
+	kings := b.types[PieceType_King]
+	// No king
+	if b.pieces(PieceType_King, color) == 0 {
+		return false
+	}
+
+	own := b.colors[color] &^ kings
+	others := b.occupied() &^ kings
+	if own == 0 {
+		return false
+	}
+	if own == b.pieces(PieceType_Knight, color) && own.Count() == 1 && others == own {
+		return false
+	}
+	if others == b.types[PieceType_Bishop] {
+		var colors [2]bool
+		for square := range others.Squares() {
+			colors[square.Color()] = true
+		}
+		return colors[SquareColor_White] && colors[SquareColor_Black]
+	}
+	return true
+}
+
+// Whether only kings and pawns are left with every pawn rammed by an enemy pawn, nothing for
+// the pawns to capture and neither king able to reach a pawn it could take. Nothing can then
+// ever give check.
+func (g *GameState) isPawnFortress() bool {
+	b := &g.board.bitboards
+	pawns := b.types[PieceType_Pawn]
+	if pawns == 0 || b.occupied() != pawns|b.types[PieceType_King] || g.enpassantTarget != nil {
+		return false
+	}
+
+	var attacked [2]Bitboard
+	for _, color := range []PieceColor{PieceColor_White, PieceColor_Black} {
+		enemyPawns := b.pieces(PieceType_Pawn, color.Opponent())
+		for square := range b.pieces(PieceType_Pawn, color).Squares() {
+			index := squareIndex(square)
+			if !enemyPawns.Has(square.Adding(pawnDelta[color])) || pawnAttackTable[color][index]&enemyPawns != 0 {
+				return false
+			}
+			attacked[color] |= pawnAttackTable[color][index]
+		}
+	}
+
+	for _, color := range []PieceColor{PieceColor_White, PieceColor_Black} {
+		// Squares the king may walk to, counting those of enemy pawns it could take
+		open := ^(b.pieces(PieceType_Pawn, color) | attacked[color.Opponent()])
+		king := b.pieces(PieceType_King, color)
+		reached := king
+		for frontier := king; frontier != 0; {
+			var next Bitboard
+			for rest := frontier; rest != 0; rest &= rest - 1 {
+				next |= kingAttackTable[bits.TrailingZeros64(uint64(rest))]
+			}
+			frontier = next & open &^ reached
+			reached |= frontier
+		}
+		if reached&b.pieces(PieceType_Pawn, color.Opponent()) != 0 {
+			return false
+		}
+	}
+	return true
+}
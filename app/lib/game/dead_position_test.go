@@ -0,0 +1,102 @@
+package game
+
+import (
+	"testing"
+)
+
+func TestDeadPosition(t *testing.T) {
+	tests := map[string]struct {
+		fen  string
+		dead bool
+		// Sides which can still checkmate
+		mating []PieceColor
+	}{
+		"Starting position": {
+			fen:    "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+			mating: []PieceColor{PieceColor_White, PieceColor_Black},
+		},
+		"Bare kings": {
+			fen:  "4k3/8/8/8/8/8/8/4K3 w - - 0 1",
+			dead: true,
+		},
+		"Lone knight": {
+			fen:  "4k3/8/8/8/8/8/8/4KN2 w - - 0 1",
+			dead: true,
+		},
+		"Two knights": {
+			fen:    "4k3/8/8/8/8/8/8/3NKN2 w - - 0 1",
+			mating: []PieceColor{PieceColor_White},
+		},
+		"Knight against a pawn": {
+			fen:    "4k3/4p3/8/8/8/8/8/4KN2 w - - 0 1",
+			mating: []PieceColor{PieceColor_White, PieceColor_Black},
+		},
+		"Bishops all on one square colour": {
+			fen:  "2b1k3/8/8/8/8/8/8/1B1BK3 w - - 0 1",
+			dead: true,
+		},
+		"Bishops on both square colours": {
+			fen:    "1b2k3/8/8/8/8/8/8/4KB2 w - - 0 1",
+			mating: []PieceColor{PieceColor_White, PieceColor_Black},
+		},
+		"Locked pawns the kings can't reach": {
+			fen:  "8/4k3/8/p1p1p1p1/P1P1P1P1/8/4K3/8 w - - 0 1",
+			dead: true,
+		},
+		"Locked pawns with a gap for the king": {
+			fen:    "8/4k3/8/p1p3p1/P1P3P1/8/4K3/8 w - - 0 1",
+			mating: []PieceColor{PieceColor_White, PieceColor_Black},
+		},
+		"Locked pawns with a capture": {
+			fen:    "8/4k3/8/p1pp2p1/P1P1P1P1/8/4K3/8 w - - 0 1",
+			mating: []PieceColor{PieceColor_White, PieceColor_Black},
+		},
+		"Free pawn": {
+			fen:    "8/4k3/8/p1p1p3/P1P1P1P1/8/4K3/8 w - - 0 1",
+			mating: []PieceColor{PieceColor_White, PieceColor_Black},
+		},
+		"Only legal move takes the rook": {
+			fen:  "8/8/8/8/8/3k4/1r6/K7 w - - 0 1",
+			dead: true,
+		},
+		"Rook out of reach": {
+			fen:    "8/8/8/8/8/3k4/7r/K7 w - - 0 1",
+			mating: []PieceColor{PieceColor_Black},
+		},
+	}
+
+	for title, test := range tests {
+		state := MustParseFEN(test.fen)
+		if dead := state.IsDeadPosition(); dead != test.dead {
+			t.Errorf("%s: IsDeadPosition() = %t, want %t", title, dead, test.dead)
+		}
+		for _, color := range []PieceColor{PieceColor_White, PieceColor_Black} {
+			want := false
+			for _, mating := range test.mating {
+				want = want || mating == color
+			}
+			if got := state.CanCheckmate(color); got != want {
+				t.Errorf("%s: CanCheckmate(%d) = %t, want %t", title, color, got, want)
+			}
+		}
+	}
+}
+
+func TestDrawByDeadPosition(t *testing.T) {
+	g := NewGameWithState(TimeControl_Thirty, MustParseFEN("8/8/8/8/8/2k5/1r6/K7 b - - 0 1"))
+	g.Start()
+	if err := g.Move(Move{From: sq("c3"), To: sq("d3")}); err != nil {
+		t.Fatalf("Move() failed unexpectedly: %v", err)
+	}
+	result, ended := g.Result()
+	if !ended || result.Result != GameResult_Draw || result.DrawReason != DrawReason_DeadPosition {
+		t.Errorf("got result %+v, want a draw by dead position", result)
+	}
+}
+
+func BenchmarkDeadPosition(b *testing.B) {
+	state := MustParseFEN("r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4")
+	for i := 0; i < b.N; i++ {
+		state.IsDeadPosition()
+	}
+}
@@ -0,0 +1,346 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const FEN_StartingPosition = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+type fenCastlingRight struct {
+	color  PieceColor
+	symbol rune
+	king   Square
+	rook   Square
+}
+
+// Castling rights of standard chess in the order they're written in a FEN record
+var fenCastlingRights = []fenCastlingRight{
+	{PieceColor_White, 'K', Square{File: 4, Rank: 0}, Square{File: 7, Rank: 0}},
+	{PieceColor_White, 'Q', Square{File: 4, Rank: 0}, Square{File: 0, Rank: 0}},
+	{PieceColor_Black, 'k', Square{File: 4, Rank: 7}, Square{File: 7, Rank: 7}},
+	{PieceColor_Black, 'q', Square{File: 4, Rank: 7}, Square{File: 0, Rank: 7}},
+}
+
+func MustParseFEN(fen string) *GameState {
+	g, err := ParseFEN(fen)
+	if err != nil {
+		panic(fmt.Sprintf("game: failed to parse invalid FEN %s: %v", fen, err))
+	}
+	return g
+}
+
+// ParseFEN builds a position from Forsyth-Edwards Notation. The halfmove clock and
+// fullmove number may be omitted, in which case they default to 0 and 1.
+func ParseFEN(fen string) (*GameState, error) {
+	return ParseVariantFEN(fen, Variant_Standard)
+}
+
+// ParseVariantFEN builds a position of the variant from FEN. Chess960 castling rights are
+// read as X-FEN, with KQkq for the outermost rooks and files for any others, which also
+// covers Shredder-FEN.
+func ParseVariantFEN(fen string, variant Variant) (*GameState, error) {
+	fields := strings.Fields(fen)
+	if len(fields) < 4 || len(fields) > 6 {
+		return nil, fmt.Errorf("fen: expected 4 to 6 fields, got %d", len(fields))
+	}
+
+	g := new(GameState)
+	g.variant = variant
+
+	board, err := parseFENBoard(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	g.board = board
+
+	var side PieceColor
+	switch fields[1] {
+	case "w":
+		side = PieceColor_White
+	case "b":
+		side = PieceColor_Black
+	default:
+		return nil, fmt.Errorf("fen: invalid side to move %q", fields[1])
+	}
+
+	castlingSquares, err := parseFENCastling(fields[2], board, variant)
+	if err != nil {
+		return nil, err
+	}
+	g.castlingSquares = castlingSquares
+
+	halfMoves, fullMoves := 0, 1
+	if len(fields) > 4 {
+		if halfMoves, err = strconv.Atoi(fields[4]); err != nil || halfMoves < 0 {
+			return nil, fmt.Errorf("fen: invalid halfmove clock %q", fields[4])
+		}
+	}
+	if len(fields) > 5 {
+		if fullMoves, err = strconv.Atoi(fields[5]); err != nil || fullMoves < 1 {
+			return nil, fmt.Errorf("fen: invalid fullmove number %q", fields[5])
+		}
+	}
+	g.numMoves = (fullMoves-1)*2 + int(side)
+	g.lastCaptureMove = g.numMoves - halfMoves
+	g.lastPawnMove = g.numMoves - halfMoves
+
+	enpassantTarget, err := parseFENEnPassant(fields[3], side, board)
+	if err != nil {
+		return nil, err
+	}
+	g.enpassantTarget = enpassantTarget
+
+	if g.IsSideInCheck(side.Opponent()) {
+		return nil, fmt.Errorf("fen: side not to move is in check")
+	}
+	g.hash = g.computeHash()
+
+	return g, nil
+}
+
+// FEN serializes the position into Forsyth-Edwards Notation. The en-passant square is
+// only written when a pawn is in position to make the capture.
+func (g *GameState) FEN() string {
+	fields := []string{
+		g.fenBoard(),
+		"w",
+		g.fenCastling(),
+		"-",
+		strconv.Itoa(g.numMoves - max(g.lastCaptureMove, g.lastPawnMove)),
+		strconv.Itoa(g.numMoves/2 + 1),
+	}
+	if g.MovingSide() == PieceColor_Black {
+		fields[1] = "b"
+	}
+	if g.enpassantTarget != nil {
+		pawnColor := g.MovingSide().Opponent()
+		fields[3] = g.enpassantTarget.Subtracting(pawnDelta[pawnColor]).String()
+	}
+	return strings.Join(fields, " ")
+}
+
+// Helpers
+
+func parseFENBoard(field string) (*Board, error) {
+	board := NewBoard()
+	ranks := strings.Split(field, "/")
+	if len(ranks) != board.NumRanks() {
+		return nil, fmt.Errorf("fen: expected %d ranks, got %d", board.NumRanks(), len(ranks))
+	}
+
+	kings := make(map[PieceColor]int)
+	for i, rankStr := range ranks {
+		rank := board.NumRanks() - 1 - i
+		file := 0
+		for _, ch := range rankStr {
+			if ch >= '1' && ch <= '8' {
+				file += int(ch - '0')
+				continue
+			}
+			t, ok := ParsePieceType(ch)
+			if !ok {
+				return nil, fmt.Errorf("fen: invalid piece %q", ch)
+			}
+			color := PieceColor_White
+			if unicode.IsLower(ch) {
+				color = PieceColor_Black
+			}
+			square := Square{File: file, Rank: rank}
+			if !board.ContainsSquare(square) {
+				return nil, fmt.Errorf("fen: rank %d has too many squares", rank+1)
+			}
+			if t == PieceType_Pawn && (rank == 0 || rank == board.NumRanks()-1) {
+				return nil, fmt.Errorf("fen: pawn on back rank %s", square)
+			}
+			if t == PieceType_King {
+				kings[color]++
+			}
+			board.setPiece(NewPiece(t, color), square)
+			file++
+		}
+		if file != board.NumFiles() {
+			return nil, fmt.Errorf("fen: rank %d has %d squares, want %d", rank+1, file, board.NumFiles())
+		}
+	}
+
+	if kings[PieceColor_White] != 1 || kings[PieceColor_Black] != 1 {
+		return nil, fmt.Errorf("fen: each side must have exactly one king")
+	}
+	return board, nil
+}
+
+func parseFENCastling(field string, board *Board, variant Variant) (map[Square]SquareMovementStatus, error) {
+	// Squares without a right are marked as moved since untracked squares default to unmoved
+	castlingSquares := make(map[Square]SquareMovementStatus)
+	for _, right := range fenCastlingRights {
+		castlingSquares[right.king] = SquareMovementStatus_Moved
+		castlingSquares[right.rook] = SquareMovementStatus_Moved
+	}
+	if field == "-" {
+		return castlingSquares, nil
+	}
+
+	seen := make(map[Square]bool)
+	for _, ch := range field {
+		var king, rook Square
+		if variant == Variant_Chess960 {
+			var err error
+			if king, rook, err = parseChess960CastlingRight(ch, board); err != nil {
+				return nil, err
+			}
+		} else {
+			var right *fenCastlingRight
+			for i := range fenCastlingRights {
+				if fenCastlingRights[i].symbol == ch {
+					right = &fenCastlingRights[i]
+				}
+			}
+			if right == nil {
+				return nil, fmt.Errorf("fen: invalid castling right %q", ch)
+			}
+			if !hasPiece(board, right.king, PieceType_King, right.color) ||
+				!hasPiece(board, right.rook, PieceType_Rook, right.color) {
+				return nil, fmt.Errorf("fen: castling right %c without king and rook in place", ch)
+			}
+			king, rook = right.king, right.rook
+		}
+		// The same right given twice, e.g. KKq, or as both KQ and file letters in X-FEN
+		if seen[rook] {
+			return nil, fmt.Errorf("fen: duplicate castling right %c", ch)
+		}
+		seen[rook] = true
+		castlingSquares[king] = SquareMovementStatus_Unmoved
+		castlingSquares[rook] = SquareMovementStatus_Unmoved
+	}
+	return castlingSquares, nil
+}
+
+// Finds the king and rook of an X-FEN castling right
+func parseChess960CastlingRight(ch rune, board *Board) (Square, Square, error) {
+	color, rank := PieceColor_White, 0
+	if unicode.IsLower(ch) {
+		color, rank = PieceColor_Black, board.NumRanks()-1
+	}
+	king, ok := board.getKingSquare(color)
+	if !ok || king.Rank != rank {
+		return Square{}, Square{}, fmt.Errorf("fen: castling right %c without king on its back rank", ch)
+	}
+
+	symbol := unicode.ToLower(ch)
+	var rook *Square
+	for file := 0; file < board.NumFiles(); file++ {
+		square := Square{File: file, Rank: rank}
+		if !hasPiece(board, square, PieceType_Rook, color) {
+			continue
+		}
+		switch {
+		case symbol == 'k' && file > king.File,
+			symbol == 'q' && file < king.File && rook == nil,
+			symbol == rune('a'+file) && file != king.File:
+			rook = &square
+		}
+	}
+	if rook == nil {
+		if symbol != 'k' && symbol != 'q' && (symbol < 'a' || symbol > 'h') {
+			return Square{}, Square{}, fmt.Errorf("fen: invalid castling right %q", ch)
+		}
+		return Square{}, Square{}, fmt.Errorf("fen: castling right %c without rook in place", ch)
+	}
+	return *king, *rook, nil
+}
+
+func parseFENEnPassant(field string, side PieceColor, board *Board) (*Square, error) {
+	if field == "-" {
+		return nil, nil
+	}
+	square, err := ParseSquare(field)
+	if err != nil || !board.ContainsSquare(*square) {
+		return nil, fmt.Errorf("fen: invalid en-passant square %q", field)
+	}
+
+	// The game tracks the pawn which made the double step rather than the square it skipped
+	pawnColor := side.Opponent()
+	target := square.Adding(pawnDelta[pawnColor])
+	if !hasPiece(board, target, PieceType_Pawn, pawnColor) {
+		return nil, fmt.Errorf("fen: no pawn in front of en-passant square %s", square)
+	}
+
+	doubleStep := Move{From: square.Subtracting(pawnDelta[pawnColor]), To: target}
+	if !NewPawn(pawnColor).isAttackedEnPassant(doubleStep, &GameState{board: board}) {
+		return nil, nil
+	}
+	return &target, nil
+}
+
+func hasPiece(board *Board, square Square, t PieceType, color PieceColor) bool {
+	piece, exists := board.GetPiece(square)
+	return exists && piece.Type() == t && piece.Color() == color
+}
+
+func (g *GameState) fenBoard() string {
+	var sb strings.Builder
+	board := g.Board()
+	for rank := board.NumRanks() - 1; rank >= 0; rank-- {
+		empty := 0
+		for file := 0; file < board.NumFiles(); file++ {
+			piece, exists := board.GetPiece(Square{File: file, Rank: rank})
+			if !exists {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			symbol := piece.Type().Symbol()
+			if piece.Color() == PieceColor_Black {
+				symbol = unicode.ToLower(symbol)
+			}
+			sb.WriteRune(symbol)
+		}
+		if empty > 0 {
+			sb.WriteString(strconv.Itoa(empty))
+		}
+		if rank > 0 {
+			sb.WriteRune('/')
+		}
+	}
+	return sb.String()
+}
+
+func (g *GameState) fenCastling() string {
+	var sb strings.Builder
+	for _, right := range g.allCastlingRights() {
+		symbol := 'Q'
+		if right.kingSide {
+			symbol = 'K'
+		}
+		// X-FEN names the rook's file when it is not the outermost one
+		if g.variant == Variant_Chess960 && !g.isOutermostRook(right) {
+			symbol = unicode.ToUpper(rune('a' + right.rook.File))
+		}
+		if right.color == PieceColor_Black {
+			symbol = unicode.ToLower(symbol)
+		}
+		sb.WriteRune(symbol)
+	}
+	if sb.Len() == 0 {
+		return "-"
+	}
+	return sb.String()
+}
+
+func (g *GameState) isOutermostRook(right castlingRight) bool {
+	for rook := range g.board.pieces(PieceType_Rook, right.color).Squares() {
+		if rook.Rank != right.rook.Rank {
+			continue
+		}
+		if (right.kingSide && rook.File > right.rook.File) || (!right.kingSide && rook.File < right.rook.File) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package game
+
+import (
+	"testing"
+)
+
+var fenCorpus = map[string]string{
+	"Starting position": FEN_StartingPosition,
+	"Kiwipete":          "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	"Rook endgame":      "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	"Partial castling":  "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	"Halfmove clock":    "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+	"Middlegame":        "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10",
+	"White en-passant":  "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+	"Black en-passant":  "rnbqkbnr/pppp1ppp/8/8/3Pp3/2N2N2/PPP1PPPP/R1BQKB1R b KQkq d3 0 3",
+	"Black to move":     "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b Kq - 17 40",
+}
+
+func TestFENRoundTrip(t *testing.T) {
+	for title, fen := range fenCorpus {
+		g, err := ParseFEN(fen)
+		if err != nil {
+			t.Errorf("%s: ParseFEN(%s) errored: %v", title, fen, err)
+			continue
+		}
+		if got := g.FEN(); got != fen {
+			t.Errorf("%s: FEN() got %s, want %s", title, got, fen)
+		}
+	}
+}
+
+func TestFENStartingPosition(t *testing.T) {
+	if got := NewGameState().FEN(); got != FEN_StartingPosition {
+		t.Errorf("FEN() of new game got %s, want %s", got, FEN_StartingPosition)
+	}
+}
+
+func TestFENAfterMoves(t *testing.T) {
+	tests := map[string]struct {
+		moves []testMove
+		want  string
+	}{
+		"En-passant square omitted without a capturing pawn": {
+			moves: []testMove{{"e2", "e4"}},
+			want:  "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1",
+		},
+		"En-passant square written with a capturing pawn": {
+			moves: []testMove{{"e2", "e4"}, {"g8", "f6"}, {"e4", "e5"}, {"d7", "d5"}},
+			want:  "rnbqkb1r/ppp1pppp/5n2/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3",
+		},
+		"Halfmove clock counts piece moves": {
+			moves: []testMove{{"g1", "f3"}, {"g8", "f6"}, {"b1", "c3"}},
+			want:  "rnbqkb1r/pppppppp/5n2/8/8/2N2N2/PPPPPPPP/R1BQKB1R b KQkq - 3 2",
+		},
+		"Castling rights lost when rook moves": {
+			moves: []testMove{{"h2", "h4"}, {"a7", "a5"}, {"h1", "h3"}, {"a8", "a6"}},
+			want:  "1nbqkbnr/1ppppppp/r7/p7/7P/7R/PPPPPPP1/RNBQKBN1 w Qk - 2 3",
+		},
+		"Castling rights lost when king moves": {
+			moves: []testMove{{"e2", "e4"}, {"e7", "e5"}, {"e1", "e2"}},
+			want:  "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPPKPPP/RNBQ1BNR b kq - 1 2",
+		},
+	}
+
+	for title, test := range tests {
+		g := playGame(title, test.moves, false, t)
+		if got := g.FEN(); got != test.want {
+			t.Errorf("%s: FEN() got %s, want %s", title, got, test.want)
+		}
+	}
+}
+
+func TestParseFENState(t *testing.T) {
+	g := MustParseFEN("rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 4 3")
+
+	if g.MovingSide() != PieceColor_White {
+		t.Errorf("MovingSide() got %v, want %v", g.MovingSide(), PieceColor_White)
+	}
+	if g.NumMoves() != 4 {
+		t.Errorf("NumMoves() got %d, want %d", g.NumMoves(), 4)
+	}
+	if g.lastCaptureMove != 0 || g.lastPawnMove != 0 {
+		t.Errorf("got last capture %d & pawn move %d, want 0", g.lastCaptureMove, g.lastPawnMove)
+	}
+	if g.enpassantTarget == nil || *g.enpassantTarget != sq("f5") {
+		t.Errorf("got en-passant target %v, want %s", g.enpassantTarget, "f5")
+	}
+
+	next, err := g.WithMove(Move{From: sq("e5"), To: sq("f6")})
+	if err != nil {
+		t.Fatalf("en-passant capture failed after parsing FEN: %v", err)
+	}
+	assertSquareEmpty("En-passant after FEN", "f5", next.Board(), t)
+
+	if _, err := g.WithMove(Move{From: sq("e1"), To: sq("g1")}); err == nil {
+		t.Errorf("castling through pieces was allowed after parsing FEN")
+	}
+}
+
+func TestParseFENCastlingRights(t *testing.T) {
+	g := MustParseFEN("r3k2r/8/8/8/8/8/8/R3K2R w Kq - 0 1")
+	assertMovePlanSquares("White castling rights from FEN", g.PlanPossibleMoves(sq("e1")),
+		[]string{"d1", "d2", "e2", "f2", "f1", "g1"}, t)
+
+	g = MustParseFEN("r3k2r/8/8/8/8/8/8/R3K2R b Kq - 0 1")
+	assertMovePlanSquares("Black castling rights from FEN", g.PlanPossibleMoves(sq("e8")),
+		[]string{"d8", "d7", "e7", "f7", "f8", "c8"}, t)
+}
+
+func TestParseFENNormalizesEnPassant(t *testing.T) {
+	g := MustParseFEN("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1")
+	want := "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"
+	if got := g.FEN(); got != want {
+		t.Errorf("FEN() got %s, want %s", got, want)
+	}
+}
+
+func TestParseFENDefaultsCounters(t *testing.T) {
+	g := MustParseFEN("4k3/8/8/8/8/8/8/4K3 b - -")
+	want := "4k3/8/8/8/8/8/8/4K3 b - - 0 1"
+	if got := g.FEN(); got != want {
+		t.Errorf("FEN() got %s, want %s", got, want)
+	}
+}
+
+func TestParseFENRejectsInvalid(t *testing.T) {
+	invalid := map[string]string{
+		"Too few fields":           "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq",
+		"Too few ranks":            "rnbqkbnr/pppppppp/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"Rank too long":            "rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"Rank too short":           "rnbqkbnr/ppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"Invalid piece":            "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNX w KQkq - 0 1",
+		"Missing king":             "rnbq1bnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQ - 0 1",
+		"Pawn on back rank":        "rnbqkbnP/pppppppp/8/8/8/8/PPPPPPP1/RNBQKBNR w KQq - 0 1",
+		"Invalid side":             "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1",
+		"Invalid castling right":   "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkx - 0 1",
+		"Castling without rook":    "rnbqkbn1/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"Duplicate castling right": "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KKq - 0 1",
+		"En-passant without pawn":  "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq e3 0 1",
+		"Negative halfmove clock":  "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - -1 1",
+		"Zero fullmove number":     "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 0",
+		"Opponent in check":        "4k3/8/8/8/8/8/4R3/4K3 w - - 0 1",
+	}
+
+	for title, fen := range invalid {
+		if _, err := ParseFEN(fen); err == nil {
+			t.Errorf("%s: ParseFEN(%s) was accepted", title, fen)
+		}
+	}
+}
@@ -2,16 +2,40 @@ package game
 
 import (
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 )
 
 type Game struct {
+	initial          *GameState
 	state            *GameState
-	repititionHashes map[string]int
+	repititionHashes map[uint64]int
 	control          TimeControl
+	source           TimeSource
 	clocks           map[PieceColor]*Clock
 	started          bool
-	result           *ResultData
-	moves            []Move
+	startTime        time.Time
+	// When the side to move's turn began
+	turnStartTime time.Time
+	result        *ResultData
+	drawPolicy    DrawPolicy
+	moves         []Move
+	sanMoves      []string
+	// How the game stood when each move was made, to take moves back
+	history []turn
+	// Which sides could still checkmate in the position last checked, as finding out may
+	// take a search
+	matingState *GameState
+	mating      [2]bool
+	// Vacations taken in a correspondence game, in the order they began
+	vacations []Vacation
+}
+
+// The position and clocks as they were when a move was made
+type turn struct {
+	state     *GameState
+	remaining map[PieceColor]time.Duration
 }
 
 type Move struct {
@@ -32,6 +56,8 @@ const (
 	GameResult_Checkmate
 	GameResult_Timeout
 	GameResult_Resigned
+	// Called off before both sides moved, counting for neither
+	GameResult_Aborted
 )
 
 type DrawReason int
@@ -44,8 +70,46 @@ const (
 	DrawReason_50Moves
 	DrawReason_InusfficientMaterialTimeout
 	DrawReason_Agreement
+	// Ended without a claim, the position having occurred five times or 75 moves having
+	// been played without a capture or pawn move
+	DrawReason_5FoldRepetition
+	DrawReason_75Moves
+	// Neither side can checkmate by any sequence of legal moves, despite having the material
+	DrawReason_DeadPosition
 )
 
+// DrawPolicy decides whether threefold repetition and the 50-move rule end the game by
+// themselves or only once claimed
+type DrawPolicy int
+
+const (
+	// The game is drawn as soon as either rule applies, as casual games prefer
+	DrawPolicy_Automatic DrawPolicy = iota
+	// As under FIDE rules, the player on move may claim the draw, and only fivefold
+	// repetition and the 75-move rule end the game by themselves
+	DrawPolicy_Claimable
+)
+
+func (p DrawPolicy) String() string {
+	switch p {
+	case DrawPolicy_Claimable:
+		return "claimable"
+	default:
+		return "automatic"
+	}
+}
+
+func ParseDrawPolicy(name string) (DrawPolicy, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "automatic":
+		return DrawPolicy_Automatic, true
+	case "claimable", "fide":
+		return DrawPolicy_Claimable, true
+	default:
+		return DrawPolicy_Automatic, false
+	}
+}
+
 type ResultData struct {
 	Result     Result      `json:"result"`
 	DrawReason DrawReason  `json:"draw_reason,omitempty"`
@@ -54,21 +118,38 @@ type ResultData struct {
 
 const (
 	drawRepetitionMoveCount = 3
+	autoDrawRepetitionCount = 5
 
 	// For draw purposes a 'move' consists of a player completing a turn followed by the opponent completing a turn
-	drawMoveCount = 50 * 2
+	drawMoveCount     = 50 * 2
+	autoDrawMoveCount = 75 * 2
 )
 
 func NewGame(control TimeControl) *Game {
+	return NewGameWithState(control, NewGameState())
+}
+
+// NewGameWithState creates a game starting from the position, e.g. a Chess960 setup
+func NewGameWithState(control TimeControl, state *GameState) *Game {
+	return NewGameWithTimeSource(control, state, SystemTime)
+}
+
+// NewGameWithTimeSource creates a game whose clocks run on the time source
+func NewGameWithTimeSource(control TimeControl, state *GameState, source TimeSource) *Game {
+	first := control.Stages()[0]
 	return &Game{
-		state:            NewGameState(),
-		repititionHashes: make(map[string]int),
+		initial: state,
+		state:   state,
+		// The position the game starts from counts towards repetitions like any other
+		repititionHashes: map[uint64]int{state.Hash(): 1},
 		control:          control,
+		source:           source,
 		clocks: map[PieceColor]*Clock{
-			PieceColor_White: NewClock(control.Total),
-			PieceColor_Black: NewClock(control.Total),
+			PieceColor_White: NewDelayClock(first.Time, first.DelayMode, first.Delay, source),
+			PieceColor_Black: NewDelayClock(first.Time, first.DelayMode, first.Delay, source),
 		},
-		moves: []Move{},
+		moves:    []Move{},
+		sanMoves: []string{},
 	}
 }
 
@@ -77,6 +158,8 @@ func (g *Game) Start() {
 		return
 	}
 	g.started = true
+	g.startTime = g.source.Now()
+	g.turnStartTime = g.startTime
 	g.clocks[g.state.MovingSide()].Start()
 }
 
@@ -84,24 +167,85 @@ func (g *Game) Move(move Move) error {
 	if !g.InProgress() {
 		return fmt.Errorf("game: game is not in progress, can not move")
 	}
+	if g.OnVacation(g.MovingSide()) {
+		return fmt.Errorf("game: can't move while on vacation")
+	}
 	state, err := g.state.WithMove(move)
 	if err != nil {
 		return err
 	}
+	before := turn{state: g.state, remaining: make(map[PieceColor]time.Duration)}
+	for color, clock := range g.clocks {
+		before.remaining[color] = clock.RemainingTime()
+	}
 	if err = g.toggleClocks(); err != nil {
 		return err
 	}
+	san := g.state.SAN(MovePlan{Move: move, Game: state})
 	g.state = state
-	hash := state.repititionHashString()
-	g.repititionHashes[hash] = g.repititionHashes[hash] + 1
+	g.repititionHashes[state.Hash()]++
 
 	g.result, _ = g.computeResult()
 
 	g.moves = append(g.moves, move)
+	g.sanMoves = append(g.sanMoves, san)
+	g.history = append(g.history, before)
+	g.turnStartTime = g.source.Now()
 
 	return nil
 }
 
+// Takeback undoes the last n moves, as agreed by the players. The position and both clocks
+// go back to how they stood when the earliest of the moves was made.
+func (g *Game) Takeback(n int) error {
+	if !g.InProgress() {
+		return fmt.Errorf("game: can't take back moves, game not in progress")
+	}
+	if n <= 0 || n > len(g.moves) {
+		return fmt.Errorf("game: can't take back %d moves of %d", n, len(g.moves))
+	}
+
+	var restored turn
+	for range n {
+		hash := g.state.Hash()
+		if g.repititionHashes[hash]--; g.repititionHashes[hash] <= 0 {
+			delete(g.repititionHashes, hash)
+		}
+		restored = g.history[len(g.history)-1]
+		g.state = restored.state
+		g.history = g.history[:len(g.history)-1]
+	}
+	// Clipped so later moves don't overwrite those in earlier snapshots
+	remaining := len(g.moves) - n
+	g.moves = slices.Clip(g.moves[:remaining])
+	g.sanMoves = slices.Clip(g.sanMoves[:remaining])
+
+	// Taken back over a stage boundary, the delay is again that of the earlier stage
+	stages := g.control.Stages()
+	for color, clock := range g.clocks {
+		clock.Stop()
+		clock.remaining = restored.remaining[color]
+		stage := stages[g.Stage(color)]
+		clock.setDelay(stage.DelayMode, stage.Delay)
+	}
+	if !g.OnVacation(g.state.MovingSide()) {
+		g.clocks[g.state.MovingSide()].Start()
+	}
+	g.turnStartTime = g.source.Now()
+
+	g.result, _ = g.computeResult()
+	return nil
+}
+
+// ParseSAN resolves Standard Algebraic Notation into a legal move in the current position
+func (g *Game) ParseSAN(san string) (Move, error) {
+	plan, err := g.state.ParseSAN(san)
+	if err != nil {
+		return Move{}, err
+	}
+	return plan.Move, nil
+}
+
 func (game *Game) Result() (*ResultData, bool) {
 	if game.result == nil {
 		game.result, _ = game.computeResult()
@@ -128,6 +272,32 @@ func (game *Game) AgreeDraw() error {
 	return nil
 }
 
+// CanClaimDraw reports whether the player on move may claim a draw, and under which rule.
+// Only games with the claimable draw policy have draws to claim.
+func (game *Game) CanClaimDraw() (DrawReason, bool) {
+	if !game.InProgress() || game.drawPolicy != DrawPolicy_Claimable {
+		return DrawReason_None, false
+	}
+	if game.hasReached3FoldRepetition(game, game.MovingSide()) {
+		return DrawReason_3FoldRepetition, true
+	} else if game.qualifiesFor50MoveRule(game.state, game.MovingSide()) {
+		return DrawReason_50Moves, true
+	}
+	return DrawReason_None, false
+}
+
+func (game *Game) ClaimDraw() error {
+	reason, ok := game.CanClaimDraw()
+	if !ok {
+		return fmt.Errorf("game: can't claim draw, neither threefold repetition nor the 50-move rule applies")
+	}
+	game.result = &ResultData{
+		Result:     GameResult_Draw,
+		DrawReason: reason,
+	}
+	return nil
+}
+
 func (game *Game) Resign(side PieceColor) error {
 	if !game.InProgress() {
 		return fmt.Errorf("game: can't resign, game not in progress")
@@ -140,10 +310,78 @@ func (game *Game) Resign(side PieceColor) error {
 	return nil
 }
 
+// CanAbort reports whether the game may still be aborted, which it may until both sides
+// have made their first move
+func (game *Game) CanAbort() bool {
+	return game.InProgress() && len(game.moves) < 2
+}
+
+func (game *Game) Abort() error {
+	if !game.CanAbort() {
+		return fmt.Errorf("game: can't abort, game not in progress or both sides have moved")
+	}
+	game.result = &ResultData{Result: GameResult_Aborted}
+	return nil
+}
+
+// TimeUntilAbort returns how long the side to move has left to make their first move,
+// within the window given, if the game may still be aborted
+func (game *Game) TimeUntilAbort(window time.Duration) (time.Duration, bool) {
+	if !game.CanAbort() {
+		return 0, false
+	}
+	return max(window-game.source.Now().Sub(game.turnStartTime), 0), true
+}
+
+// SetDrawPolicy sets how threefold repetition and the 50-move rule are handled, before the
+// game starts
+func (game *Game) SetDrawPolicy(policy DrawPolicy) {
+	game.drawPolicy = policy
+}
+
+func (game *Game) DrawPolicy() DrawPolicy {
+	return game.drawPolicy
+}
+
 func (game *Game) MovingSide() PieceColor {
 	return game.state.MovingSide()
 }
 
+// State returns the current position, which is never modified once moves are made
+func (game *Game) State() *GameState {
+	return game.state
+}
+
+func (game *Game) InitialState() *GameState {
+	return game.initial
+}
+
+func (game *Game) Control() TimeControl {
+	return game.control
+}
+
+// Stage returns the index of the time control stage the side's next move is made in
+func (game *Game) Stage(side PieceColor) int {
+	return game.control.StageOf(game.movesMade(side) + 1)
+}
+
+func (game *Game) RemainingTime(side PieceColor) time.Duration {
+	return game.clocks[side].RemainingTime()
+}
+
+// TimeUntilFlag returns how long until the running clock runs out, if the game is in
+// progress. Result() reports the timeout from then on.
+func (game *Game) TimeUntilFlag() (time.Duration, bool) {
+	if !game.InProgress() {
+		return 0, false
+	}
+	clock := game.clocks[game.MovingSide()]
+	if !clock.Running() {
+		return 0, false
+	}
+	return clock.TimeUntilFlag(), true
+}
+
 // Helpers
 
 func (game *Game) computeResult() (*ResultData, bool) {
@@ -160,7 +398,7 @@ func (game *Game) computeResult() (*ResultData, bool) {
 				DrawReason: DrawReason_InusfficientMaterialTimeout,
 			}, true
 		}
-	} else if possibleMoves := g.PlanPossibleMovesForSide(side); len(possibleMoves) == 0 {
+	} else if !g.hasLegalMove(side) {
 		if g.IsSideInCheck(side) {
 			return &ResultData{Result: GameResult_Checkmate, Winner: &opponent}, true
 		} else {
@@ -186,74 +424,89 @@ func (game *Game) toggleClocks() error {
 		if clock.RemainingTime() <= 0 {
 			return fmt.Errorf("game: clock ran out of time")
 		}
-		clock.Increment(game.control.Increment)
+		// Every move of a correspondence game has the same time
+		if game.control.IsCorrespondence() {
+			clock.remaining = game.control.MoveTime()
+		}
+		// The move being made, which may be the last of its stage
+		move := game.movesMade(side) + 1
+		stages := game.control.Stages()
+		stage := game.control.StageOf(move)
+		clock.Increment(stages[stage].Increment)
+		if next := game.control.StageOf(move + 1); next != stage {
+			clock.Increment(stages[next].Time)
+			clock.setDelay(stages[next].DelayMode, stages[next].Delay)
+		}
 	}
-	if otherClock, _ := game.clocks[side.Opponent()]; otherClock.RemainingTime() > 0 {
+	if otherClock, _ := game.clocks[side.Opponent()]; otherClock.RemainingTime() > 0 && !game.OnVacation(side.Opponent()) {
 		otherClock.Start()
 	}
 	return nil
 }
 
+// Number of moves the side has made since the game began
+func (game *Game) movesMade(side PieceColor) int {
+	plies := game.state.NumMoves() - game.initial.NumMoves()
+	if game.initial.MovingSide() == side {
+		return (plies + 1) / 2
+	}
+	return plies / 2
+}
+
 func (game *Game) testForDraw(g *Game, color PieceColor) (DrawReason, bool) {
 	if game.hasInsufficientMaterial(g.state, color) {
 		return DrawReason_InusfficientMaterial, true
-	} else if game.hasReached3FoldRepetition(g, color) {
-		return DrawReason_3FoldRepetition, true
-	} else if game.qualifiesFor50MoveRule(g.state, color) {
-		return DrawReason_50Moves, true
+	} else if !game.sideHasMaterialForCheckmate(g.state, color) &&
+		!game.sideHasMaterialForCheckmate(g.state, color.Opponent()) {
+		return DrawReason_DeadPosition, true
+	}
+	if g.drawPolicy == DrawPolicy_Automatic {
+		if game.hasReached3FoldRepetition(g, color) {
+			return DrawReason_3FoldRepetition, true
+		} else if game.qualifiesFor50MoveRule(g.state, color) {
+			return DrawReason_50Moves, true
+		}
+	}
+	if game.hasReached5FoldRepetition(g, color) {
+		return DrawReason_5FoldRepetition, true
+	} else if game.qualifiesFor75MoveRule(g.state, color) {
+		return DrawReason_75Moves, true
 	}
 	return DrawReason_None, false
 }
 
 func (game *Game) sideHasMaterialForCheckmate(g *GameState, color PieceColor) bool {
-	counts := g.CountPieces()[color]
-	totalPieces := len(counts)
-	// Lone/no king
-	if totalPieces <= 1 || counts[PieceType_King] <= 0 {
-		return false
-	}
-	// King + Knight/Bishop
-	if totalPieces == 2 && (counts[PieceType_Bishop] == 1 || counts[PieceType_Knight] == 1) {
-		return false
+	if game.matingState != g {
+		game.matingState = g
+		game.mating = [2]bool{g.CanCheckmate(PieceColor_White), g.CanCheckmate(PieceColor_Black)}
 	}
-	return true
+	return game.mating[color]
 }
 
 func (game *Game) hasInsufficientMaterial(g *GameState, color PieceColor) bool {
-	counts := g.CountPieces()
-	black, white := counts[PieceColor_Black], counts[PieceColor_White]
-	nBlack, nWhite := len(black), len(white)
+	b := &g.board.bitboards
 	// Sanity check
-	if black[PieceType_King] != 1 || white[PieceType_King] != 1 {
+	if b.pieces(PieceType_King, PieceColor_White).Count() != 1 ||
+		b.pieces(PieceType_King, PieceColor_Black).Count() != 1 {
 		return false
 	}
-	// King vs king
-	if nBlack == 1 && nWhite == 1 {
-		return true
-	}
-	// King vs King & (Knight/Bishop)
-	if nBlack+nWhite == 3 {
-		return black[PieceType_Knight] == 1 || black[PieceType_Bishop] == 1 ||
-			white[PieceType_Knight] == 1 || white[PieceType_Bishop] == 1
-	}
-	// King vs King with both sides having an opposite color bishop
-	if nBlack == 2 && nWhite == 2 {
-		var bishopSqs []Square
-		for square, piece := range g.Board().pieces {
-			if piece.Type() == PieceType_Bishop {
-				bishopSqs = append(bishopSqs, square)
-			}
-		}
-		return len(bishopSqs) == 2 && bishopSqs[0].Color() != bishopSqs[1].Color()
-	}
-	return false
+	return !g.hasMatingMaterial(PieceColor_White) && !g.hasMatingMaterial(PieceColor_Black)
 }
 
 func (game *Game) hasReached3FoldRepetition(g *Game, color PieceColor) bool {
-	return g.repititionHashes[g.state.repititionHashString()] >= drawRepetitionMoveCount
+	return g.repititionHashes[g.state.Hash()] >= drawRepetitionMoveCount
 }
 
 func (game *Game) qualifiesFor50MoveRule(g *GameState, color PieceColor) bool {
 	return g.numMoves-g.lastCaptureMove >= drawMoveCount &&
 		g.numMoves-g.lastPawnMove >= drawMoveCount
 }
+
+func (game *Game) hasReached5FoldRepetition(g *Game, color PieceColor) bool {
+	return g.repititionHashes[g.state.Hash()] >= autoDrawRepetitionCount
+}
+
+func (game *Game) qualifiesFor75MoveRule(g *GameState, color PieceColor) bool {
+	return g.numMoves-g.lastCaptureMove >= autoDrawMoveCount &&
+		g.numMoves-g.lastPawnMove >= autoDrawMoveCount
+}
@@ -0,0 +1,65 @@
+package game
+
+import (
+	"fmt"
+	"time"
+)
+
+// RestoredMove is a move replayed when restoring a game, with the time each side had left
+// once it was made
+type RestoredMove struct {
+	Move      Move
+	Remaining map[PieceColor]time.Duration
+}
+
+// RestoreGame rebuilds a started game by replaying its moves from the initial position, as
+// when resuming games after a restart. The clocks are set to the remaining times stored
+// with each move, so taking moves back returns to them, with the clock of the side to move
+// running from now, so time spent while the game was not being played isn't charged to
+// either side. The draw policy is the one the game was played under, which decides whether
+// repetitions along the way ended it.
+func RestoreGame(control TimeControl, policy DrawPolicy, initial *GameState, moves []RestoredMove,
+	startTime time.Time) (*Game, error) {
+	return RestoreGameWithTimeSource(control, policy, initial, moves, startTime, SystemTime)
+}
+
+// RestoreGameWithTimeSource restores the game like RestoreGame, its clocks running on the
+// time source
+func RestoreGameWithTimeSource(control TimeControl, policy DrawPolicy, initial *GameState, moves []RestoredMove,
+	startTime time.Time, source TimeSource) (*Game, error) {
+	g := NewGameWithTimeSource(control, initial, source)
+	g.SetDrawPolicy(policy)
+	g.Start()
+	for i, move := range moves {
+		if err := g.Move(move.Move); err != nil {
+			return nil, fmt.Errorf("game: failed to replay move %d %s: %v", i+1, move.Move.UCI(), err)
+		}
+		// Replaying takes no time, so the clocks are set as they were once the move was
+		// made, which the history kept for takebacks records on the next move
+		for color, clock := range g.clocks {
+			d, ok := move.Remaining[color]
+			if !ok {
+				continue
+			}
+			running := clock.Running()
+			clock.Stop()
+			clock.remaining = d
+			if running {
+				clock.Start()
+			}
+		}
+	}
+
+	g.startTime = startTime
+	g.result, _ = g.computeResult()
+	return g, nil
+}
+
+func (game *Game) StartTime() time.Time {
+	return game.startTime
+}
+
+// TimeSource returns the source the game tells the time by
+func (game *Game) TimeSource() TimeSource {
+	return game.source
+}
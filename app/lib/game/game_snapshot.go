@@ -1,25 +1,86 @@
 package game
 
-import "time"
+import "slices"
 
 // Serialiable copy of game.Game
 type GameSnapshot struct {
+	Variant       Variant              `json:"variant"`
+	InitialFEN    string               `json:"initial_fen"`
 	Moves         []Move               `json:"moves"`
+	SANMoves      []string             `json:"san_moves"`
+	UCIMoves      []string             `json:"uci_moves"`
 	Result        *ResultData          `json:"result,omitempty"`
 	SnapshotTime  int64                `json:"snapshot_time"`
 	RemainingTime map[PieceColor]int64 `json:"remaining_time"`
+	DrawPolicy    DrawPolicy           `json:"draw_policy"`
+	// Draw the player on move may claim, if any
+	ClaimableDraw DrawReason `json:"claimable_draw,omitempty"`
+	// Stages of the time control, and the one each side's next move is made in
+	TimeStages []TimeStageSnapshot `json:"time_stages"`
+	Stage      map[PieceColor]int  `json:"stage"`
+	// Vacations taken in a correspondence game, and the vacation time each side has left
+	Vacations      []Vacation           `json:"vacations,omitempty"`
+	VacationMillis map[PieceColor]int64 `json:"vacation_millis,omitempty"`
+}
+
+// A stage of the time control, in milliseconds like the clocks
+type TimeStageSnapshot struct {
+	Moves           int       `json:"moves,omitempty"`
+	DurationMillis  int64     `json:"duration_millis"`
+	IncrementMillis int64     `json:"increment_millis,omitempty"`
+	DelayMillis     int64     `json:"delay_millis,omitempty"`
+	DelayMode       DelayMode `json:"delay_mode,omitempty"`
+}
+
+// StageSnapshots returns the stages of the time control as the snapshot shows them
+func (t TimeControl) StageSnapshots() []TimeStageSnapshot {
+	var stages []TimeStageSnapshot
+	for _, s := range t.Stages() {
+		stages = append(stages, TimeStageSnapshot{
+			Moves:           s.Moves,
+			DurationMillis:  s.Time.Milliseconds(),
+			IncrementMillis: s.Increment.Milliseconds(),
+			DelayMillis:     s.Delay.Milliseconds(),
+			DelayMode:       s.DelayMode,
+		})
+	}
+	return stages
 }
 
 func (g *Game) Snapshot() GameSnapshot {
 	result, _ := g.Result()
 	remaining := make(map[PieceColor]int64)
+	stage := make(map[PieceColor]int)
 	for color, clock := range g.clocks {
 		remaining[color] = clock.RemainingTime().Milliseconds()
+		stage[color] = g.Stage(color)
+	}
+	var vacationLeft map[PieceColor]int64
+	if g.control.IsCorrespondence() {
+		vacationLeft = make(map[PieceColor]int64)
+		for color := range g.clocks {
+			vacationLeft[color] = g.VacationLeft(color).Milliseconds()
+		}
+	}
+	claimable, _ := g.CanClaimDraw()
+	uciMoves := make([]string, len(g.moves))
+	for i, move := range g.moves {
+		uciMoves[i] = move.UCI()
 	}
 	return GameSnapshot{
-		Moves:         g.moves,
-		Result:        result,
-		SnapshotTime:  time.Now().UnixMilli(),
-		RemainingTime: remaining,
+		Variant:        g.initial.Variant(),
+		InitialFEN:     g.initial.FEN(),
+		Moves:          g.moves,
+		SANMoves:       g.sanMoves,
+		UCIMoves:       uciMoves,
+		Result:         result,
+		DrawPolicy:     g.drawPolicy,
+		ClaimableDraw:  claimable,
+		TimeStages:     g.control.StageSnapshots(),
+		Stage:          stage,
+		Vacations:      slices.Clone(g.vacations),
+		VacationMillis: vacationLeft,
+		SnapshotTime:   g.source.Now().UnixMilli(),
+		RemainingTime:  remaining,
 	}
 }
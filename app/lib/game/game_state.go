@@ -1,11 +1,7 @@
 package game
 
 import (
-	"crypto/sha1"
 	"fmt"
-	"strings"
-
-	"golang.org/x/exp/slices"
 )
 
 type GameState struct {
@@ -15,6 +11,8 @@ type GameState struct {
 	enpassantTarget *Square
 	lastCaptureMove int
 	lastPawnMove    int
+	hash            uint64
+	variant         Variant
 }
 
 func (g *GameState) Board() *Board {
@@ -30,20 +28,29 @@ func (g *GameState) WithMove(move Move) (*GameState, error) {
 		return nil, fmt.Errorf("game: attempted to move piece out of turn")
 	}
 
-	nextPos, err := piece.WithLocalMove(move, g)
-	if err != nil {
-		return nil, fmt.Errorf("game: move failed: %v", err)
+	var candidate *Move
+	for _, pseudo := range g.pseudoLegalMoves(piece.Color(), squareBit(move.From), nil) {
+		if pseudo.To != move.To {
+			continue
+		}
+		if pseudo.Promotion == nil || (move.Promotion != nil && *pseudo.Promotion == *move.Promotion) {
+			candidate = &pseudo
+			break
+		}
 	}
-	if nextPos.IsSideInCheck(piece.Color()) {
+	if candidate == nil {
+		return nil, fmt.Errorf("game: move failed: illegal move from %s to %s", move.From, move.To)
+	}
+	if !g.leavesKingSafe(*candidate, piece.Color()) {
 		return nil, fmt.Errorf("game: move failed: violates king integrity")
 	}
 
-	return nextPos, nil
+	return g.applyMove(*candidate), nil
 }
 
 func (g *GameState) ComputeSquaresAttackedBySide(color PieceColor) map[Square]bool {
 	attacked := make(map[Square]bool)
-	for square, piece := range g.Board().pieces {
+	for square, piece := range g.Board().Pieces() {
 		if piece.Color() != color {
 			continue
 		}
@@ -64,14 +71,7 @@ func (g *GameState) ComputeAttackedSquares(from Square) map[Square]bool {
 }
 
 func (g *GameState) PlanPossibleMovesForSide(color PieceColor) []MovePlan {
-	var out []MovePlan
-	for sq, piece := range g.Board().pieces {
-		if piece.Color() != color {
-			continue
-		}
-		out = append(out, g.PlanPossibleMoves(sq)...)
-	}
-	return out
+	return g.planMoves(g.legalMoves(color, ^Bitboard(0)))
 }
 
 func (g *GameState) PlanPossibleMoves(from Square) []MovePlan {
@@ -79,25 +79,19 @@ func (g *GameState) PlanPossibleMoves(from Square) []MovePlan {
 	if !exists {
 		return []MovePlan{}
 	}
-	moves := piece.PlanPossibleMovesLocally(from, g)
-	var out []MovePlan
-	color := piece.Color()
-	for _, move := range moves {
-		if move.Game.IsSideInCheck(color) {
-			continue
-		}
-		out = append(out, move)
-	}
-	return out
+	return g.planMoves(g.legalMoves(piece.Color(), squareBit(from)))
 }
 
 func (g *GameState) IsSideInCheck(color PieceColor) bool {
-	attackMap := g.ComputeSquaresAttackedBySide(color.Opponent())
 	square, ok := g.Board().getKingSquare(color)
 	if !ok {
 		return false
 	}
-	return attackMap[*square]
+	return g.Board().isSquareAttacked(*square, color.Opponent())
+}
+
+func (g *GameState) Variant() Variant {
+	return g.variant
 }
 
 func (g *GameState) NumMoves() int {
@@ -113,7 +107,7 @@ func (g *GameState) CountPieces() map[PieceColor]map[PieceType]int {
 		PieceColor_White: {},
 		PieceColor_Black: {},
 	}
-	for _, piece := range g.Board().pieces {
+	for _, piece := range g.Board().Pieces() {
 		out[piece.Color()][piece.Type()] += 1
 	}
 	return out
@@ -134,12 +128,18 @@ func (g *GameState) appendingPosition(board *Board, move Move, params AppendPosP
 		enpassantTarget = &move.To
 	}
 
-	castlingSquares := make(map[Square]SquareMovementStatus)
-	for square, status := range g.castlingSquares {
-		if square == move.From || square == move.To {
-			castlingSquares[square] = SquareMovementStatus_Moved
-		} else {
-			castlingSquares[square] = status
+	// Positions share the castling squares until a tracked square is touched
+	castlingSquares := g.castlingSquares
+	_, fromTracked := castlingSquares[move.From]
+	_, toTracked := castlingSquares[move.To]
+	if fromTracked || toTracked {
+		castlingSquares = make(map[Square]SquareMovementStatus, len(g.castlingSquares))
+		for square, status := range g.castlingSquares {
+			if square == move.From || square == move.To {
+				castlingSquares[square] = SquareMovementStatus_Moved
+			} else {
+				castlingSquares[square] = status
+			}
 		}
 	}
 
@@ -149,55 +149,27 @@ func (g *GameState) appendingPosition(board *Board, move Move, params AppendPosP
 	}
 
 	lastCaptureMove := g.lastCaptureMove
-	if _, exists := g.board.GetPiece(move.To); exists ||
-		len(board.pieces) < len(g.board.pieces) {
+	if board.NumPieces() < g.board.NumPieces() {
 		lastCaptureMove = numMoves
 	}
 
-	return &GameState{
-		board,
-		numMoves,
-		castlingSquares,
-		enpassantTarget,
-		lastCaptureMove,
-		lastPawnMove,
+	next := &GameState{
+		board:           board,
+		numMoves:        numMoves,
+		castlingSquares: castlingSquares,
+		enpassantTarget: enpassantTarget,
+		lastCaptureMove: lastCaptureMove,
+		lastPawnMove:    lastPawnMove,
+		variant:         g.variant,
 	}
+	next.hash = next.computeHash()
+	return next
 }
 
-func (g *GameState) repititionHashString() string {
-	h := sha1.New()
-	h.Write([]byte(g.repititionHashableString()))
-	bs := h.Sum(nil)
-	sh := string(fmt.Sprintf("%x", bs))
-	return sh
-}
-
-func (g *GameState) repititionHashableString() string {
-	hashes := []string{}
-
-	// Ensure all the pieces and their colors are the same
-	for square, piece := range g.board.pieces {
-		hash := fmt.Sprintf("%s=%d_%d", square.String(), piece.Color(), piece.Type())
-		hashes = append(hashes, hash)
-	}
-	slices.Sort(hashes)
-
-	// Ensure castling rights are unchanged
-	castlingHashes := []string{}
-	for square, status := range g.castlingSquares {
-		hash := fmt.Sprintf("%s=%d", square.String(), status)
-		castlingHashes = append(castlingHashes, hash)
-	}
-	slices.Sort(castlingHashes)
-	hashes = append(hashes, castlingHashes...)
-
-	// Must be the move of the same player
-	hashes = append(hashes, fmt.Sprint(g.numMoves%2))
-
-	// Ensure en-passant rights are unchanged
-	if g.enpassantTarget != nil {
-		hashes = append(hashes, g.enpassantTarget.String())
+func (g *GameState) planMoves(moves []Move) []MovePlan {
+	var out []MovePlan
+	for _, move := range moves {
+		out = append(out, MovePlan{Move: move, Game: g.applyMove(move)})
 	}
-
-	return strings.Join(hashes, ",")
+	return out
 }
@@ -1,43 +1,49 @@
 package game
 
+var standardBackRank = []PieceType{
+	PieceType_Rook,
+	PieceType_Knight,
+	PieceType_Bishop,
+	PieceType_Queen,
+	PieceType_King,
+	PieceType_Bishop,
+	PieceType_Knight,
+	PieceType_Rook,
+}
+
 func NewGameState() *GameState {
+	return newGameState(standardBackRank, Variant_Standard)
+}
+
+func newGameState(backRank []PieceType, variant Variant) *GameState {
 	game := new(GameState)
 	game.board = NewBoard()
 	game.castlingSquares = make(map[Square]SquareMovementStatus)
-	initializePieces(game.Board(), game.castlingSquares)
+	game.variant = variant
+	initializePieces(game.Board(), backRank, game.castlingSquares)
+	game.hash = game.computeHash()
 	return game
 }
 
-func initializePieces(board *Board, castlingSquares map[Square]SquareMovementStatus) {
+func initializePieces(board *Board, backRank []PieceType, castlingSquares map[Square]SquareMovementStatus) {
 	whitePiecesRank := 0
 	whitePawnsRank := whitePiecesRank + 1
 	populatePawns(board, PieceColor_White, whitePawnsRank)
-	populatePieces(board, PieceColor_White, whitePiecesRank, castlingSquares)
+	populatePieces(board, PieceColor_White, whitePiecesRank, backRank, castlingSquares)
 
 	blackPawnsRank := board.NumRanks() - 1 - whitePawnsRank
 	blackPiecesRank := board.NumRanks() - 1 - whitePiecesRank
 	populatePawns(board, PieceColor_Black, blackPawnsRank)
-	populatePieces(board, PieceColor_Black, blackPiecesRank, castlingSquares)
+	populatePieces(board, PieceColor_Black, blackPiecesRank, backRank, castlingSquares)
 }
 
-func populatePieces(board *Board, color PieceColor, rank int, castlingSquares map[Square]SquareMovementStatus) {
-	pieces := []Piece{
-		NewRook(color),
-		NewKnight(color),
-		NewBishop(color),
-		NewQueen(color),
-		NewKing(color),
-		NewBishop(color),
-		NewKnight(color),
-		NewRook(color),
-	}
-	for file, piece := range pieces {
-		t := piece.Type()
+func populatePieces(board *Board, color PieceColor, rank int, backRank []PieceType, castlingSquares map[Square]SquareMovementStatus) {
+	for file, t := range backRank {
 		sq := Square{File: file, Rank: rank}
 		if t == PieceType_Rook || t == PieceType_King {
 			castlingSquares[sq] = SquareMovementStatus_Unmoved
 		}
-		board.setPiece(piece, sq)
+		board.setPiece(NewPiece(t, color), sq)
 	}
 }
 
@@ -33,18 +33,6 @@ func TestIsSideInCheck(t *testing.T) {
 	}
 }
 
-func TestStateHashing(t *testing.T) {
-	g := NewGame(TimeControl_Thirty)
-    g.Start()
-	g.Move(Move{From: sq("e2"), To: sq("e4")})
-	hash := g.state.repititionHashableString()
-	want := "a1=0_2,a2=0_5,a7=1_5,a8=1_2,b1=0_4,b2=0_5,b7=1_5,b8=1_4,c1=0_3,c2=0_5,c7=1_5,c8=1_3,d1=0_1,d2=0_5,d7=1_5,d8=1_1,e1=0_0,e4=0_5,e7=1_5," +
-		"e8=1_0,f1=0_3,f2=0_5,f7=1_5,f8=1_3,g1=0_4,g2=0_5,g7=1_5,g8=1_4,h1=0_2,h2=0_5,h7=1_5,h8=1_2,a1=0,a8=0,e1=0,e8=0,h1=0,h8=0,1"
-	if hash != want {
-		t.Errorf("Got hash: %s want: %s", hash, want)
-	}
-}
-
 // Helpers
 
 type testMove struct {
@@ -85,19 +73,23 @@ func clearSquares(g *GameState, squares ...string) {
 
 func simulatePosition(strPieces map[string]Piece, append bool) *GameState {
 	g := NewGameState()
-	pieces := make(map[Square]Piece)
+	board := NewBoard()
 	for sqStr, piece := range strPieces {
-		pieces[sq(sqStr)] = piece
+		board.setPiece(piece, sq(sqStr))
 		// Checking if has moved from original square
-		if g.Board().pieces[sq(sqStr)] != pieces[sq(sqStr)] {
+		if original, _ := g.Board().GetPiece(sq(sqStr)); original != piece {
 			if _, tracked := g.castlingSquares[sq(sqStr)]; tracked {
 				g.castlingSquares[sq(sqStr)] = SquareMovementStatus_Moved
 			}
 		}
 	}
-	board := &Board{pieces: pieces}
 	if append {
-		return &GameState{board, g.numMoves + 1, g.castlingSquares, g.enpassantTarget, 0, 0}
+		return &GameState{
+			board:           board,
+			numMoves:        g.numMoves + 1,
+			castlingSquares: g.castlingSquares,
+			enpassantTarget: g.enpassantTarget,
+		}
 	} else {
 		g.board = board
 		return g
@@ -105,5 +97,10 @@ func simulatePosition(strPieces map[string]Piece, append bool) *GameState {
 }
 
 func gameWithPosition(g *GameState, b *Board) *GameState {
-	return &GameState{b, g.numMoves, g.castlingSquares, g.enpassantTarget, 0, 0}
+	return &GameState{
+		board:           b,
+		numMoves:        g.numMoves,
+		castlingSquares: g.castlingSquares,
+		enpassantTarget: g.enpassantTarget,
+	}
 }
@@ -1,6 +1,9 @@
 package game
 
 import (
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 	"testing/synctest"
 	"time"
@@ -148,23 +151,34 @@ func TestResults(t *testing.T) {
 			},
 			result: &ResultData{Result: GameResult_Draw, DrawReason: DrawReason_InusfficientMaterial},
 		},
-		"King vs King & opposing square colour bishops insufficient material": {
+		"King vs King & opposing square colour bishops, not insufficient material": {
 			pos: map[string]Piece{
 				"a8": NewKing(PieceColor_Black),
 				"a1": NewBishop(PieceColor_Black),
 				"a6": NewKing(PieceColor_White),
 				"b1": NewBishop(PieceColor_White),
 			},
-			result: &ResultData{Result: GameResult_Draw, DrawReason: DrawReason_InusfficientMaterial},
+			result: nil,
 		},
-		"King vs King & same square colour bishops, not insufficient material": {
+		"King vs King & same square colour bishops insufficient material": {
 			pos: map[string]Piece{
 				"a8": NewKing(PieceColor_Black),
 				"a1": NewBishop(PieceColor_Black),
 				"a6": NewKing(PieceColor_White),
 				"a3": NewBishop(PieceColor_White),
 			},
-			result: nil,
+			result: &ResultData{Result: GameResult_Draw, DrawReason: DrawReason_InusfficientMaterial},
+		},
+		"Several bishops all on one square colour insufficient material": {
+			pos: map[string]Piece{
+				"a8": NewKing(PieceColor_Black),
+				"c1": NewBishop(PieceColor_Black),
+				"a6": NewKing(PieceColor_White),
+				"a3": NewBishop(PieceColor_White),
+				"e3": NewBishop(PieceColor_White),
+				"h6": NewBishop(PieceColor_White),
+			},
+			result: &ResultData{Result: GameResult_Draw, DrawReason: DrawReason_InusfficientMaterial},
 		},
 		"Combination of timeout and opponent having insufficient material": {
 			pos: map[string]Piece{
@@ -228,9 +242,7 @@ func Test3FoldRepetition(t *testing.T) {
 				{from: "b1", to: "c3"},
 				{from: "g8", to: "f6"},
 				{from: "c3", to: "b1"},
-				{from: "f6", to: "g8"},
-
-				{from: "b1", to: "c3"},
+				{from: "f6", to: "g8"}, // The starting position a third time
 			},
 			draw: true,
 		},
@@ -748,6 +760,34 @@ func TestMoveLogging(t *testing.T) {
 	}
 }
 
+func TestSANMoves(t *testing.T) {
+	g := NewGame(TimeControl_Thirty)
+	g.Start()
+
+	sans := []string{"e4", "e5", "Nf3", "Nc6", "Bb5", "a6", "Bxc6", "dxc6", "O-O"}
+	for _, san := range sans {
+		move, err := g.ParseSAN(san)
+		if err != nil {
+			t.Fatalf("ParseSAN(%s) failed unexpectedly: %v", san, err)
+		}
+		if err := g.Move(move); err != nil {
+			t.Fatalf("Move(%v) failed unexpectedly: %v", move, err)
+		}
+	}
+
+	if _, err := g.ParseSAN("Ke2"); err == nil {
+		t.Errorf("ParseSAN(%s) accepted an illegal move", "Ke2")
+	}
+
+	snap := g.Snapshot()
+	if !reflect.DeepEqual(snap.SANMoves, sans) {
+		t.Errorf("Snapshot() SAN moves got %v, want %v", snap.SANMoves, sans)
+	}
+	if len(snap.Moves) != len(sans) {
+		t.Errorf("Snapshot() moves length got %d, want %d", len(snap.Moves), len(sans))
+	}
+}
+
 // Helper
 
 type timeTrackingParm struct {
@@ -786,3 +826,749 @@ func testTimeTracking(t *testing.T, g *Game, params []timeTrackingParm) {
 		}
 	})
 }
+
+func TestRestoreGame(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		moves := restoredMoves([]string{"g1f3", "g8f6", "f3g1", "f6g8", "g1f3", "g8f6", "f3g1"}, nil)
+		moves[len(moves)-1].Remaining = map[PieceColor]time.Duration{
+			PieceColor_White: 10 * time.Minute,
+			PieceColor_Black: 5 * time.Minute,
+		}
+		startTime := time.Now().Add(-time.Hour)
+		g, err := RestoreGame(TimeControl_Thirty, DrawPolicy_Automatic, NewGameState(), moves, startTime)
+		if err != nil {
+			t.Fatalf("RestoreGame() failed unexpectedly: %v", err)
+		}
+
+		if !g.InProgress() {
+			t.Errorf("restored game is not in progress")
+		}
+		if got := g.Snapshot().SANMoves; len(got) != len(moves) || got[0] != "Nf3" {
+			t.Errorf("restored game SAN moves got %v", got)
+		}
+		if !g.StartTime().Equal(startTime) {
+			t.Errorf("StartTime() got %v, want %v", g.StartTime(), startTime)
+		}
+
+		time.Sleep(time.Second)
+		if got, want := g.RemainingTime(PieceColor_White), 10*time.Minute; got != want {
+			t.Errorf("white's clock got %v, want %v stopped", got, want)
+		}
+		if got, want := g.RemainingTime(PieceColor_Black), 5*time.Minute-time.Second; got != want {
+			t.Errorf("black's clock got %v, want %v running", got, want)
+		}
+
+		// Repetitions from before the restore still count, back to the starting position
+		if err := g.Move(Move{From: sq("f6"), To: sq("g8")}); err != nil {
+			t.Fatalf("Move() failed unexpectedly: %v", err)
+		}
+		if result, ended := g.Result(); !ended || result.DrawReason != DrawReason_3FoldRepetition {
+			t.Errorf("restored game result got %v, want a draw by repetition", result)
+		}
+	})
+}
+
+func TestRestoredGameTakeback(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		// White and black had spent most of their time by the fourth move
+		moves := restoredMoves([]string{"e2e4", "e7e5", "g1f3", "b8c6"}, []map[PieceColor]time.Duration{
+			{PieceColor_White: 30 * time.Second, PieceColor_Black: 3*time.Minute + 2*time.Second},
+			{PieceColor_White: 30 * time.Second, PieceColor_Black: 40 * time.Second},
+			{PieceColor_White: 10 * time.Second, PieceColor_Black: 40 * time.Second},
+			{PieceColor_White: 10 * time.Second, PieceColor_Black: 12 * time.Second},
+		})
+		g, err := RestoreGame(TimeControl_ThreeTwo, DrawPolicy_Automatic, NewGameState(), moves, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatalf("RestoreGame() failed unexpectedly: %v", err)
+		}
+		if got, want := g.RemainingTime(PieceColor_White), 10*time.Second; got != want {
+			t.Errorf("white's clock got %v after the restore, want %v", got, want)
+		}
+
+		tests := []struct {
+			n          int
+			wantWhite  time.Duration
+			wantBlack  time.Duration
+			wantMoving PieceColor
+		}{
+			// Back to before g1f3, as white stood once e7e5 was made
+			{n: 2, wantWhite: 30 * time.Second, wantBlack: 40 * time.Second, wantMoving: PieceColor_White},
+			// Back to the start
+			{n: 2, wantWhite: 3 * time.Minute, wantBlack: 3 * time.Minute, wantMoving: PieceColor_White},
+		}
+		for _, test := range tests {
+			if err := g.Takeback(test.n); err != nil {
+				t.Fatalf("Takeback(%d) failed unexpectedly: %v", test.n, err)
+			}
+			if got := g.RemainingTime(PieceColor_White); got != test.wantWhite {
+				t.Errorf("white's clock after taking back to %d moves got %v, want %v", len(g.Snapshot().Moves), got, test.wantWhite)
+			}
+			if got := g.RemainingTime(PieceColor_Black); got != test.wantBlack {
+				t.Errorf("black's clock after taking back to %d moves got %v, want %v", len(g.Snapshot().Moves), got, test.wantBlack)
+			}
+			if got := g.MovingSide(); got != test.wantMoving {
+				t.Errorf("side to move got %v, want %v", got, test.wantMoving)
+			}
+		}
+	})
+}
+
+func TestRestoreGameRejectsIllegalMoves(t *testing.T) {
+	moves := restoredMoves([]string{"e2e5"}, nil)
+	if _, err := RestoreGame(TimeControl_Thirty, DrawPolicy_Automatic, NewGameState(), moves, time.Now()); err == nil {
+		t.Errorf("RestoreGame() accepted an illegal move")
+	}
+}
+
+func TestTimeUntilFlag(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		g := NewGame(TimeControl_TwoOne)
+		if _, ok := g.TimeUntilFlag(); ok {
+			t.Errorf("TimeUntilFlag() reported a running clock before the game started")
+		}
+
+		g.Start()
+		time.Sleep(30 * time.Second)
+		if got, ok := g.TimeUntilFlag(); !ok || got != 90*time.Second {
+			t.Errorf("TimeUntilFlag() got %s, want %s", got, 90*time.Second)
+		}
+
+		g.Move(Move{From: sq("e2"), To: sq("e4")})
+		time.Sleep(10 * time.Second)
+		if got, ok := g.TimeUntilFlag(); !ok || got != 110*time.Second {
+			t.Errorf("TimeUntilFlag() for black got %s, want %s", got, 110*time.Second)
+		}
+
+		time.Sleep(110 * time.Second)
+		result, ended := g.Result()
+		if !ended || result.Result != GameResult_Timeout || *result.Winner != PieceColor_White {
+			t.Errorf("Result() at flag fall got %v, want a timeout win for white", result)
+		}
+		if _, ok := g.TimeUntilFlag(); ok {
+			t.Errorf("TimeUntilFlag() reported a running clock after the game ended")
+		}
+	})
+}
+
+func TestClocksWithFakeTime(t *testing.T) {
+	source := NewFakeTimeSource(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	g := NewGameWithTimeSource(TimeControl_ThreeTwo, NewGameState(), source)
+	g.Start()
+
+	base := 3 * time.Minute
+	tests := []struct {
+		move          Move
+		timeTaken     time.Duration
+		wantRemaining map[PieceColor]time.Duration
+	}{
+		{
+			move:      Move{From: sq("e2"), To: sq("e4")},
+			timeTaken: 10 * time.Second,
+			wantRemaining: map[PieceColor]time.Duration{
+				PieceColor_White: base - 8*time.Second,
+				PieceColor_Black: base,
+			},
+		},
+		{
+			move:      Move{From: sq("e7"), To: sq("e5")},
+			timeTaken: 500 * time.Millisecond,
+			wantRemaining: map[PieceColor]time.Duration{
+				PieceColor_White: base - 8*time.Second,
+				PieceColor_Black: base + 1500*time.Millisecond,
+			},
+		},
+		{
+			move:      Move{From: sq("g1"), To: sq("f3")},
+			timeTaken: time.Minute,
+			wantRemaining: map[PieceColor]time.Duration{
+				PieceColor_White: base - 66*time.Second,
+				PieceColor_Black: base + 1500*time.Millisecond,
+			},
+		},
+	}
+	for i, test := range tests {
+		source.Advance(test.timeTaken)
+		if err := g.Move(test.move); err != nil {
+			t.Fatalf("Move %d failed unexpectedly: %v", i, err)
+		}
+		for side, want := range test.wantRemaining {
+			if got := g.RemainingTime(side); got != want {
+				t.Errorf("Move %d: side %d remaining got %s, want %s", i, side, got, want)
+			}
+		}
+	}
+
+	if got, want := g.Snapshot().SnapshotTime, source.Now().UnixMilli(); got != want {
+		t.Errorf("SnapshotTime got %d, want %d", got, want)
+	}
+}
+
+func TestFlagFallWithFakeTime(t *testing.T) {
+	source := NewFakeTimeSource(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	g := NewGameWithTimeSource(TimeControl_TwoOne, NewGameState(), source)
+	g.Start()
+
+	source.Advance(2*time.Minute - time.Millisecond)
+	if !g.InProgress() {
+		t.Fatalf("game ended with time left on the clock")
+	}
+
+	source.Advance(time.Millisecond)
+	result, ended := g.Result()
+	if !ended || result.Result != GameResult_Timeout || *result.Winner != PieceColor_Black {
+		t.Errorf("Result() at flag fall got %v, want a timeout win for black", result)
+	}
+	if err := g.Move(Move{From: sq("e2"), To: sq("e4")}); err == nil {
+		t.Errorf("Move() accepted after flag fall")
+	}
+}
+
+func TestTimeoutWithInsufficientMaterial(t *testing.T) {
+	white, black := PieceColor_White, PieceColor_Black
+	tests := map[string]struct {
+		fen        string
+		wantWinner *PieceColor
+	}{
+		"Queen against lone king, king's side flags": {
+			fen:        "4k3/8/8/8/8/8/8/4K2Q b - - 0 1",
+			wantWinner: &white,
+		},
+		"Queen against lone king, queen's side flags": {
+			fen: "4k3/8/8/8/8/8/8/4K2Q w - - 0 1",
+		},
+		"Knight can't mate a lone king": {
+			fen: "4k3/8/8/8/8/8/8/4KN2 b - - 0 1",
+		},
+		"Knight can mate with the help of the pawn": {
+			fen:        "4k3/4p3/8/8/8/8/8/4KN2 b - - 0 1",
+			wantWinner: &white,
+		},
+		"Bishop can mate with the help of the pawn": {
+			fen:        "4k3/4p3/8/8/8/8/8/4KB2 b - - 0 1",
+			wantWinner: &white,
+		},
+		"Bishops on one square colour can't mate": {
+			fen: "4k3/8/8/8/2b5/8/8/4KB2 b - - 0 1",
+		},
+		"Rook is lost to the only legal move": {
+			fen: "8/8/8/8/8/3k4/1r6/K7 w - - 0 1",
+		},
+		"Pawns locked out of reach of the kings can't mate": {
+			fen: "8/4k3/8/p1p1p1p1/P1P1P1P1/8/4K3/8 w - - 0 1",
+		},
+		"Pawn can promote and mate": {
+			fen:        "4k3/4p3/8/8/8/8/8/4KN2 w - - 0 1",
+			wantWinner: &black,
+		},
+		"Two knights can mate": {
+			fen:        "4k3/4p3/8/8/8/8/8/3NKN2 b - - 0 1",
+			wantWinner: &white,
+		},
+		"Bishop and knight can mate": {
+			fen:        "4k3/4p3/8/8/8/8/8/3NKB2 b - - 0 1",
+			wantWinner: &white,
+		},
+		"Rook can mate": {
+			fen:        "r3k3/8/8/8/8/8/8/4K3 w - - 0 1",
+			wantWinner: &black,
+		},
+	}
+
+	for title, test := range tests {
+		source := NewFakeTimeSource(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		g := NewGameWithTimeSource(TimeControl_Five, MustParseFEN(test.fen), source)
+		g.Start()
+		source.Advance(5 * time.Minute)
+
+		result, ended := g.Result()
+		if !ended {
+			t.Errorf("%s: game still in progress after flag fall", title)
+			continue
+		}
+		if test.wantWinner == nil {
+			if result.Result != GameResult_Draw || result.DrawReason != DrawReason_InusfficientMaterialTimeout {
+				t.Errorf("%s: got %v, want a draw by insufficient material on timeout", title, result)
+			}
+			continue
+		}
+		if result.Result != GameResult_Timeout || result.Winner == nil || *result.Winner != *test.wantWinner {
+			t.Errorf("%s: got %v, want a timeout win for %d", title, result, *test.wantWinner)
+		}
+	}
+}
+
+func TestTakeback(t *testing.T) {
+	source := NewFakeTimeSource(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	g := NewGameWithTimeSource(TimeControl_ThreeTwo, NewGameState(), source)
+	g.Start()
+
+	play := func(moves ...string) {
+		for _, san := range moves {
+			source.Advance(10 * time.Second)
+			move, err := g.ParseSAN(san)
+			if err != nil {
+				t.Fatalf("ParseSAN(%s) failed unexpectedly: %v", san, err)
+			}
+			if err := g.Move(move); err != nil {
+				t.Fatalf("Move(%s) failed unexpectedly: %v", san, err)
+			}
+		}
+	}
+
+	play("e4", "e5", "Nf3")
+	before := g.Snapshot()
+	source.Advance(5 * time.Second)
+	play("Nc6")
+
+	if err := g.Takeback(1); err != nil {
+		t.Fatalf("Takeback(1) failed unexpectedly: %v", err)
+	}
+	if got, want := g.Snapshot().UCIMoves, before.UCIMoves; !slices.Equal(got, want) {
+		t.Errorf("Takeback(1) moves got %v, want %v", got, want)
+	}
+	if got := g.Snapshot().SANMoves; !slices.Equal(got, []string{"e4", "e5", "Nf3"}) {
+		t.Errorf("Takeback(1) SAN moves got %v", got)
+	}
+	// Black's clock is back to when Nc6 was played, running again
+	if got, want := g.RemainingTime(PieceColor_Black), 3*time.Minute-23*time.Second; got != want {
+		t.Errorf("black's clock got %s, want %s", got, want)
+	}
+	if got, want := g.RemainingTime(PieceColor_White), 3*time.Minute-16*time.Second; got != want {
+		t.Errorf("white's clock got %s, want %s", got, want)
+	}
+	source.Advance(time.Second)
+	if got, want := g.RemainingTime(PieceColor_Black), 3*time.Minute-24*time.Second; got != want {
+		t.Errorf("black's clock after a second got %s, want %s", got, want)
+	}
+
+	if err := g.Takeback(2); err != nil {
+		t.Fatalf("Takeback(2) failed unexpectedly: %v", err)
+	}
+	if got, want := g.State().FEN(), "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"; got != want {
+		t.Errorf("Takeback(2) position got %s, want %s", got, want)
+	}
+	if g.MovingSide() != PieceColor_Black {
+		t.Errorf("Takeback(2) side to move got %d, want black", g.MovingSide())
+	}
+
+	for _, n := range []int{0, -1, 2} {
+		if err := g.Takeback(n); err == nil {
+			t.Errorf("Takeback(%d) accepted with one move played", n)
+		}
+	}
+
+	// Positions taken back no longer count towards repetition
+	play("Nf6", "Nf3", "Ng8", "Ng1")
+	if err := g.Takeback(4); err != nil {
+		t.Fatalf("Takeback(4) failed unexpectedly: %v", err)
+	}
+	play("Nf6", "Nf3", "Ng8", "Ng1", "Nf6", "Nf3", "Ng8")
+	if !g.InProgress() {
+		t.Errorf("game drawn counting positions which were taken back")
+	}
+	play("Ng1")
+	if result, ended := g.Result(); !ended || result.DrawReason != DrawReason_3FoldRepetition {
+		t.Errorf("Result() got %v, want a draw by repetition", result)
+	}
+	if err := g.Takeback(1); err == nil {
+		t.Errorf("Takeback() accepted once the game ended")
+	}
+}
+
+func TestAbort(t *testing.T) {
+	tests := map[string]struct {
+		moves     []Move
+		wantAbort bool
+	}{
+		"Before any move": {
+			wantAbort: true,
+		},
+		"After white's first move": {
+			moves:     []Move{{From: sq("e2"), To: sq("e4")}},
+			wantAbort: true,
+		},
+		"After both sides moved": {
+			moves: []Move{{From: sq("e2"), To: sq("e4")}, {From: sq("e7"), To: sq("e5")}},
+		},
+	}
+
+	for title, test := range tests {
+		g := NewGame(TimeControl_Five)
+		g.Start()
+		for _, move := range test.moves {
+			if err := g.Move(move); err != nil {
+				t.Fatalf("%s: Move() failed unexpectedly: %v", title, err)
+			}
+		}
+
+		if got := g.CanAbort(); got != test.wantAbort {
+			t.Errorf("%s: CanAbort() got %t, want %t", title, got, test.wantAbort)
+		}
+		err := g.Abort()
+		if !test.wantAbort {
+			if err == nil {
+				t.Errorf("%s: Abort() accepted after both sides moved", title)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Abort() failed unexpectedly: %v", title, err)
+			continue
+		}
+		result, ended := g.Result()
+		if !ended || result.Result != GameResult_Aborted || result.Winner != nil {
+			t.Errorf("%s: Result() got %v, want aborted", title, result)
+		}
+		if !strings.Contains(g.PGN(), `[Result "*"]`) || !strings.Contains(g.PGN(), `[Termination "abandoned"]`) {
+			t.Errorf("%s: PGN() of aborted game got\n%s", title, g.PGN())
+		}
+	}
+
+	if err := NewGame(TimeControl_Five).Abort(); err == nil {
+		t.Errorf("Abort() accepted before the game started")
+	}
+}
+
+func TestTimeUntilAbort(t *testing.T) {
+	source := NewFakeTimeSource(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	g := NewGameWithTimeSource(TimeControl_Five, NewGameState(), source)
+	g.Start()
+
+	window := 30 * time.Second
+	source.Advance(20 * time.Second)
+	if got, ok := g.TimeUntilAbort(window); !ok || got != 10*time.Second {
+		t.Errorf("TimeUntilAbort() for white got %s, want %s", got, 10*time.Second)
+	}
+
+	g.Move(Move{From: sq("e2"), To: sq("e4")})
+	source.Advance(25 * time.Second)
+	if got, ok := g.TimeUntilAbort(window); !ok || got != 5*time.Second {
+		t.Errorf("TimeUntilAbort() for black got %s, want %s", got, 5*time.Second)
+	}
+	source.Advance(time.Minute)
+	if got, ok := g.TimeUntilAbort(window); !ok || got != 0 {
+		t.Errorf("TimeUntilAbort() after the window got %s, want 0", got)
+	}
+
+	g.Move(Move{From: sq("e7"), To: sq("e5")})
+	if _, ok := g.TimeUntilAbort(window); ok {
+		t.Errorf("TimeUntilAbort() reported a window after both sides moved")
+	}
+}
+
+func TestClaimableDraws(t *testing.T) {
+	knightDance := []string{"Nc3", "Nf6", "Nb1", "Ng8"}
+	tests := map[string]struct {
+		fen    string
+		moves  []string
+		policy DrawPolicy
+		// Draw the side on move may claim after the moves, if any
+		claimable DrawReason
+		// Draw the moves lead to without a claim, if any
+		auto DrawReason
+	}{
+		"Threefold repetition ends automatic games": {
+			moves:  slices.Concat(knightDance, knightDance),
+			policy: DrawPolicy_Automatic,
+			auto:   DrawReason_3FoldRepetition,
+		},
+		"Threefold repetition may be claimed": {
+			moves:     slices.Concat(knightDance, knightDance),
+			policy:    DrawPolicy_Claimable,
+			claimable: DrawReason_3FoldRepetition,
+		},
+		"Fewer repetitions can't be claimed": {
+			moves:  slices.Concat(knightDance, knightDance[:1]),
+			policy: DrawPolicy_Claimable,
+		},
+		"Fivefold repetition ends the game": {
+			moves:  slices.Concat(knightDance, knightDance, knightDance, knightDance),
+			policy: DrawPolicy_Claimable,
+			auto:   DrawReason_5FoldRepetition,
+		},
+		"50 moves end automatic games": {
+			fen:    "8/8/4k3/8/8/4K3/8/R7 w - - 98 80",
+			moves:  []string{"Ra2", "Kd6"},
+			policy: DrawPolicy_Automatic,
+			auto:   DrawReason_50Moves,
+		},
+		"50 moves may be claimed": {
+			fen:       "8/8/4k3/8/8/4K3/8/R7 w - - 98 80",
+			moves:     []string{"Ra2", "Kd6"},
+			policy:    DrawPolicy_Claimable,
+			claimable: DrawReason_50Moves,
+		},
+		"Fewer than 50 moves can't be claimed": {
+			fen:    "8/8/4k3/8/8/4K3/8/R7 w - - 97 80",
+			moves:  []string{"Ra2", "Kd6"},
+			policy: DrawPolicy_Claimable,
+		},
+		"75 moves end the game": {
+			fen:    "8/8/4k3/8/8/4K3/8/R7 w - - 148 80",
+			moves:  []string{"Ra2", "Kd6"},
+			policy: DrawPolicy_Claimable,
+			auto:   DrawReason_75Moves,
+		},
+		"Checkmate on the 75th move stands": {
+			fen:    "7k/8/6K1/8/8/8/8/R7 w - - 149 80",
+			moves:  []string{"Ra8#"},
+			policy: DrawPolicy_Claimable,
+		},
+	}
+
+	for title, test := range tests {
+		state := NewGameState()
+		if test.fen != "" {
+			state = MustParseFEN(test.fen)
+		}
+		g := NewGameWithState(TimeControl_Thirty, state)
+		g.SetDrawPolicy(test.policy)
+		g.Start()
+		for _, san := range test.moves {
+			move, err := g.ParseSAN(san)
+			if err == nil {
+				err = g.Move(move)
+			}
+			if err != nil {
+				t.Fatalf("%s: failed to play %s: %v", title, san, err)
+			}
+		}
+
+		result, ended := g.Result()
+		if test.auto != DrawReason_None {
+			if !ended || result.Result != GameResult_Draw || result.DrawReason != test.auto {
+				t.Errorf("%s: got result %+v, want draw by reason %d", title, result, test.auto)
+			}
+		} else if ended && result.Result == GameResult_Draw {
+			t.Errorf("%s: got draw by reason %d, want no draw", title, result.DrawReason)
+		}
+
+		reason, ok := g.CanClaimDraw()
+		if reason != test.claimable || ok != (test.claimable != DrawReason_None) {
+			t.Errorf("%s: CanClaimDraw() = %d, %t, want %d", title, reason, ok, test.claimable)
+		}
+		err := g.ClaimDraw()
+		if test.claimable == DrawReason_None {
+			if err == nil {
+				t.Errorf("%s: ClaimDraw() succeeded, want error", title)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ClaimDraw() failed unexpectedly: %v", title, err)
+		}
+		if result, ended := g.Result(); !ended || result.DrawReason != test.claimable {
+			t.Errorf("%s: got result %+v after claim, want draw by reason %d", title, result, test.claimable)
+		}
+	}
+}
+
+func TestRepeatingStartingPosition(t *testing.T) {
+	knightDance := []string{"Nc3", "Nf6", "Nb1", "Ng8"}
+	tests := map[string]struct {
+		fen   string
+		moves []string
+		// Moves taken back once the moves before are played, then the rest played
+		takeback int
+		rest     []string
+		draw     bool
+	}{
+		"Third time": {
+			moves: slices.Concat(knightDance, knightDance),
+			draw:  true,
+		},
+		"Second time": {
+			moves: knightDance,
+		},
+		"Third time from a FEN": {
+			fen:   "4k1n1/8/8/8/8/8/8/1N2K3 w - - 0 1",
+			moves: slices.Concat(knightDance, knightDance),
+			draw:  true,
+		},
+		"Taken back to the start": {
+			moves:    slices.Concat(knightDance, knightDance[:1]),
+			takeback: 5,
+			rest:     knightDance,
+		},
+		"Third time after a takeback": {
+			moves:    slices.Concat(knightDance, knightDance[:1]),
+			takeback: 5,
+			rest:     slices.Concat(knightDance, knightDance),
+			draw:     true,
+		},
+	}
+
+	for title, test := range tests {
+		state := NewGameState()
+		if test.fen != "" {
+			state = MustParseFEN(test.fen)
+		}
+		g := NewGameWithState(TimeControl_Thirty, state)
+		g.Start()
+		play := func(moves []string) {
+			for _, san := range moves {
+				move, err := g.ParseSAN(san)
+				if err == nil {
+					err = g.Move(move)
+				}
+				if err != nil {
+					t.Fatalf("%s: failed to play %s: %v", title, san, err)
+				}
+			}
+		}
+		play(test.moves)
+		if test.takeback > 0 {
+			if err := g.Takeback(test.takeback); err != nil {
+				t.Fatalf("%s: Takeback() failed unexpectedly: %v", title, err)
+			}
+			play(test.rest)
+		}
+
+		result, ended := g.Result()
+		if test.draw && (!ended || result.DrawReason != DrawReason_3FoldRepetition) {
+			t.Errorf("%s: got result %+v, want a draw by repetition", title, result)
+		} else if !test.draw && ended {
+			t.Errorf("%s: got result %+v, want the game in progress", title, result)
+		}
+	}
+}
+
+func TestParseDrawPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy DrawPolicy
+		ok     bool
+	}{
+		"":          {DrawPolicy_Automatic, true},
+		"automatic": {DrawPolicy_Automatic, true},
+		"Claimable": {DrawPolicy_Claimable, true},
+		"fide":      {DrawPolicy_Claimable, true},
+		"sometimes": {DrawPolicy_Automatic, false},
+	}
+	for name, test := range tests {
+		policy, ok := ParseDrawPolicy(name)
+		if policy != test.policy || ok != test.ok {
+			t.Errorf("ParseDrawPolicy(%q) = %d, %t, want %d, %t", name, policy, ok, test.policy, test.ok)
+		}
+		if roundTrip, _ := ParseDrawPolicy(policy.String()); roundTrip != policy {
+			t.Errorf("ParseDrawPolicy(%q) = %d, want %d", policy.String(), roundTrip, policy)
+		}
+	}
+}
+
+func TestClocksWithDelay(t *testing.T) {
+	moves := []Move{
+		{From: sq("e2"), To: sq("e4")},
+		{From: sq("e7"), To: sq("e5")},
+		{From: sq("g1"), To: sq("f3")},
+	}
+	// Time taken for each move, within the delay and then beyond it
+	thinking := []time.Duration{3 * time.Second, 5 * time.Second, 12 * time.Second}
+	base := 5 * time.Minute
+
+	for _, mode := range []DelayMode{DelayMode_Simple, DelayMode_Bronstein} {
+		control := TimeControl{Total: base, Delay: 5 * time.Second, DelayMode: mode}
+		source := NewFakeTimeSource(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		g := NewGameWithTimeSource(control, NewGameState(), source)
+		g.Start()
+		for i, move := range moves {
+			source.Advance(thinking[i])
+			if err := g.Move(move); err != nil {
+				t.Fatalf("%s: move %d failed unexpectedly: %v", mode, i, err)
+			}
+		}
+		// Both ways only the time beyond the delay is charged once a move is made
+		if got, want := g.RemainingTime(PieceColor_White), base-7*time.Second; got != want {
+			t.Errorf("%s: white remaining got %s, want %s", mode, got, want)
+		}
+		if got, want := g.RemainingTime(PieceColor_Black), base; got != want {
+			t.Errorf("%s: black remaining got %s, want %s", mode, got, want)
+		}
+		// Black's delay has yet to pass, which under simple delay is time before the clock runs
+		untilFlag := base
+		if mode == DelayMode_Simple {
+			untilFlag += 5 * time.Second
+		}
+		if got, _ := g.TimeUntilFlag(); got != untilFlag {
+			t.Errorf("%s: TimeUntilFlag() got %s, want %s", mode, got, untilFlag)
+		}
+	}
+}
+
+func TestClocksWithStages(t *testing.T) {
+	// Knights out and back, so the game goes on as long as needed
+	cycle := []Move{
+		{From: sq("g1"), To: sq("f3")},
+		{From: sq("g8"), To: sq("f6")},
+		{From: sq("f3"), To: sq("g1")},
+		{From: sq("f6"), To: sq("g8")},
+	}
+	control := TimeControl{Total: 10 * time.Minute, Increment: 10 * time.Second, Moves: 2, Later: []TimeStage{
+		{Time: 5 * time.Minute, Delay: 5 * time.Second, DelayMode: DelayMode_Bronstein},
+	}}
+	source := NewFakeTimeSource(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	g := NewGameWithTimeSource(control, NewGameState(), source)
+	g.Start()
+
+	// White's second move ends the first stage, adding the second's time
+	for i, move := range cycle[:3] {
+		source.Advance(20 * time.Second)
+		if err := g.Move(move); err != nil {
+			t.Fatalf("move %d failed unexpectedly: %v", i, err)
+		}
+	}
+	if got, want := g.RemainingTime(PieceColor_White), 15*time.Minute-20*time.Second; got != want {
+		t.Errorf("white remaining got %s, want %s", got, want)
+	}
+	if got, want := g.RemainingTime(PieceColor_Black), 10*time.Minute-10*time.Second; got != want {
+		t.Errorf("black remaining got %s, want %s", got, want)
+	}
+	if got := g.Stage(PieceColor_White); got != 1 {
+		t.Errorf("white stage got %d, want 1", got)
+	}
+	if got := g.Stage(PieceColor_Black); got != 0 {
+		t.Errorf("black stage got %d, want 0", got)
+	}
+
+	// Black follows, and from then on both sides get the delay instead of the increment
+	for i, move := range []Move{cycle[3], cycle[0]} {
+		source.Advance(20 * time.Second)
+		if err := g.Move(move); err != nil {
+			t.Fatalf("move %d failed unexpectedly: %v", i+3, err)
+		}
+	}
+	if got, want := g.RemainingTime(PieceColor_Black), 15*time.Minute-20*time.Second; got != want {
+		t.Errorf("black remaining got %s, want %s", got, want)
+	}
+	if got, want := g.RemainingTime(PieceColor_White), 15*time.Minute-35*time.Second; got != want {
+		t.Errorf("white remaining got %s, want %s", got, want)
+	}
+
+	// Taking back over the boundary returns to the first stage, without the time it added
+	if err := g.Takeback(3); err != nil {
+		t.Fatalf("takeback failed unexpectedly: %v", err)
+	}
+	if got, want := g.RemainingTime(PieceColor_White), 10*time.Minute-30*time.Second; got != want {
+		t.Errorf("white remaining after takeback got %s, want %s", got, want)
+	}
+	if got := g.Stage(PieceColor_White); got != 0 {
+		t.Errorf("white stage after takeback got %d, want 0", got)
+	}
+}
+
+// Helpers
+
+// The moves in UCI notation to restore, each with the remaining times given, if any
+func restoredMoves(uciMoves []string, remaining []map[PieceColor]time.Duration) []RestoredMove {
+	moves := make([]RestoredMove, len(uciMoves))
+	for i, uci := range uciMoves {
+		move, err := ParseUCIMove(uci)
+		if err != nil {
+			panic(err)
+		}
+		moves[i].Move = *move
+		if i < len(remaining) {
+			moves[i].Remaining = remaining[i]
+		}
+	}
+	return moves
+}
@@ -0,0 +1,207 @@
+package game
+
+import (
+	"math/bits"
+)
+
+// LegalMoves returns every move the side may play in the position, regardless of
+// whose turn it is. Promotions are listed once per piece type.
+func (g *GameState) LegalMoves(color PieceColor) []Move {
+	return g.legalMoves(color, ^Bitboard(0))
+}
+
+// Helpers
+
+func (g *GameState) legalMoves(color PieceColor, origins Bitboard) []Move {
+	moves := g.pseudoLegalMoves(color, origins, make([]Move, 0, 48))
+	legal := moves[:0]
+	for _, move := range moves {
+		if g.leavesKingSafe(move, color) {
+			legal = append(legal, move)
+		}
+	}
+	return legal
+}
+
+func (g *GameState) hasLegalMove(color PieceColor) bool {
+	for _, move := range g.pseudoLegalMoves(color, ^Bitboard(0), make([]Move, 0, 48)) {
+		if g.leavesKingSafe(move, color) {
+			return true
+		}
+	}
+	return false
+}
+
+// Moves which follow the movement rules of the pieces but may leave the king in check
+func (g *GameState) pseudoLegalMoves(color PieceColor, origins Bitboard, moves []Move) []Move {
+	b := &g.board.bitboards
+	own, enemy := b.colors[color], b.colors[color.Opponent()]
+	occupied := own | enemy
+
+	addTargets := func(from int, targets Bitboard) {
+		for ; targets != 0; targets &= targets - 1 {
+			moves = append(moves, Move{
+				From: indexSquare(from),
+				To:   indexSquare(bits.TrailingZeros64(uint64(targets))),
+			})
+		}
+	}
+	forEach := func(t PieceType, fn func(from int)) {
+		for pieces := b.pieces(t, color) & origins; pieces != 0; pieces &= pieces - 1 {
+			fn(bits.TrailingZeros64(uint64(pieces)))
+		}
+	}
+
+	forEach(PieceType_Pawn, func(from int) {
+		moves = g.pawnMoves(color, from, enemy, occupied, moves)
+	})
+	forEach(PieceType_Knight, func(from int) {
+		addTargets(from, knightAttackTable[from]&^own)
+	})
+	forEach(PieceType_Bishop, func(from int) {
+		addTargets(from, bishopAttacks(from, occupied)&^own)
+	})
+	forEach(PieceType_Rook, func(from int) {
+		addTargets(from, rookAttacks(from, occupied)&^own)
+	})
+	forEach(PieceType_Queen, func(from int) {
+		addTargets(from, (rookAttacks(from, occupied)|bishopAttacks(from, occupied))&^own)
+	})
+	forEach(PieceType_King, func(from int) {
+		addTargets(from, kingAttackTable[from]&^own)
+		moves = g.castlingMoves(color, from, moves)
+	})
+	return moves
+}
+
+func (g *GameState) pawnMoves(color PieceColor, from int, enemy Bitboard, occupied Bitboard, moves []Move) []Move {
+	pawn := Pawn{pieceProps: pieceProps{PieceColor: color}}
+	fromSq := indexSquare(from)
+	promotionRank := pawn.promotionRank(g)
+	add := func(to Square) {
+		if to.Rank != promotionRank {
+			moves = append(moves, Move{From: fromSq, To: to})
+			return
+		}
+		for _, t := range pawn.promotablePieces() {
+			promotion := t
+			moves = append(moves, Move{From: fromSq, To: to, Promotion: &promotion})
+		}
+	}
+
+	// Pushes
+	delta := pawnDelta[color]
+	if push := fromSq.Adding(delta); g.board.ContainsSquare(push) && !occupied.Has(push) {
+		add(push)
+		if double := push.Adding(delta); fromSq.Rank == pawn.homeRank(g) &&
+			g.board.ContainsSquare(double) && !occupied.Has(double) {
+			add(double)
+		}
+	}
+
+	// Captures
+	for targets := pawnAttackTable[color][from] & enemy; targets != 0; targets &= targets - 1 {
+		add(indexSquare(bits.TrailingZeros64(uint64(targets))))
+	}
+
+	// En-passant captures the pawn standing on the target square
+	if target := g.enpassantTarget; target != nil && target.Rank == fromSq.Rank && abs(target.File-fromSq.File) == 1 &&
+		g.board.pieces(PieceType_Pawn, color.Opponent()).Has(*target) {
+		add(target.Adding(delta))
+	}
+	return moves
+}
+
+func (g *GameState) castlingMoves(color PieceColor, from int, moves []Move) []Move {
+	b := &g.board.bitboards
+	opponent := color.Opponent()
+	if b.isAttacked(from, opponent) {
+		return moves
+	}
+
+	for _, right := range g.castlingRights(color) {
+		if squareIndex(right.king) != from {
+			continue
+		}
+		kingTarget, rookTarget := right.kingTarget(), right.rookTarget()
+		// Apart from the castling king and rook, both of their paths must be empty
+		others := b.occupied() &^ (squareBit(right.king) | squareBit(right.rook))
+		if others&(squaresSpanning(right.king, kingTarget)|squaresSpanning(right.rook, rookTarget)) != 0 {
+			continue
+		}
+		// The king may not pass through or land on an attacked square
+		safe := true
+		for sq := range squaresSpanning(right.king, kingTarget).Squares() {
+			if b.isAttacked(squareIndex(sq), opponent) {
+				safe = false
+				break
+			}
+		}
+		if safe {
+			moves = append(moves, g.castlingMove(right))
+		}
+	}
+	return moves
+}
+
+// Plays the move on a copy of the piece placement and tests whether the king is attacked
+func (g *GameState) leavesKingSafe(move Move, color PieceColor) bool {
+	b := g.board.bitboards
+	from, to := squareIndex(move.From), squareIndex(move.To)
+	piece := g.board.squares[from]
+	switch piece.Type() {
+	case PieceType_Pawn:
+		if move.From.File != move.To.File && !b.occupied().Has(move.To) {
+			b.clear(squareIndex(Square{File: move.To.File, Rank: move.From.Rank}))
+		}
+	case PieceType_King:
+		// In Chess960 the rook may have shielded the king's destination
+		if right, ok := g.castlingRightOf(move); ok {
+			b.clear(from)
+			b.clear(squareIndex(right.rook))
+			b.set(squareIndex(right.rookTarget()), PieceType_Rook, color)
+			return !b.isAttacked(squareIndex(right.kingTarget()), color.Opponent())
+		}
+	}
+	b.clear(to)
+	b.clear(from)
+	b.set(to, piece.Type(), color)
+
+	kings := b.pieces(PieceType_King, color)
+	if kings == 0 {
+		return true
+	}
+	return !b.isAttacked(bits.TrailingZeros64(uint64(kings)), color.Opponent())
+}
+
+// Builds the position after a move produced by the generator
+func (g *GameState) applyMove(move Move) *GameState {
+	board := g.board.Clone()
+	piece, _ := board.GetPiece(move.From)
+	color := piece.Color()
+	params := AppendPosParams{}
+
+	switch piece.Type() {
+	case PieceType_Pawn:
+		params.pawnMove = true
+		params.enpassantTarget = Pawn{pieceProps: pieceProps{PieceColor: color}}.isAttackedEnPassant(move, g)
+		if move.From.File != move.To.File && !board.occupied().Has(move.To) {
+			board.clearSquare(Square{File: move.To.File, Rank: move.From.Rank})
+		}
+	case PieceType_King:
+		if right, ok := g.castlingRightOf(move); ok {
+			rook, _ := board.GetPiece(right.rook)
+			board.clearSquare(right.king)
+			board.clearSquare(right.rook)
+			board.setPiece(piece, right.kingTarget())
+			board.setPiece(rook, right.rookTarget())
+			return g.appendingPosition(board, move, params)
+		}
+	}
+
+	board.jumpPiece(move.From, move.To)
+	if move.Promotion != nil {
+		board.setPiece(NewPiece(*move.Promotion, color), move.To)
+	}
+	return g.appendingPosition(board, move, params)
+}
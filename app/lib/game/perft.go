@@ -0,0 +1,41 @@
+package game
+
+import (
+	"sort"
+)
+
+type PerftDivision struct {
+	Move  Move
+	Nodes int
+}
+
+// Perft counts the leaf positions of the legal move tree to the given depth. The counts
+// are well known for many positions, which makes them a check on move generation.
+func (g *GameState) Perft(depth int) int {
+	if depth <= 0 {
+		return 1
+	}
+	if depth == 1 {
+		return len(g.LegalMoves(g.MovingSide()))
+	}
+	nodes := 0
+	for _, plan := range g.PlanPossibleMovesForSide(g.MovingSide()) {
+		nodes += plan.Game.Perft(depth - 1)
+	}
+	return nodes
+}
+
+// PerftDivide splits the perft count by the first move, ordered by UCI notation
+func (g *GameState) PerftDivide(depth int) []PerftDivision {
+	var out []PerftDivision
+	if depth <= 0 {
+		return out
+	}
+	for _, plan := range g.PlanPossibleMovesForSide(g.MovingSide()) {
+		out = append(out, PerftDivision{Move: plan.Move, Nodes: plan.Game.Perft(depth - 1)})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Move.UCI() < out[j].Move.UCI()
+	})
+	return out
+}
@@ -0,0 +1,87 @@
+package game
+
+import (
+	"testing"
+)
+
+type perftPosition struct {
+	fen   string
+	nodes []int
+}
+
+// Counts from https://www.chessprogramming.org/Perft_Results, indexed by depth - 1
+var perftPositions = map[string]perftPosition{
+	"Starting position": {FEN_StartingPosition, []int{20, 400, 8902, 197281, 4865609}},
+	"Kiwipete":          {fenCorpus["Kiwipete"], []int{48, 2039, 97862, 4085603}},
+	"Position 3":        {fenCorpus["Rook endgame"], []int{14, 191, 2812, 43238, 674624}},
+	"Position 4":        {fenCorpus["Partial castling"], []int{6, 264, 9467, 422333}},
+	"Position 4 mirrored": {
+		"r2q1rk1/pP1p2pp/Q4n2/bbp1p3/Np6/1B3NBn/pPPP1PPP/R3K2R b KQ - 0 1",
+		[]int{6, 264, 9467, 422333},
+	},
+	"Position 5": {fenCorpus["Halfmove clock"], []int{44, 1486, 62379, 2103487}},
+	"Position 6": {fenCorpus["Middlegame"], []int{46, 2079, 89890, 3894594}},
+}
+
+func TestPerft(t *testing.T) {
+	for title, position := range perftPositions {
+		g := MustParseFEN(position.fen)
+		maxDepth := len(position.nodes)
+		if testing.Short() {
+			maxDepth = min(maxDepth, 3)
+		}
+		for depth := 1; depth <= maxDepth; depth++ {
+			if got, want := g.Perft(depth), position.nodes[depth-1]; got != want {
+				t.Errorf("%s: Perft(%d) got %d, want %d", title, depth, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestPerftDivide(t *testing.T) {
+	g := MustParseFEN(fenCorpus["Kiwipete"])
+	divisions := g.PerftDivide(2)
+	if len(divisions) != 48 {
+		t.Fatalf("PerftDivide(2) got %d moves, want %d", len(divisions), 48)
+	}
+
+	total := 0
+	for i, division := range divisions {
+		if i > 0 && divisions[i-1].Move.UCI() >= division.Move.UCI() {
+			t.Errorf("PerftDivide(2) not ordered: %s before %s", divisions[i-1].Move.UCI(), division.Move.UCI())
+		}
+		total += division.Nodes
+	}
+	if total != 2039 {
+		t.Errorf("PerftDivide(2) sums to %d, want %d", total, 2039)
+	}
+
+	// Queen-side castling walks the king over d1 and next to the rook on a1
+	for _, division := range divisions {
+		if division.Move.UCI() == "e1c1" && division.Nodes != 43 {
+			t.Errorf("PerftDivide(2) e1c1 got %d, want %d", division.Nodes, 43)
+		}
+	}
+}
+
+func TestPerftDepthZero(t *testing.T) {
+	g := NewGameState()
+	if got := g.Perft(0); got != 1 {
+		t.Errorf("Perft(0) got %d, want %d", got, 1)
+	}
+	if got := g.PerftDivide(0); len(got) != 0 {
+		t.Errorf("PerftDivide(0) got %v, want no moves", got)
+	}
+}
+
+func BenchmarkPerft(b *testing.B) {
+	for _, title := range []string{"Starting position", "Kiwipete", "Position 3"} {
+		g := MustParseFEN(perftPositions[title].fen)
+		b.Run(title, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				g.Perft(3)
+			}
+		})
+	}
+}
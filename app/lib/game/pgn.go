@@ -0,0 +1,172 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	pgnResultWhiteWins = "1-0"
+	pgnResultBlackWins = "0-1"
+	pgnResultDraw      = "1/2-1/2"
+	pgnResultUnknown   = "*"
+
+	pgnTerminationNormal       = "normal"
+	pgnTerminationTimeForfeit  = "time forfeit"
+	pgnTerminationUnterminated = "unterminated"
+	pgnTerminationAbandoned    = "abandoned"
+
+	pgnUnknownValue = "?"
+	pgnUnknownDate  = "????.??.??"
+	pgnDateFormat   = "2006.01.02"
+
+	pgnMaxLineLength = 80
+)
+
+type PGNTag struct {
+	Name  string
+	Value string
+}
+
+// PGN exports the game in Portable Game Notation. Tags passed in replace the defaults
+// of the same name, e.g. the players, and are otherwise appended after them.
+func (g *Game) PGN(tags ...PGNTag) string {
+	var sb strings.Builder
+	for _, tag := range g.pgnTags(tags) {
+		sb.WriteString(fmt.Sprintf("[%s \"%s\"]\n", tag.Name, pgnEscape(tag.Value)))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(g.pgnMovetext())
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// Helpers
+
+func (g *Game) pgnTags(overrides []PGNTag) []PGNTag {
+	date := pgnUnknownDate
+	if g.started {
+		date = g.startTime.UTC().Format(pgnDateFormat)
+	}
+
+	// Seven Tag Roster followed by supplemental tags
+	tags := []PGNTag{
+		{"Event", pgnUnknownValue},
+		{"Site", pgnUnknownValue},
+		{"Date", date},
+		{"Round", pgnUnknownValue},
+		{"White", pgnUnknownValue},
+		{"Black", pgnUnknownValue},
+		{"Result", g.pgnResult()},
+		{"TimeControl", g.control.pgnValue()},
+		{"Termination", g.pgnTermination()},
+	}
+	if variant := g.initial.Variant(); variant != Variant_Standard {
+		tags = append(tags, PGNTag{"Variant", variant.String()})
+	}
+	if fen := g.initial.FEN(); fen != FEN_StartingPosition || g.initial.Variant() != Variant_Standard {
+		tags = append(tags, PGNTag{"SetUp", "1"}, PGNTag{"FEN", fen})
+	}
+
+	for _, override := range overrides {
+		replaced := false
+		for i := range tags {
+			if tags[i].Name == override.Name {
+				tags[i].Value = override.Value
+				replaced = true
+			}
+		}
+		if !replaced {
+			tags = append(tags, override)
+		}
+	}
+	return tags
+}
+
+func (g *Game) pgnResult() string {
+	result, ended := g.Result()
+	if !ended || !g.started || result.Result == GameResult_Aborted {
+		return pgnResultUnknown
+	}
+	if result.Winner == nil {
+		return pgnResultDraw
+	}
+	if *result.Winner == PieceColor_White {
+		return pgnResultWhiteWins
+	}
+	return pgnResultBlackWins
+}
+
+func (g *Game) pgnTermination() string {
+	result, ended := g.Result()
+	if !ended || !g.started {
+		return pgnTerminationUnterminated
+	}
+	if result.Result == GameResult_Timeout || result.DrawReason == DrawReason_InusfficientMaterialTimeout {
+		return pgnTerminationTimeForfeit
+	}
+	if result.Result == GameResult_Aborted {
+		return pgnTerminationAbandoned
+	}
+	return pgnTerminationNormal
+}
+
+func (g *Game) pgnMovetext() string {
+	var tokens []string
+	for i, san := range g.sanMoves {
+		// Games set up from a position may start later on, or with black to move
+		ply := g.initial.NumMoves() + i
+		if ply%2 == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d.", ply/2+1))
+		} else if i == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d...", ply/2+1))
+		}
+		tokens = append(tokens, san)
+	}
+	tokens = append(tokens, g.pgnResult())
+	return pgnWrap(tokens)
+}
+
+// The stages as moves/seconds+increment, separated by colons. Delays and correspondence
+// deadlines have no notation.
+func (t TimeControl) pgnValue() string {
+	if t.IsCorrespondence() {
+		return "-"
+	}
+	var periods []string
+	for _, stage := range t.Stages() {
+		value := fmt.Sprint(int64(stage.Time.Seconds()))
+		if stage.Moves > 0 {
+			value = fmt.Sprintf("%d/%s", stage.Moves, value)
+		}
+		if stage.Increment > 0 {
+			value += fmt.Sprintf("+%d", int64(stage.Increment.Seconds()))
+		}
+		periods = append(periods, value)
+	}
+	return strings.Join(periods, ":")
+}
+
+func pgnWrap(tokens []string) string {
+	var sb strings.Builder
+	lineLength := 0
+	for i, token := range tokens {
+		if i > 0 {
+			if lineLength+1+len(token) > pgnMaxLineLength {
+				sb.WriteString("\n")
+				lineLength = 0
+			} else {
+				sb.WriteString(" ")
+				lineLength++
+			}
+		}
+		sb.WriteString(token)
+		lineLength += len(token)
+	}
+	return sb.String()
+}
+
+func pgnEscape(value string) string {
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	return strings.ReplaceAll(value, "\"", "\\\"")
+}
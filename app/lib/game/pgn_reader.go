@@ -0,0 +1,310 @@
+package game
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// A game read from PGN with its mainline replayed through the rules
+type PGNGame struct {
+	Tags    []PGNTag
+	Moves   []Move
+	Result  string
+	Initial *GameState
+	Final   *GameState
+}
+
+func (g *PGNGame) Tag(name string) (string, bool) {
+	for _, tag := range g.Tags {
+		if tag.Name == name {
+			return tag.Value, true
+		}
+	}
+	return "", false
+}
+
+// PGNMoveError reports the first move of a game which couldn't be replayed
+type PGNMoveError struct {
+	// 1 based index of the half-move in the mainline
+	Ply int
+	SAN string
+	Err error
+}
+
+func (e *PGNMoveError) Error() string {
+	return fmt.Sprintf("pgn: illegal move %s at ply %d: %v", e.SAN, e.Ply, e.Err)
+}
+
+func (e *PGNMoveError) Unwrap() error {
+	return e.Err
+}
+
+// PGNReader streams games out of a PGN database one at a time
+type PGNReader struct {
+	r       *bufio.Reader
+	last    rune
+	prev    rune
+	pending *pgnToken
+}
+
+func NewPGNReader(r io.Reader) *PGNReader {
+	return &PGNReader{r: bufio.NewReader(r), last: '\n'}
+}
+
+// ParsePGN reads the first game of a PGN string
+func ParsePGN(pgn string) (*PGNGame, error) {
+	return NewPGNReader(strings.NewReader(pgn)).Next()
+}
+
+// Next reads the following game, returning io.EOF once there are none left. Comments,
+// NAGs and variations are skipped. If the game can't be replayed, the rest of it is
+// consumed before the error is returned so that reading can carry on with the next game.
+func (r *PGNReader) Next() (*PGNGame, error) {
+	game := &PGNGame{}
+	var state *GameState
+	var gameErr error
+	inMovetext := false
+	ply := 0
+
+	for game.Result == "" {
+		token, err := r.nextToken()
+		if err == io.EOF {
+			if !inMovetext && len(game.Tags) == 0 {
+				return nil, io.EOF
+			}
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if token.tag != nil {
+			if inMovetext {
+				// Start of the next game which is missing a result in the previous one
+				r.pending = &token
+				break
+			}
+			game.Tags = append(game.Tags, *token.tag)
+			continue
+		}
+
+		if !inMovetext {
+			inMovetext = true
+			if state, err = game.initialState(); err != nil {
+				gameErr = err
+			}
+			game.Initial = state
+		}
+		if pgnResults[token.symbol] {
+			game.Result = token.symbol
+			break
+		}
+		san := pgnMoveNumberPattern.ReplaceAllString(token.symbol, "")
+		if san == "" || strings.Trim(san, "!?") == "" || gameErr != nil {
+			continue
+		}
+
+		ply++
+		plan, err := state.ParseSAN(san)
+		if err != nil {
+			gameErr = &PGNMoveError{Ply: ply, SAN: san, Err: err}
+			continue
+		}
+		game.Moves = append(game.Moves, plan.Move)
+		state = plan.Game
+	}
+
+	if gameErr != nil {
+		return nil, gameErr
+	}
+	if game.Initial == nil {
+		if game.Initial, gameErr = game.initialState(); gameErr != nil {
+			return nil, gameErr
+		}
+		state = game.Initial
+	}
+	if game.Result == "" {
+		game.Result = pgnResultUnknown
+		if result, ok := game.Tag("Result"); ok && pgnResults[result] {
+			game.Result = result
+		}
+	}
+	game.Final = state
+	return game, nil
+}
+
+// Helpers
+
+var (
+	pgnResults = map[string]bool{
+		pgnResultWhiteWins: true,
+		pgnResultBlackWins: true,
+		pgnResultDraw:      true,
+		pgnResultUnknown:   true,
+	}
+	pgnMoveNumberPattern = regexp.MustCompile(`^[0-9]+\.+`)
+)
+
+type pgnToken struct {
+	tag    *PGNTag
+	symbol string
+}
+
+func (g *PGNGame) initialState() (*GameState, error) {
+	variant := Variant_Standard
+	if name, ok := g.Tag("Variant"); ok {
+		if variant, ok = ParseVariant(name); !ok {
+			return nil, fmt.Errorf("pgn: unsupported variant %q", name)
+		}
+	}
+	fen, ok := g.Tag("FEN")
+	if !ok {
+		if variant == Variant_Chess960 {
+			return nil, fmt.Errorf("pgn: chess960 game without a FEN tag")
+		}
+		return NewGameState(), nil
+	}
+	state, err := ParseVariantFEN(fen, variant)
+	if err != nil {
+		return nil, fmt.Errorf("pgn: invalid FEN tag: %v", err)
+	}
+	return state, nil
+}
+
+func (r *PGNReader) readRune() (rune, error) {
+	ch, _, err := r.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	r.prev, r.last = r.last, ch
+	return ch, nil
+}
+
+func (r *PGNReader) unreadRune() {
+	if r.r.UnreadRune() == nil {
+		r.last = r.prev
+	}
+}
+
+// Returns the next tag or movetext symbol outside of comments and variations
+func (r *PGNReader) nextToken() (pgnToken, error) {
+	if r.pending != nil {
+		token := *r.pending
+		r.pending = nil
+		return token, nil
+	}
+
+	depth := 0
+	for {
+		lineStart := r.last == '\n'
+		ch, err := r.readRune()
+		if err != nil {
+			return pgnToken{}, err
+		}
+
+		switch {
+		case unicode.IsSpace(ch):
+		case ch == '%' && lineStart, ch == ';':
+			if err := r.skipPast('\n'); err != nil && err != io.EOF {
+				return pgnToken{}, err
+			}
+		case ch == '{':
+			if err := r.skipPast('}'); err != nil {
+				return pgnToken{}, fmt.Errorf("pgn: unterminated comment: %v", err)
+			}
+		case ch == '(':
+			depth++
+		case ch == ')':
+			if depth == 0 {
+				return pgnToken{}, fmt.Errorf("pgn: unbalanced variation")
+			}
+			depth--
+		case ch == '$':
+			r.readWhile(unicode.IsDigit)
+		case ch == '[':
+			tag, err := r.readTag()
+			if err != nil {
+				return pgnToken{}, err
+			}
+			if depth == 0 {
+				return pgnToken{tag: tag}, nil
+			}
+		case ch == '*':
+			if depth == 0 {
+				return pgnToken{symbol: pgnResultUnknown}, nil
+			}
+		default:
+			symbol := string(ch) + r.readWhile(isPGNSymbolRune)
+			if depth == 0 {
+				return pgnToken{symbol: symbol}, nil
+			}
+		}
+	}
+}
+
+func (r *PGNReader) readTag() (*PGNTag, error) {
+	r.readWhile(unicode.IsSpace)
+	name := r.readWhile(isPGNSymbolRune)
+	r.readWhile(unicode.IsSpace)
+	if ch, err := r.readRune(); err != nil || ch != '"' || name == "" {
+		return nil, fmt.Errorf("pgn: malformed tag %q", name)
+	}
+
+	var value strings.Builder
+	for escaped := false; ; {
+		ch, err := r.readRune()
+		if err != nil {
+			return nil, fmt.Errorf("pgn: unterminated value for tag %s", name)
+		}
+		if escaped {
+			value.WriteRune(ch)
+			escaped = false
+		} else if ch == '\\' {
+			escaped = true
+		} else if ch == '"' {
+			break
+		} else {
+			value.WriteRune(ch)
+		}
+	}
+
+	if err := r.skipPast(']'); err != nil {
+		return nil, fmt.Errorf("pgn: unterminated tag %s", name)
+	}
+	return &PGNTag{Name: name, Value: value.String()}, nil
+}
+
+func (r *PGNReader) readWhile(accept func(rune) bool) string {
+	var sb strings.Builder
+	for {
+		ch, err := r.readRune()
+		if err != nil {
+			return sb.String()
+		}
+		if !accept(ch) {
+			r.unreadRune()
+			return sb.String()
+		}
+		sb.WriteRune(ch)
+	}
+}
+
+func (r *PGNReader) skipPast(end rune) error {
+	for {
+		ch, err := r.readRune()
+		if err != nil {
+			return err
+		}
+		if ch == end {
+			return nil
+		}
+	}
+}
+
+func isPGNSymbolRune(ch rune) bool {
+	return unicode.IsLetter(ch) || unicode.IsDigit(ch) || strings.ContainsRune("_+#=:-/.!?", ch)
+}
@@ -0,0 +1,173 @@
+package game
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+const pgnDatabase = `[Event "Casual game"]
+[Site "?"]
+[Date "2024.03.01"]
+[Round "?"]
+[White "Alice"]
+[Black "Bob \"The Blunderer\""]
+[Result "1-0"]
+
+1. e4 {King's pawn} e5 2. Bc4 $1 Nc6 (2... Nf6 3. d3 (3. Nc3) Bc5) 3. Qh5!?
+Nf6?? ; Missed the threat
+4. Qxf7# 1-0
+
+% An escaped line that is ignored [Event "Bogus"]
+[Event "Fool's mate"]
+[Result "0-1"]
+
+1.f3 e5 2.g4 Qh4# 0-1
+
+[Event "Illegal game"]
+[Result "*"]
+
+1. e4 e5 2. Ke3 Nf6 3. Nf3 *
+
+[Event "From position"]
+[SetUp "1"]
+[FEN "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1"]
+[Result "*"]
+
+1... O-O-O 2. O-O Rd2 *
+
+[Event "Missing result"]
+
+1. d4 d5
+[Event "Last game"]
+
+1. c4 *
+`
+
+func TestPGNReader(t *testing.T) {
+	r := NewPGNReader(strings.NewReader(pgnDatabase))
+
+	game, err := r.Next()
+	if err != nil {
+		t.Fatalf("Next() first game errored: %v", err)
+	}
+	if value, _ := game.Tag("Black"); value != `Bob "The Blunderer"` {
+		t.Errorf("Tag(Black) got %s, want %s", value, `Bob "The Blunderer"`)
+	}
+	if len(game.Tags) != 7 {
+		t.Errorf("first game got %d tags, want %d", len(game.Tags), 7)
+	}
+	assertPGNGame("Scholar's mate", game, 7, "1-0",
+		"r1bqkb1r/pppp1Qpp/2n2n2/4p3/2B1P3/8/PPPP1PPP/RNB1K1NR b KQkq - 0 4", t)
+
+	game, err = r.Next()
+	if err != nil {
+		t.Fatalf("Next() second game errored: %v", err)
+	}
+	if value, _ := game.Tag("Event"); value != "Fool's mate" {
+		t.Errorf("escaped line was not skipped, got event %s", value)
+	}
+	assertPGNGame("Fool's mate", game, 4, "0-1",
+		"rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3", t)
+
+	_, err = r.Next()
+	var moveErr *PGNMoveError
+	if !errors.As(err, &moveErr) {
+		t.Fatalf("Next() illegal game got error %v, want a PGNMoveError", err)
+	}
+	if moveErr.Ply != 3 || moveErr.SAN != "Ke3" {
+		t.Errorf("PGNMoveError got ply %d move %s, want ply %d move %s", moveErr.Ply, moveErr.SAN, 3, "Ke3")
+	}
+
+	game, err = r.Next()
+	if err != nil {
+		t.Fatalf("Next() game from position errored: %v", err)
+	}
+	assertPGNGame("Game from position", game, 3, "*", "2k4r/8/8/8/8/8/3r4/R4RK1 w - - 3 3", t)
+	if game.Initial.FEN() != "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1" {
+		t.Errorf("Initial got %s, want the FEN tag", game.Initial.FEN())
+	}
+
+	game, err = r.Next()
+	if err != nil {
+		t.Fatalf("Next() game missing result errored: %v", err)
+	}
+	assertPGNGame("Game missing result", game, 2, "*",
+		"rnbqkbnr/ppp1pppp/8/3p4/3P4/8/PPP1PPPP/RNBQKBNR w KQkq - 0 2", t)
+
+	game, err = r.Next()
+	if err != nil {
+		t.Fatalf("Next() last game errored: %v", err)
+	}
+	if value, _ := game.Tag("Event"); value != "Last game" {
+		t.Errorf("last game got event %s, want %s", value, "Last game")
+	}
+
+	if _, err = r.Next(); err != io.EOF {
+		t.Errorf("Next() after last game got %v, want %v", err, io.EOF)
+	}
+}
+
+func TestPGNRoundTrip(t *testing.T) {
+	g := NewGame(TimeControl_Thirty)
+	g.Start()
+	moves := []testMove{
+		{"e2", "e4"}, {"d7", "d5"}, {"e4", "e5"}, {"f7", "f5"}, {"e5", "f6"},
+		{"g8", "h6"}, {"f6", "g7"}, {"e8", "f7"}, {"g7", "h8"},
+	}
+	for _, move := range moves {
+		m := move.Move()
+		if move.to == "h8" {
+			m.Promotion = pieceTypePtr(PieceType_Knight)
+		}
+		if err := g.Move(m); err != nil {
+			t.Fatalf("Move(%v) failed unexpectedly: %v", m, err)
+		}
+	}
+
+	parsed, err := ParsePGN(g.PGN())
+	if err != nil {
+		t.Fatalf("ParsePGN() of exported game errored: %v", err)
+	}
+	if len(parsed.Moves) != len(g.moves) {
+		t.Fatalf("ParsePGN() got %d moves, want %d", len(parsed.Moves), len(g.moves))
+	}
+	for i, move := range parsed.Moves {
+		if !sameMove(move, g.moves[i]) {
+			t.Errorf("ParsePGN() move %d got %v, want %v", i, move, g.moves[i])
+		}
+	}
+	if parsed.Final.FEN() != g.state.FEN() {
+		t.Errorf("ParsePGN() final position got %s, want %s", parsed.Final.FEN(), g.state.FEN())
+	}
+}
+
+func TestPGNReaderRejectsMalformed(t *testing.T) {
+	tests := map[string]string{
+		"Unterminated tag":     `[Event "Broken`,
+		"Unterminated comment": `1. e4 { never closed`,
+		"Unbalanced variation": `1. e4 ) e5 *`,
+		"Invalid FEN tag":      "[FEN \"8/8/8/8 w - - 0 1\"]\n\n1. e4 *",
+	}
+
+	for title, pgn := range tests {
+		if _, err := ParsePGN(pgn); err == nil {
+			t.Errorf("%s: ParsePGN() was accepted", title)
+		}
+	}
+}
+
+// Helpers
+
+func assertPGNGame(title string, game *PGNGame, numMoves int, result string, fen string, t *testing.T) {
+	if len(game.Moves) != numMoves {
+		t.Errorf("%s: got %d moves, want %d", title, len(game.Moves), numMoves)
+	}
+	if game.Result != result {
+		t.Errorf("%s: got result %s, want %s", title, game.Result, result)
+	}
+	if got := game.Final.FEN(); got != fen {
+		t.Errorf("%s: got final position %s, want %s", title, got, fen)
+	}
+}
@@ -0,0 +1,128 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPGNExport(t *testing.T) {
+	g := NewGame(TimeControl_ThreeTwo)
+	g.Start()
+	moves := []testMove{
+		{"e2", "e4"}, {"e7", "e5"},
+		{"f1", "c4"}, {"b8", "c6"},
+		{"d1", "h5"}, {"g8", "f6"},
+		{"h5", "f7"},
+	}
+	for _, move := range moves {
+		if err := g.Move(move.Move()); err != nil {
+			t.Fatalf("Move(%v) failed unexpectedly: %v", move, err)
+		}
+	}
+
+	got := g.PGN(
+		PGNTag{"Date", "2024.03.01"},
+		PGNTag{"White", "Alice"},
+		PGNTag{"Black", "Bob \"The Blunderer\""},
+		PGNTag{"Annotator", "gochess"},
+	)
+	want := strings.Join([]string{
+		`[Event "?"]`,
+		`[Site "?"]`,
+		`[Date "2024.03.01"]`,
+		`[Round "?"]`,
+		`[White "Alice"]`,
+		`[Black "Bob \"The Blunderer\""]`,
+		`[Result "1-0"]`,
+		`[TimeControl "180+2"]`,
+		`[Termination "normal"]`,
+		`[Annotator "gochess"]`,
+		``,
+		`1. e4 e5 2. Bc4 Nc6 3. Qh5 Nf6 4. Qxf7# 1-0`,
+		``,
+	}, "\n")
+
+	if got != want {
+		t.Errorf("PGN() got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPGNExportResults(t *testing.T) {
+	tests := map[string]struct {
+		end         func(g *Game)
+		result      string
+		termination string
+	}{
+		"In progress": {
+			end:         func(g *Game) {},
+			result:      "*",
+			termination: "unterminated",
+		},
+		"Resignation": {
+			end:         func(g *Game) { g.Resign(PieceColor_White) },
+			result:      "0-1",
+			termination: "normal",
+		},
+		"Draw by agreement": {
+			end:         func(g *Game) { g.AgreeDraw() },
+			result:      "1/2-1/2",
+			termination: "normal",
+		},
+		"Timeout": {
+			end: func(g *Game) {
+				g.clocks[PieceColor_White] = NewClock(0)
+			},
+			result:      "0-1",
+			termination: "time forfeit",
+		},
+	}
+
+	for title, test := range tests {
+		g := NewGame(TimeControl_Five)
+		g.Start()
+		test.end(g)
+		pgn := g.PGN()
+
+		if want := `[Result "` + test.result + `"]`; !strings.Contains(pgn, want) {
+			t.Errorf("%s: PGN() missing %s in:\n%s", title, want, pgn)
+		}
+		if want := `[Termination "` + test.termination + `"]`; !strings.Contains(pgn, want) {
+			t.Errorf("%s: PGN() missing %s in:\n%s", title, want, pgn)
+		}
+		if want := `[TimeControl "300"]`; !strings.Contains(pgn, want) {
+			t.Errorf("%s: PGN() missing %s in:\n%s", title, want, pgn)
+		}
+		if !strings.HasSuffix(pgn, test.result+"\n") {
+			t.Errorf("%s: PGN() movetext does not end with %s:\n%s", title, test.result, pgn)
+		}
+	}
+}
+
+func TestPGNExportTimeStages(t *testing.T) {
+	g := NewGame(TimeControl_Classical)
+	if want := `[TimeControl "40/5400+30:1800+30"]`; !strings.Contains(g.PGN(), want) {
+		t.Errorf("PGN() missing %s:\n%s", want, g.PGN())
+	}
+}
+
+func TestPGNExportWrapsLongMovetext(t *testing.T) {
+	g := NewGame(TimeControl_Thirty)
+	g.Start()
+	for i := 0; i < 20; i++ {
+		for _, move := range []testMove{{"g1", "f3"}, {"g8", "f6"}, {"f3", "g1"}, {"f6", "g8"}} {
+			if !g.InProgress() {
+				break
+			}
+			if err := g.Move(move.Move()); err != nil {
+				t.Fatalf("Move(%v) failed unexpectedly: %v", move, err)
+			}
+		}
+	}
+
+	movetext := strings.SplitN(g.PGN(), "\n\n", 2)[1]
+	for _, line := range strings.Split(strings.TrimSpace(movetext), "\n") {
+		if len(line) > pgnMaxLineLength {
+			t.Errorf("PGN() movetext line exceeds %d characters: %s", pgnMaxLineLength, line)
+		}
+	}
+}
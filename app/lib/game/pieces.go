@@ -3,6 +3,7 @@ package game
 import (
 	"fmt"
 	"math/rand"
+	"unicode"
 )
 
 type Piece interface {
@@ -53,6 +54,31 @@ const (
 	PieceType_Pawn
 )
 
+var pieceTypeSymbols = map[PieceType]rune{
+	PieceType_King:   'K',
+	PieceType_Queen:  'Q',
+	PieceType_Rook:   'R',
+	PieceType_Bishop: 'B',
+	PieceType_Knight: 'N',
+	PieceType_Pawn:   'P',
+}
+
+// Symbol returns the upper case English letter used for the piece type in FEN & SAN
+func (p PieceType) Symbol() rune {
+	return pieceTypeSymbols[p]
+}
+
+// ParsePieceType maps an English piece letter of either case to its type
+func ParsePieceType(symbol rune) (PieceType, bool) {
+	upper := unicode.ToUpper(symbol)
+	for t, s := range pieceTypeSymbols {
+		if s == upper {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
 type pieceProps struct {
 	PieceColor PieceColor
 	PieceId    PieceId
@@ -0,0 +1,178 @@
+package game
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	sanKingSideCastle  = "O-O"
+	sanQueenSideCastle = "O-O-O"
+)
+
+// Piece, origin file, origin rank, capture, destination and promotion
+var sanPattern = regexp.MustCompile(`^([KQRBN])?([a-h])?([1-8])?(x)?([a-h][1-8])(?:=?([QRBNqrbn]))?$`)
+
+// MoveSAN validates a move against the position and returns its Standard Algebraic Notation
+func (g *GameState) MoveSAN(move Move) (string, error) {
+	next, err := g.WithMove(move)
+	if err != nil {
+		return "", err
+	}
+	return g.SAN(MovePlan{Move: move, Game: next}), nil
+}
+
+// SAN returns the Standard Algebraic Notation of a legal move planned from this position
+func (g *GameState) SAN(plan MovePlan) string {
+	return g.sanWithoutSuffix(plan) + plan.Game.sanSuffix()
+}
+
+// ParseSAN finds the legal move described by Standard Algebraic Notation. Check and
+// annotation suffixes are ignored, as is superfluous disambiguation.
+func (g *GameState) ParseSAN(san string) (*MovePlan, error) {
+	notation := strings.TrimRight(san, "+#!?")
+	notation = strings.ReplaceAll(notation, "0", "O")
+
+	var matches []MovePlan
+	if notation == sanKingSideCastle || notation == sanQueenSideCastle {
+		for _, plan := range g.PlanPossibleMovesForSide(g.MovingSide()) {
+			if g.sanWithoutSuffix(plan) == notation {
+				matches = append(matches, plan)
+			}
+		}
+	} else {
+		parts := sanPattern.FindStringSubmatch(notation)
+		if parts == nil {
+			return nil, fmt.Errorf("san: invalid notation %q", san)
+		}
+		pieceType := PieceType_Pawn
+		if parts[1] != "" {
+			pieceType, _ = ParsePieceType(rune(parts[1][0]))
+		}
+		to := MustSquare(parts[5])
+		for _, plan := range g.PlanPossibleMovesForSide(g.MovingSide()) {
+			piece, _ := g.Board().GetPiece(plan.From)
+			if piece.Type() != pieceType || plan.To != to || g.isCastlingMove(plan.Move) {
+				continue
+			}
+			if parts[2] != "" && plan.From.File != int(parts[2][0]-'a') {
+				continue
+			}
+			if parts[3] != "" && plan.From.Rank != int(parts[3][0]-'1') {
+				continue
+			}
+			if !sanPromotionMatches(plan.Promotion, parts[6]) {
+				continue
+			}
+			matches = append(matches, plan)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("san: no legal move matches %q", san)
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, fmt.Errorf("san: ambiguous move %q", san)
+	}
+}
+
+// Helpers
+
+func sanPromotionMatches(promotion *PieceType, symbol string) bool {
+	if symbol == "" {
+		return promotion == nil
+	}
+	t, _ := ParsePieceType(rune(symbol[0]))
+	return promotion != nil && *promotion == t
+}
+
+func (g *GameState) sanWithoutSuffix(plan MovePlan) string {
+	from, to := plan.From, plan.To
+	piece, exists := g.Board().GetPiece(from)
+	if !exists {
+		return ""
+	}
+
+	if g.isCastlingMove(plan.Move) {
+		if to.File > from.File {
+			return sanKingSideCastle
+		}
+		return sanQueenSideCastle
+	}
+
+	var sb strings.Builder
+	_, isCapture := g.Board().GetPiece(to)
+	if piece.Type() == PieceType_Pawn {
+		// Diagonal pawn moves are always captures, including en-passant
+		if from.File != to.File {
+			sb.WriteByte(byte('a' + from.File))
+			isCapture = true
+		}
+	} else {
+		sb.WriteRune(piece.Type().Symbol())
+		sb.WriteString(g.sanDisambiguation(piece, from, to))
+	}
+	if isCapture {
+		sb.WriteByte('x')
+	}
+	sb.WriteString(to.String())
+	if plan.Promotion != nil {
+		sb.WriteString(fmt.Sprintf("=%c", plan.Promotion.Symbol()))
+	}
+	return sb.String()
+}
+
+// Returns the origin file, rank or square needed to tell apart pieces of the same type
+// which can legally reach the same square
+func (g *GameState) sanDisambiguation(piece Piece, from Square, to Square) string {
+	sameFile, sameRank, ambiguous := false, false, false
+	for sq, other := range g.Board().Pieces() {
+		if sq == from || other.Color() != piece.Color() || other.Type() != piece.Type() {
+			continue
+		}
+		if _, err := g.WithMove(Move{From: sq, To: to}); err != nil {
+			continue
+		}
+		ambiguous = true
+		sameFile = sameFile || sq.File == from.File
+		sameRank = sameRank || sq.Rank == from.Rank
+	}
+
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return string(rune('a' + from.File))
+	case !sameRank:
+		return fmt.Sprint(from.Rank + 1)
+	default:
+		return from.String()
+	}
+}
+
+// Returns the check or checkmate indicator for the side to move in this position
+func (g *GameState) sanSuffix() string {
+	side := g.MovingSide()
+	if !g.IsSideInCheck(side) {
+		return ""
+	}
+	if !g.hasLegalMove(side) {
+		return "#"
+	}
+	return "+"
+}
+
+func (g *GameState) isCastlingMove(move Move) bool {
+	_, ok := g.castlingRightOf(move)
+	return ok
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,231 @@
+package game
+
+import (
+	"testing"
+)
+
+func TestMoveSAN(t *testing.T) {
+	tests := map[string]struct {
+		fen  string
+		move Move
+		want string
+	}{
+		"Pawn push": {
+			fen:  FEN_StartingPosition,
+			move: Move{From: sq("e2"), To: sq("e4")},
+			want: "e4",
+		},
+		"Piece move": {
+			fen:  FEN_StartingPosition,
+			move: Move{From: sq("g1"), To: sq("f3")},
+			want: "Nf3",
+		},
+		"File disambiguation": {
+			fen:  "k7/8/8/8/8/8/8/1N3N1K w - - 0 1",
+			move: Move{From: sq("b1"), To: sq("d2")},
+			want: "Nbd2",
+		},
+		"Rank disambiguation": {
+			fen:  "k7/8/8/N7/8/8/8/N6K w - - 0 1",
+			move: Move{From: sq("a1"), To: sq("b3")},
+			want: "N1b3",
+		},
+		"Square disambiguation": {
+			fen:  "4k3/8/8/8/8/Q7/8/Q1Q4K w - - 0 1",
+			move: Move{From: sq("a1"), To: sq("b2")},
+			want: "Qa1b2",
+		},
+		"No disambiguation for a pinned piece": {
+			fen:  "k3r3/8/8/8/8/8/4N3/1N2K3 w - - 0 1",
+			move: Move{From: sq("b1"), To: sq("c3")},
+			want: "Nc3",
+		},
+		"Piece capture": {
+			fen:  "k7/8/8/3p4/8/2N5/8/K7 w - - 0 1",
+			move: Move{From: sq("c3"), To: sq("d5")},
+			want: "Nxd5",
+		},
+		"Pawn capture": {
+			fen:  "k7/8/8/3p4/4P3/8/8/K7 w - - 0 1",
+			move: Move{From: sq("e4"), To: sq("d5")},
+			want: "exd5",
+		},
+		"En-passant capture": {
+			fen:  "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+			move: Move{From: sq("e5"), To: sq("f6")},
+			want: "exf6",
+		},
+		"King-side castling": {
+			fen:  "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1",
+			move: Move{From: sq("e1"), To: sq("g1")},
+			want: "O-O",
+		},
+		"Queen-side castling": {
+			fen:  "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1",
+			move: Move{From: sq("e8"), To: sq("c8")},
+			want: "O-O-O",
+		},
+		"Promotion with check": {
+			fen:  "k7/4P3/8/8/8/8/8/K7 w - - 0 1",
+			move: Move{From: sq("e7"), To: sq("e8"), Promotion: pieceTypePtr(PieceType_Queen)},
+			want: "e8=Q+",
+		},
+		"Under-promotion": {
+			fen:  "k7/4P3/8/8/8/8/8/K7 w - - 0 1",
+			move: Move{From: sq("e7"), To: sq("e8"), Promotion: pieceTypePtr(PieceType_Knight)},
+			want: "e8=N",
+		},
+		"Capturing promotion": {
+			fen:  "3r3k/4P3/8/8/8/8/8/K7 w - - 0 1",
+			move: Move{From: sq("e7"), To: sq("d8"), Promotion: pieceTypePtr(PieceType_Rook)},
+			want: "exd8=R+",
+		},
+		"Checkmate": {
+			fen:  "rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq - 0 2",
+			move: Move{From: sq("d8"), To: sq("h4")},
+			want: "Qh4#",
+		},
+	}
+
+	for title, test := range tests {
+		g := MustParseFEN(test.fen)
+		got, err := g.MoveSAN(test.move)
+		if err != nil {
+			t.Errorf("%s: MoveSAN(%v) errored: %v", title, test.move, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: MoveSAN(%v) got %s, want %s", title, test.move, got, test.want)
+		}
+	}
+}
+
+func TestMoveSANRejectsIllegalMoves(t *testing.T) {
+	g := NewGameState()
+	move := Move{From: sq("e2"), To: sq("e5")}
+	if san, err := g.MoveSAN(move); err == nil {
+		t.Errorf("MoveSAN(%v) accepted an illegal move: %s", move, san)
+	}
+}
+
+// Helpers
+
+func pieceTypePtr(t PieceType) *PieceType {
+	return &t
+}
+
+func TestParseSAN(t *testing.T) {
+	tests := map[string]struct {
+		fen  string
+		san  string
+		want Move
+	}{
+		"Pawn push": {
+			fen:  FEN_StartingPosition,
+			san:  "e4",
+			want: Move{From: sq("e2"), To: sq("e4")},
+		},
+		"Piece move with check suffix and annotation": {
+			fen:  "k7/8/8/8/8/8/8/K2R4 w - - 0 1",
+			san:  "Rd8+!?",
+			want: Move{From: sq("d1"), To: sq("d8")},
+		},
+		"File disambiguation": {
+			fen:  "k7/8/8/8/8/8/8/1N3N1K w - - 0 1",
+			san:  "Nfd2",
+			want: Move{From: sq("f1"), To: sq("d2")},
+		},
+		"Superfluous disambiguation": {
+			fen:  FEN_StartingPosition,
+			san:  "Ng1f3",
+			want: Move{From: sq("g1"), To: sq("f3")},
+		},
+		"Capture without marker": {
+			fen:  "k7/8/8/3p4/4P3/8/8/K7 w - - 0 1",
+			san:  "ed5",
+			want: Move{From: sq("e4"), To: sq("d5")},
+		},
+		"En-passant capture": {
+			fen:  "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+			san:  "exf6",
+			want: Move{From: sq("e5"), To: sq("f6")},
+		},
+		"Castling": {
+			fen:  "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1",
+			san:  "O-O-O",
+			want: Move{From: sq("e8"), To: sq("c8")},
+		},
+		"Castling with zeros": {
+			fen:  "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1",
+			san:  "0-0",
+			want: Move{From: sq("e1"), To: sq("g1")},
+		},
+		"Promotion": {
+			fen:  "k7/4P3/8/8/8/8/8/K7 w - - 0 1",
+			san:  "e8=N",
+			want: Move{From: sq("e7"), To: sq("e8"), Promotion: pieceTypePtr(PieceType_Knight)},
+		},
+		"Promotion without equals sign": {
+			fen:  "k7/4P3/8/8/8/8/8/K7 w - - 0 1",
+			san:  "e8Q+",
+			want: Move{From: sq("e7"), To: sq("e8"), Promotion: pieceTypePtr(PieceType_Queen)},
+		},
+	}
+
+	for title, test := range tests {
+		g := MustParseFEN(test.fen)
+		plan, err := g.ParseSAN(test.san)
+		if err != nil {
+			t.Errorf("%s: ParseSAN(%s) errored: %v", title, test.san, err)
+			continue
+		}
+		if !sameMove(plan.Move, test.want) {
+			t.Errorf("%s: ParseSAN(%s) got %v, want %v", title, test.san, plan.Move, test.want)
+		}
+	}
+}
+
+func TestParseSANRejectsInvalid(t *testing.T) {
+	tests := map[string]struct {
+		fen string
+		san string
+	}{
+		"Garbage":                {FEN_StartingPosition, "hello"},
+		"Illegal move":           {FEN_StartingPosition, "e5"},
+		"Wrong piece":            {FEN_StartingPosition, "Bf3"},
+		"Ambiguous move":         {"k7/8/8/8/8/8/8/1N3N1K w - - 0 1", "Nd2"},
+		"Missing promotion":      {"k7/4P3/8/8/8/8/8/K7 w - - 0 1", "e8"},
+		"Castling without right": {"r3k2r/8/8/8/8/8/8/R3K2R w kq - 0 1", "O-O"},
+	}
+
+	for title, test := range tests {
+		g := MustParseFEN(test.fen)
+		if plan, err := g.ParseSAN(test.san); err == nil {
+			t.Errorf("%s: ParseSAN(%s) was accepted as %v", title, test.san, plan.Move)
+		}
+	}
+}
+
+func TestSANRoundTrip(t *testing.T) {
+	for title, fen := range fenCorpus {
+		g := MustParseFEN(fen)
+		for _, plan := range g.PlanPossibleMovesForSide(g.MovingSide()) {
+			san := g.SAN(plan)
+			parsed, err := g.ParseSAN(san)
+			if err != nil {
+				t.Errorf("%s: ParseSAN(%s) errored: %v", title, san, err)
+				continue
+			}
+			if !sameMove(parsed.Move, plan.Move) {
+				t.Errorf("%s: ParseSAN(%s) got %v, want %v", title, san, parsed.Move, plan.Move)
+			}
+		}
+	}
+}
+
+func sameMove(a Move, b Move) bool {
+	if a.From != b.From || a.To != b.To || (a.Promotion == nil) != (b.Promotion == nil) {
+		return false
+	}
+	return a.Promotion == nil || *a.Promotion == *b.Promotion
+}
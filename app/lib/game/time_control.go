@@ -1,17 +1,167 @@
 package game
 
 import (
+	"slices"
+	"strings"
 	"time"
 )
 
+// TimeControl gives each side the total time with the increment or delay for the first
+// stage of the game. Classical controls follow it with later stages once the moves of the
+// first are made, e.g. 40 moves in 90 minutes then 30 minutes for the rest of the game.
 type TimeControl struct {
 	Total     time.Duration
 	Increment time.Duration
+	// Allowed on every move before it costs time, in place of an increment
+	Delay     time.Duration
+	DelayMode DelayMode
+	// Moves each side makes in the first stage, when later stages follow
+	Moves int
+	// Stages after the first, in order
+	Later []TimeStage
+	// Days each side has for every move of a correspondence game, in place of the times
+	// above, with the days of vacation each side may take over the game
+	DaysPerMove  int
+	VacationDays int
 }
 
+// TimeStage is one period of a time control
+type TimeStage struct {
+	// Moves each side makes in the stage, none for the last which lasts the rest of the game
+	Moves int
+	// Added to each clock as the stage begins
+	Time      time.Duration
+	Increment time.Duration
+	Delay     time.Duration
+	DelayMode DelayMode
+}
+
+const (
+	// Most stages a time control may have after the first
+	maxLaterTimeStages = 4
+
+	maxDaysPerMove  = 14
+	maxVacationDays = 30
+)
+
+// DelayMode decides how the delay saves time on each move
+type DelayMode int
+
+const (
+	DelayMode_None DelayMode = iota
+	// Simple or US delay, the clock only starts counting down once the delay has passed
+	DelayMode_Simple
+	// The clock counts down from the start of the move, and the time used is given back at
+	// the end of it, up to the delay
+	DelayMode_Bronstein
+)
+
 func (t TimeControl) Validate() bool {
-	return t.Total >= 1*time.Minute && t.Total <= 24*time.Hour &&
-		t.Increment >= 0 && t.Increment <= 2*time.Minute
+	if t.IsCorrespondence() {
+		return t.DaysPerMove <= maxDaysPerMove &&
+			t.VacationDays >= 0 && t.VacationDays <= maxVacationDays &&
+			t.Total == 0 && t.Increment == 0 && t.Delay == 0 && t.DelayMode == DelayMode_None &&
+			t.Moves == 0 && len(t.Later) == 0
+	}
+	if t.Total < 1*time.Minute || t.DaysPerMove < 0 || t.VacationDays != 0 || len(t.Later) > maxLaterTimeStages {
+		return false
+	}
+	stages := t.Stages()
+	for i, stage := range stages {
+		// Only the last stage lasts the rest of the game
+		last := i == len(stages)-1
+		if !stage.valid() || (stage.Moves == 0) != last {
+			return false
+		}
+	}
+	return true
+}
+
+// IsCorrespondence reports whether each move has a deadline of days rather than the game
+// being played against the clock
+func (t TimeControl) IsCorrespondence() bool {
+	return t.DaysPerMove > 0
+}
+
+// MoveTime returns the time each side has for every move of a correspondence game
+func (t TimeControl) MoveTime() time.Duration {
+	return time.Duration(t.DaysPerMove) * 24 * time.Hour
+}
+
+// VacationTime returns the vacation each side may take over a correspondence game
+func (t TimeControl) VacationTime() time.Duration {
+	return time.Duration(t.VacationDays) * 24 * time.Hour
+}
+
+// Stages returns every stage of the control in order, starting with the first. The one
+// stage of a correspondence game is the time for each move.
+func (t TimeControl) Stages() []TimeStage {
+	if t.IsCorrespondence() {
+		return []TimeStage{{Time: t.MoveTime()}}
+	}
+	first := TimeStage{
+		Moves:     t.Moves,
+		Time:      t.Total,
+		Increment: t.Increment,
+		Delay:     t.Delay,
+		DelayMode: t.DelayMode,
+	}
+	return append([]TimeStage{first}, t.Later...)
+}
+
+// StageOf returns the index of the stage in which a side makes its nth move, counting
+// moves from one
+func (t TimeControl) StageOf(move int) int {
+	stages := t.Stages()
+	for i, stage := range stages {
+		if stage.Moves == 0 || move <= stage.Moves {
+			return i
+		}
+		move -= stage.Moves
+	}
+	return len(stages) - 1
+}
+
+// Equal reports whether both controls give the same time, as when pairing players
+func (t TimeControl) Equal(other TimeControl) bool {
+	return t.Total == other.Total && t.Increment == other.Increment &&
+		t.Delay == other.Delay && t.DelayMode == other.DelayMode &&
+		t.Moves == other.Moves && slices.Equal(t.Later, other.Later) &&
+		t.DaysPerMove == other.DaysPerMove && t.VacationDays == other.VacationDays
+}
+
+func (s TimeStage) valid() bool {
+	return s.Moves >= 0 && s.Time >= 0 && s.Time <= 24*time.Hour &&
+		s.Increment >= 0 && s.Increment <= 2*time.Minute &&
+		s.Delay >= 0 && s.Delay <= 2*time.Minute &&
+		s.DelayMode >= DelayMode_None && s.DelayMode <= DelayMode_Bronstein &&
+		// A delay needs a mode, and replaces the increment
+		(s.Delay > 0) == (s.DelayMode != DelayMode_None) &&
+		(s.Delay == 0 || s.Increment == 0)
+}
+
+func (m DelayMode) String() string {
+	switch m {
+	case DelayMode_Simple:
+		return "simple"
+	case DelayMode_Bronstein:
+		return "bronstein"
+	default:
+		return "none"
+	}
+}
+
+func ParseDelayMode(name string) (DelayMode, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "none":
+		return DelayMode_None, true
+	case "simple", "us":
+		return DelayMode_Simple, true
+	case "bronstein":
+		return DelayMode_Bronstein, true
+	default:
+		return DelayMode_None, false
+	}
 }
 
 var (
@@ -44,4 +194,17 @@ var (
 	TimeControl_Hour = TimeControl{
 		Total: time.Hour,
 	}
+	// 40 moves in 90 minutes then 30 minutes, with 30 seconds added per move throughout
+	TimeControl_Classical = TimeControl{
+		Total:     90 * time.Minute,
+		Increment: 30 * time.Second,
+		Moves:     40,
+		Later: []TimeStage{
+			{Time: 30 * time.Minute, Increment: 30 * time.Second},
+		},
+	}
+	TimeControl_ThreeDays = TimeControl{
+		DaysPerMove:  3,
+		VacationDays: 14,
+	}
 )
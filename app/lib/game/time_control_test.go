@@ -0,0 +1,165 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeControlValidate(t *testing.T) {
+	tests := map[string]struct {
+		control TimeControl
+		valid   bool
+	}{
+		"Sudden death": {
+			control: TimeControl_Five,
+			valid:   true,
+		},
+		"Increment": {
+			control: TimeControl_FiveTwo,
+			valid:   true,
+		},
+		"Simple delay": {
+			control: TimeControl{Total: 5 * time.Minute, Delay: 5 * time.Second, DelayMode: DelayMode_Simple},
+			valid:   true,
+		},
+		"Bronstein delay": {
+			control: TimeControl{Total: 5 * time.Minute, Delay: 5 * time.Second, DelayMode: DelayMode_Bronstein},
+			valid:   true,
+		},
+		"Too short": {
+			control: TimeControl{Total: 30 * time.Second},
+		},
+		"Delay without a mode": {
+			control: TimeControl{Total: 5 * time.Minute, Delay: 5 * time.Second},
+		},
+		"Mode without a delay": {
+			control: TimeControl{Total: 5 * time.Minute, DelayMode: DelayMode_Simple},
+		},
+		"Delay and increment": {
+			control: TimeControl{Total: 5 * time.Minute, Increment: 2 * time.Second, Delay: 5 * time.Second, DelayMode: DelayMode_Simple},
+		},
+		"Delay too long": {
+			control: TimeControl{Total: 5 * time.Minute, Delay: 3 * time.Minute, DelayMode: DelayMode_Bronstein},
+		},
+		"Unknown mode": {
+			control: TimeControl{Total: 5 * time.Minute, Delay: 5 * time.Second, DelayMode: DelayMode(7)},
+		},
+		"Classical": {
+			control: TimeControl_Classical,
+			valid:   true,
+		},
+		"Stages with delays": {
+			control: TimeControl{Total: time.Hour, Moves: 30, Later: []TimeStage{
+				{Moves: 20, Time: 30 * time.Minute, Delay: 10 * time.Second, DelayMode: DelayMode_Bronstein},
+				{Time: 15 * time.Minute, Delay: 5 * time.Second, DelayMode: DelayMode_Simple},
+			}},
+			valid: true,
+		},
+		"Moves without later stages": {
+			control: TimeControl{Total: 90 * time.Minute, Moves: 40},
+		},
+		"Later stages without moves": {
+			control: TimeControl{Total: 90 * time.Minute, Later: []TimeStage{{Time: 30 * time.Minute}}},
+		},
+		"Stage after the last": {
+			control: TimeControl{Total: 90 * time.Minute, Moves: 40, Later: []TimeStage{
+				{Time: 30 * time.Minute},
+				{Time: 15 * time.Minute},
+			}},
+		},
+		"Invalid later stage": {
+			control: TimeControl{Total: 90 * time.Minute, Moves: 40, Later: []TimeStage{
+				{Time: 30 * time.Minute, Delay: 5 * time.Second},
+			}},
+		},
+		"Correspondence": {
+			control: TimeControl_ThreeDays,
+			valid:   true,
+		},
+		"Correspondence without vacations": {
+			control: TimeControl{DaysPerMove: 14},
+			valid:   true,
+		},
+		"Too many days per move": {
+			control: TimeControl{DaysPerMove: 15},
+		},
+		"Too many vacation days": {
+			control: TimeControl{DaysPerMove: 3, VacationDays: 31},
+		},
+		"Correspondence with a clock": {
+			control: TimeControl{DaysPerMove: 3, Total: time.Hour},
+		},
+		"Vacations against the clock": {
+			control: TimeControl{Total: time.Hour, VacationDays: 7},
+		},
+		"Too many stages": {
+			control: TimeControl{Total: time.Hour, Moves: 10, Later: []TimeStage{
+				{Moves: 10, Time: time.Minute},
+				{Moves: 10, Time: time.Minute},
+				{Moves: 10, Time: time.Minute},
+				{Moves: 10, Time: time.Minute},
+				{Time: time.Minute},
+			}},
+		},
+	}
+
+	for title, test := range tests {
+		if got := test.control.Validate(); got != test.valid {
+			t.Errorf("%s: Validate() = %t, want %t", title, got, test.valid)
+		}
+	}
+}
+
+func TestParseDelayMode(t *testing.T) {
+	tests := map[string]struct {
+		mode DelayMode
+		ok   bool
+	}{
+		"":          {DelayMode_None, true},
+		"none":      {DelayMode_None, true},
+		"simple":    {DelayMode_Simple, true},
+		"US":        {DelayMode_Simple, true},
+		"Bronstein": {DelayMode_Bronstein, true},
+		"fischer":   {DelayMode_None, false},
+	}
+	for name, test := range tests {
+		mode, ok := ParseDelayMode(name)
+		if mode != test.mode || ok != test.ok {
+			t.Errorf("ParseDelayMode(%q) = %d, %t, want %d, %t", name, mode, ok, test.mode, test.ok)
+		}
+		if roundTrip, _ := ParseDelayMode(mode.String()); roundTrip != mode {
+			t.Errorf("ParseDelayMode(%q) = %d, want %d", mode.String(), roundTrip, mode)
+		}
+	}
+}
+
+func TestTimeControlStageOf(t *testing.T) {
+	control := TimeControl{Total: time.Hour, Moves: 40, Later: []TimeStage{
+		{Moves: 20, Time: 30 * time.Minute},
+		{Time: 15 * time.Minute},
+	}}
+	tests := map[int]int{1: 0, 40: 0, 41: 1, 60: 1, 61: 2, 200: 2}
+	for move, want := range tests {
+		if got := control.StageOf(move); got != want {
+			t.Errorf("StageOf(%d) = %d, want %d", move, got, want)
+		}
+	}
+	if got := TimeControl_Five.StageOf(100); got != 0 {
+		t.Errorf("StageOf(100) of a single stage = %d, want 0", got)
+	}
+}
+
+func TestTimeControlEqual(t *testing.T) {
+	classical := TimeControl_Classical
+	classical.Later = []TimeStage{{Time: 30 * time.Minute, Increment: 30 * time.Second}}
+	if !TimeControl_Classical.Equal(classical) {
+		t.Errorf("Equal() = false for the same stages")
+	}
+	classical.Later[0].Increment = 0
+	if TimeControl_Classical.Equal(classical) {
+		t.Errorf("Equal() = true for different later stages")
+	}
+	if TimeControl_ThreeTwo.Equal(TimeControl_FiveTwo) {
+		t.Errorf("Equal() = true for different totals")
+	}
+}
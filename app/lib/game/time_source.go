@@ -0,0 +1,44 @@
+package game
+
+import (
+	"sync"
+	"time"
+)
+
+// TimeSource tells the time, letting games and their clocks be driven by something other
+// than the system clock
+type TimeSource interface {
+	Now() time.Time
+}
+
+type systemTimeSource struct{}
+
+func (systemTimeSource) Now() time.Time {
+	return time.Now()
+}
+
+// SystemTime is the time source used unless another is given
+var SystemTime TimeSource = systemTimeSource{}
+
+// FakeTimeSource only moves when advanced, so tests can control exactly how much time
+// passes on a clock
+type FakeTimeSource struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFakeTimeSource(start time.Time) *FakeTimeSource {
+	return &FakeTimeSource{now: start}
+}
+
+func (f *FakeTimeSource) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *FakeTimeSource) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
@@ -0,0 +1,43 @@
+package game
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// UCI returns the move in the long algebraic notation used by the Universal Chess
+// Interface, e.g. e2e4 or e7e8q. Castling is written as the king's two square move in
+// standard games, e.g. e1g1, and as the king taking its own rook in Chess960, e.g. e1h1.
+func (m Move) UCI() string {
+	notation := m.From.String() + m.To.String()
+	if m.Promotion != nil {
+		notation += string(unicode.ToLower(m.Promotion.Symbol()))
+	}
+	return notation
+}
+
+func ParseUCIMove(notation string) (*Move, error) {
+	if len(notation) != 4 && len(notation) != 5 {
+		return nil, fmt.Errorf("uci: invalid move %q", notation)
+	}
+	board := NewBoard()
+	from, err := ParseSquare(notation[0:2])
+	if err != nil || !board.ContainsSquare(*from) {
+		return nil, fmt.Errorf("uci: invalid origin square in %q", notation)
+	}
+	to, err := ParseSquare(notation[2:4])
+	if err != nil || !board.ContainsSquare(*to) {
+		return nil, fmt.Errorf("uci: invalid target square in %q", notation)
+	}
+
+	move := &Move{From: *from, To: *to}
+	if len(notation) == 5 {
+		symbol := rune(notation[4])
+		promotion, ok := ParsePieceType(symbol)
+		if !ok || !unicode.IsLower(symbol) || !NewPawn(PieceColor_White).canPromoteTo(promotion) {
+			return nil, fmt.Errorf("uci: invalid promotion in %q", notation)
+		}
+		move.Promotion = &promotion
+	}
+	return move, nil
+}
@@ -0,0 +1,63 @@
+package game
+
+import (
+	"testing"
+)
+
+func TestUCIRoundTrip(t *testing.T) {
+	tests := map[string]Move{
+		"e2e4":  {From: sq("e2"), To: sq("e4")},
+		"g8f6":  {From: sq("g8"), To: sq("f6")},
+		"e1g1":  {From: sq("e1"), To: sq("g1")},
+		"e7e8q": {From: sq("e7"), To: sq("e8"), Promotion: pieceTypePtr(PieceType_Queen)},
+		"a2b1n": {From: sq("a2"), To: sq("b1"), Promotion: pieceTypePtr(PieceType_Knight)},
+	}
+
+	for notation, want := range tests {
+		got, err := ParseUCIMove(notation)
+		if err != nil {
+			t.Errorf("ParseUCIMove(%s) errored: %v", notation, err)
+			continue
+		}
+		if !sameMove(*got, want) {
+			t.Errorf("ParseUCIMove(%s) got %v, want %v", notation, *got, want)
+		}
+		if uci := want.UCI(); uci != notation {
+			t.Errorf("UCI() of %v got %s, want %s", want, uci, notation)
+		}
+	}
+}
+
+func TestParseUCIMoveRejectsInvalid(t *testing.T) {
+	for _, notation := range []string{"", "e2", "e2e", "e2e4e5", "i2e4", "e9e4", "e2e0", "e7e8k", "e7e8Q", "e7e8x"} {
+		if move, err := ParseUCIMove(notation); err == nil {
+			t.Errorf("ParseUCIMove(%s) was accepted as %v", notation, *move)
+		}
+	}
+}
+
+func TestUCIMovesPlayable(t *testing.T) {
+	g := NewGame(TimeControl_Thirty)
+	g.Start()
+
+	moves := []string{"e2e4", "e7e5", "g1f3", "b8c6", "f1c4", "g8f6", "e1g1"}
+	for _, notation := range moves {
+		move, err := ParseUCIMove(notation)
+		if err != nil {
+			t.Fatalf("ParseUCIMove(%s) failed unexpectedly: %v", notation, err)
+		}
+		if err := g.Move(*move); err != nil {
+			t.Fatalf("Move(%s) failed unexpectedly: %v", notation, err)
+		}
+	}
+
+	if got := g.Snapshot().SANMoves[len(moves)-1]; got != "O-O" {
+		t.Errorf("castling by UCI got SAN %s, want %s", got, "O-O")
+	}
+	uciMoves := g.Snapshot().UCIMoves
+	for i, notation := range moves {
+		if uciMoves[i] != notation {
+			t.Errorf("Snapshot() UCI move %d got %s, want %s", i, uciMoves[i], notation)
+		}
+	}
+}
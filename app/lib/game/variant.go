@@ -0,0 +1,110 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+type Variant int
+
+const (
+	Variant_Standard Variant = iota
+	// Fischer Random, the back rank is shuffled and castling is written king takes rook
+	Variant_Chess960
+)
+
+const (
+	Chess960_NumPositions = 960
+	// Index of RNBQKBNR in the Scharnagl numbering
+	Chess960_StandardPosition = 518
+)
+
+// String returns the name used for the variant in the PGN Variant tag
+func (v Variant) String() string {
+	switch v {
+	case Variant_Chess960:
+		return "Chess960"
+	default:
+		return "Standard"
+	}
+}
+
+// ParseVariant accepts the variant names commonly found in PGN Variant tags
+func ParseVariant(name string) (Variant, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "standard", "normal":
+		return Variant_Standard, true
+	case "chess960", "chess 960", "fischerandom", "fischer random", "960":
+		return Variant_Chess960, true
+	default:
+		return Variant_Standard, false
+	}
+}
+
+// Chess960BackRank returns white's back rank from a1 to h1 for one of the 960 starting
+// positions, numbered as by Reinhard Scharnagl so that 518 is the standard setup
+func Chess960BackRank(index int) ([]PieceType, error) {
+	if index < 0 || index >= Chess960_NumPositions {
+		return nil, fmt.Errorf("game: chess960 position must be between 0 and %d, got %d",
+			Chess960_NumPositions-1, index)
+	}
+	backRank := make([]*PieceType, boardNumFiles)
+	place := func(file int, t PieceType) {
+		backRank[file] = &t
+	}
+	// Places the piece on the nth empty square from the a-file
+	placeEmpty := func(n int, t PieceType) {
+		for file := range backRank {
+			if backRank[file] != nil {
+				continue
+			}
+			if n == 0 {
+				place(file, t)
+				return
+			}
+			n--
+		}
+	}
+
+	n := index
+	place(n%4*2+1, PieceType_Bishop)
+	n /= 4
+	place(n%4*2, PieceType_Bishop)
+	n /= 4
+	placeEmpty(n%6, PieceType_Queen)
+	n /= 6
+	knights := chess960KnightPlacements[n]
+	// The second knight is placed first so the first's count of empty squares holds
+	placeEmpty(knights[1], PieceType_Knight)
+	placeEmpty(knights[0], PieceType_Knight)
+	placeEmpty(0, PieceType_Rook)
+	placeEmpty(0, PieceType_King)
+	placeEmpty(0, PieceType_Rook)
+
+	out := make([]PieceType, boardNumFiles)
+	for file, t := range backRank {
+		out[file] = *t
+	}
+	return out, nil
+}
+
+func NewChess960GameState(index int) (*GameState, error) {
+	backRank, err := Chess960BackRank(index)
+	if err != nil {
+		return nil, err
+	}
+	return newGameState(backRank, Variant_Chess960), nil
+}
+
+func RandomChess960Index() int {
+	return rand.Intn(Chess960_NumPositions)
+}
+
+// Helpers
+
+// Empty squares taken by the two knights once bishops and queen are placed
+var chess960KnightPlacements = [10][2]int{
+	{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2},
+	{1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4},
+}
@@ -0,0 +1,284 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChess960BackRank(t *testing.T) {
+	tests := map[int]string{
+		0:                         "BBQNNRKR",
+		Chess960_StandardPosition: "RNBQKBNR",
+		959:                       "RKRNNQBB",
+	}
+	for index, want := range tests {
+		backRank, err := Chess960BackRank(index)
+		if err != nil {
+			t.Errorf("Chess960BackRank(%d) errored: %v", index, err)
+			continue
+		}
+		if got := backRankString(backRank); got != want {
+			t.Errorf("Chess960BackRank(%d) got %s, want %s", index, got, want)
+		}
+	}
+
+	for _, index := range []int{-1, Chess960_NumPositions} {
+		if _, err := Chess960BackRank(index); err == nil {
+			t.Errorf("Chess960BackRank(%d) accepted an invalid index", index)
+		}
+	}
+}
+
+func TestChess960PositionsAreDistinctAndValid(t *testing.T) {
+	seen := make(map[string]int)
+	for index := 0; index < Chess960_NumPositions; index++ {
+		backRank, _ := Chess960BackRank(index)
+		setup := backRankString(backRank)
+		if other, ok := seen[setup]; ok {
+			t.Errorf("positions %d and %d are both %s", other, index, setup)
+		}
+		seen[setup] = index
+
+		bishops := strings.Index(setup, "B") + strings.LastIndex(setup, "B")
+		if bishops%2 == 0 {
+			t.Errorf("position %d %s has bishops on the same color", index, setup)
+		}
+		king := strings.Index(setup, "K")
+		if !(strings.Index(setup, "R") < king && king < strings.LastIndex(setup, "R")) {
+			t.Errorf("position %d %s does not have the king between the rooks", index, setup)
+		}
+	}
+}
+
+func TestChess960GameState(t *testing.T) {
+	g, err := NewChess960GameState(0)
+	if err != nil {
+		t.Fatalf("NewChess960GameState(0) failed unexpectedly: %v", err)
+	}
+	if got, want := g.FEN(), "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w KQkq - 0 1"; got != want {
+		t.Errorf("FEN() got %s, want %s", got, want)
+	}
+	if g.Variant() != Variant_Chess960 {
+		t.Errorf("Variant() got %v, want %v", g.Variant(), Variant_Chess960)
+	}
+	if got := g.Perft(2); got != 400 {
+		t.Errorf("Perft(2) got %d, want %d", got, 400)
+	}
+}
+
+func TestChess960Perft(t *testing.T) {
+	// From the Chess960 perft suite, written in Shredder-FEN
+	tests := map[string][]int{
+		"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9":   {21, 528, 12189, 326672},
+		"2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9":      {21, 807, 18002, 667366},
+		"b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9":         {20, 479, 10471, 273318},
+		"qbbnnrkr/2pp2pp/p7/1p2pp2/8/P3PP2/1PPP1KPP/QBBNNR1R w hf - 0 9":      {22, 593, 13440, 382958},
+		"1nbbnrkr/p1p1ppp1/3p4/1p3P1p/3Pq2P/8/PPP1P1P1/QNBBNRKR w HFhf - 0 9": {28, 1120, 31058, 1171749},
+	}
+	for fen, nodes := range tests {
+		g, err := ParseVariantFEN(fen, Variant_Chess960)
+		if err != nil {
+			t.Errorf("ParseVariantFEN(%s) errored: %v", fen, err)
+			continue
+		}
+		maxDepth := len(nodes)
+		if testing.Short() {
+			maxDepth = 3
+		}
+		for depth := 1; depth <= maxDepth; depth++ {
+			if got := g.Perft(depth); got != nodes[depth-1] {
+				t.Errorf("%s: Perft(%d) got %d, want %d", fen, depth, got, nodes[depth-1])
+				break
+			}
+		}
+	}
+}
+
+func TestChess960Castling(t *testing.T) {
+	tests := map[string]struct {
+		fen     string
+		move    string
+		san     string
+		want    string
+		illegal bool
+	}{
+		"King and rook swap": {
+			fen:  "4k3/8/8/8/8/8/8/5KR1 w G - 0 1",
+			move: "f1g1",
+			san:  "O-O",
+			want: "4k3/8/8/8/8/8/8/5RK1 b - - 1 1",
+		},
+		"King stays on its square": {
+			fen:  "4k3/8/8/8/8/8/8/1R1K4 w B - 0 1",
+			move: "d1b1",
+			san:  "O-O-O",
+			want: "4k3/8/8/8/8/8/8/2KR4 b - - 1 1",
+		},
+		"Rook passing over the king's destination": {
+			fen:  "r3k3/8/8/8/8/8/8/4K3 b q - 0 1",
+			move: "e8a8",
+			san:  "O-O-O",
+			want: "2kr4/8/8/8/8/8/8/4K3 w - - 1 2",
+		},
+		"Path blocked by another piece": {
+			fen:     "4k3/8/8/8/8/8/8/RN2K3 w A - 0 1",
+			move:    "e1a1",
+			illegal: true,
+		},
+		"Castling rook shielding the king": {
+			fen:     "4k3/8/8/8/8/8/8/rRK5 w B - 0 1",
+			move:    "c1b1",
+			illegal: true,
+		},
+		"King passing through check": {
+			fen:     "4kr2/8/8/8/8/8/8/4K2R w K - 0 1",
+			move:    "e1h1",
+			illegal: true,
+		},
+	}
+
+	for title, test := range tests {
+		g, err := ParseVariantFEN(test.fen, Variant_Chess960)
+		if err != nil {
+			t.Errorf("%s: ParseVariantFEN(%s) errored: %v", title, test.fen, err)
+			continue
+		}
+		move, _ := ParseUCIMove(test.move)
+		san, sanErr := g.MoveSAN(*move)
+		next, err := g.WithMove(*move)
+		if test.illegal {
+			if err == nil {
+				t.Errorf("%s: castling %s was accepted", title, test.move)
+			}
+			continue
+		}
+		if err != nil || sanErr != nil {
+			t.Errorf("%s: castling %s failed: %v %v", title, test.move, err, sanErr)
+			continue
+		}
+		if san != test.san {
+			t.Errorf("%s: MoveSAN() got %s, want %s", title, san, test.san)
+		}
+		if got := next.FEN(); got != test.want {
+			t.Errorf("%s: castling got %s, want %s", title, got, test.want)
+		}
+		plan, err := g.ParseSAN(test.san)
+		if err != nil || plan.UCI() != test.move {
+			t.Errorf("%s: ParseSAN(%s) got %v, want %s", title, test.san, plan, test.move)
+		}
+	}
+}
+
+func TestChess960FENCastlingRights(t *testing.T) {
+	tests := map[string]string{
+		// Outermost rooks are written as KQkq
+		"rkr5/8/8/8/8/8/8/RKR5 w KQkq - 0 1": "rkr5/8/8/8/8/8/8/RKR5 w KQkq - 0 1",
+		"rkr5/8/8/8/8/8/8/RKR5 w CAca - 0 1": "rkr5/8/8/8/8/8/8/RKR5 w KQkq - 0 1",
+		// Inner rooks are written by file
+		"1k3r1r/8/8/8/8/8/8/R2R2K1 w Dh - 0 1": "1k3r1r/8/8/8/8/8/8/R2R2K1 w Dk - 0 1",
+		"1k3r1r/8/8/8/8/8/8/R2R2K1 w Qf - 0 1": "1k3r1r/8/8/8/8/8/8/R2R2K1 w Qf - 0 1",
+	}
+	for fen, want := range tests {
+		g, err := ParseVariantFEN(fen, Variant_Chess960)
+		if err != nil {
+			t.Errorf("ParseVariantFEN(%s) errored: %v", fen, err)
+			continue
+		}
+		if got := g.FEN(); got != want {
+			t.Errorf("ParseVariantFEN(%s).FEN() got %s, want %s", fen, got, want)
+		}
+	}
+
+	for _, fen := range []string{
+		"rkr5/8/8/8/8/8/8/RKR5 w B - 0 1",  // King's file
+		"rkr5/8/8/8/8/8/8/RKR5 w E - 0 1",  // No rook
+		"rkr5/8/8/8/8/8/8/RKR5 w X - 0 1",  // Not a file
+		"rkr5/8/8/8/8/8/K7/R1R5 w Q - 0 1", // King off the back rank
+	} {
+		if _, err := ParseVariantFEN(fen, Variant_Chess960); err == nil {
+			t.Errorf("ParseVariantFEN(%s) accepted invalid castling rights", fen)
+		}
+	}
+}
+
+func TestChess960PGN(t *testing.T) {
+	state, _ := NewChess960GameState(0)
+	g := NewGameWithState(TimeControl_Thirty, state)
+	g.Start()
+	for _, san := range []string{"f4", "f5", "Rf3", "Rf6", "O-O", "O-O"} {
+		move, err := g.ParseSAN(san)
+		if err != nil {
+			t.Fatalf("ParseSAN(%s) failed unexpectedly: %v", san, err)
+		}
+		if err := g.Move(move); err != nil {
+			t.Fatalf("Move(%s) failed unexpectedly: %v", san, err)
+		}
+	}
+	if got := g.Snapshot().UCIMoves[4]; got != "g1h1" {
+		t.Errorf("castling got UCI %s, want %s", got, "g1h1")
+	}
+
+	pgn := g.PGN()
+	for _, tag := range []string{
+		`[Variant "Chess960"]`,
+		`[SetUp "1"]`,
+		`[FEN "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w KQkq - 0 1"]`,
+	} {
+		if !strings.Contains(pgn, tag) {
+			t.Errorf("PGN() missing tag %s in\n%s", tag, pgn)
+		}
+	}
+
+	parsed, err := ParsePGN(pgn)
+	if err != nil {
+		t.Fatalf("ParsePGN() failed unexpectedly: %v", err)
+	}
+	if got, want := parsed.Final.FEN(), g.State().FEN(); got != want {
+		t.Errorf("ParsePGN() final position got %s, want %s", got, want)
+	}
+}
+
+func TestPGNMoveNumbersFromPosition(t *testing.T) {
+	g := NewGameWithState(TimeControl_Thirty, MustParseFEN("4k3/8/8/8/8/8/8/R3K3 b Q - 0 12"))
+	g.Start()
+	for _, san := range []string{"Kd7", "O-O-O+", "Ke7"} {
+		move, err := g.ParseSAN(san)
+		if err != nil {
+			t.Fatalf("ParseSAN(%s) failed unexpectedly: %v", san, err)
+		}
+		if err := g.Move(move); err != nil {
+			t.Fatalf("Move(%s) failed unexpectedly: %v", san, err)
+		}
+	}
+	if want := "12... Kd7 13. O-O-O+ Ke7 *"; !strings.Contains(g.PGN(), want) {
+		t.Errorf("PGN() movetext got\n%s\nwant %s", g.PGN(), want)
+	}
+}
+
+func TestParseVariant(t *testing.T) {
+	tests := map[string]Variant{
+		"Standard":     Variant_Standard,
+		"":             Variant_Standard,
+		"chess960":     Variant_Chess960,
+		"Chess960":     Variant_Chess960,
+		"Fischerandom": Variant_Chess960,
+	}
+	for name, want := range tests {
+		if got, ok := ParseVariant(name); !ok || got != want {
+			t.Errorf("ParseVariant(%q) got %v, want %v", name, got, want)
+		}
+	}
+	if _, ok := ParseVariant("crazyhouse"); ok {
+		t.Errorf("ParseVariant() accepted an unsupported variant")
+	}
+}
+
+// Helpers
+
+func backRankString(backRank []PieceType) string {
+	var sb strings.Builder
+	for _, t := range backRank {
+		sb.WriteRune(t.Symbol())
+	}
+	return sb.String()
+}
@@ -0,0 +1,83 @@
+package game
+
+import (
+	"math/bits"
+	"math/rand/v2"
+)
+
+// Zobrist keys are drawn from a fixed seed so hashes are stable between runs
+var (
+	zobristPieces         [2][6][numSquares]uint64
+	zobristBlackToMove    uint64
+	zobristCastlingRights [4]uint64
+	zobristEnPassantFile  [boardNumFiles]uint64
+)
+
+func init() {
+	r := rand.New(rand.NewPCG(0x5a0b71c7, 0x9e3779b97f4a7c15))
+	for color := range zobristPieces {
+		for t := range zobristPieces[color] {
+			for index := range zobristPieces[color][t] {
+				zobristPieces[color][t][index] = r.Uint64()
+			}
+		}
+	}
+	zobristBlackToMove = r.Uint64()
+	for i := range zobristCastlingRights {
+		zobristCastlingRights[i] = r.Uint64()
+	}
+	for i := range zobristEnPassantFile {
+		zobristEnPassantFile[i] = r.Uint64()
+	}
+}
+
+func zobristPiece(piece Piece, index int) uint64 {
+	return zobristPieces[piece.Color()][piece.Type()][index]
+}
+
+// Hash returns the Zobrist key of the position. Positions which are the same for the
+// purposes of repetition, i.e. same pieces, side to move, castling rights and legal
+// en-passant captures, share a key.
+func (g *GameState) Hash() uint64 {
+	return g.hash
+}
+
+// Helpers
+
+// Combines the incrementally maintained piece key of the board with the rest of the state
+func (g *GameState) computeHash() uint64 {
+	hash := g.board.zobrist
+	if g.MovingSide() == PieceColor_Black {
+		hash ^= zobristBlackToMove
+	}
+	for _, right := range g.allCastlingRights() {
+		hash ^= zobristCastlingRights[right.index()]
+	}
+	if g.canCaptureEnPassant() {
+		hash ^= zobristEnPassantFile[g.enpassantTarget.File]
+	}
+	return hash
+}
+
+// Whether the side to move has a legal en-passant capture
+func (g *GameState) canCaptureEnPassant() bool {
+	if g.enpassantTarget == nil {
+		return false
+	}
+	side := g.MovingSide()
+	target := *g.enpassantTarget
+	if !g.board.pieces(PieceType_Pawn, side.Opponent()).Has(target) {
+		return false
+	}
+	to := target.Adding(pawnDelta[side])
+	for pawns := g.board.pieces(PieceType_Pawn, side); pawns != 0; pawns &= pawns - 1 {
+		from := indexSquare(bits.TrailingZeros64(uint64(pawns)))
+		if from.Rank != target.Rank || abs(from.File-target.File) != 1 {
+			continue
+		}
+		if g.leavesKingSafe(Move{From: from, To: to}, side) {
+			return true
+		}
+	}
+	return false
+}
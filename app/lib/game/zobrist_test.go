@@ -0,0 +1,61 @@
+package game
+
+import (
+	"testing"
+)
+
+func TestHashTranspositions(t *testing.T) {
+	a := continueGame("Knights first", []testMove{{"g1", "f3"}, {"g8", "f6"}, {"b1", "c3"}, {"b8", "c6"}}, false, NewGameState(), t)
+	b := continueGame("Knights swapped", []testMove{{"b1", "c3"}, {"b8", "c6"}, {"g1", "f3"}, {"g8", "f6"}}, false, NewGameState(), t)
+	if a.Hash() != b.Hash() {
+		t.Errorf("transposed positions got hashes %x and %x", a.Hash(), b.Hash())
+	}
+	if a.Hash() == NewGameState().Hash() {
+		t.Errorf("different positions share hash %x", a.Hash())
+	}
+}
+
+func TestHashMatchesFEN(t *testing.T) {
+	for title, fen := range fenCorpus {
+		g := MustParseFEN(fen)
+		for _, plan := range g.PlanPossibleMovesForSide(g.MovingSide()) {
+			for _, reply := range plan.Game.PlanPossibleMovesForSide(plan.Game.MovingSide()) {
+				parsed := MustParseFEN(reply.Game.FEN())
+				if reply.Game.Hash() != parsed.Hash() {
+					t.Errorf("%s: %s %s got hash %x, FEN %s hashes to %x",
+						title, plan.UCI(), reply.UCI(), reply.Game.Hash(), reply.Game.FEN(), parsed.Hash())
+				}
+			}
+		}
+	}
+}
+
+func TestHashPositionDetails(t *testing.T) {
+	tests := map[string]struct {
+		a, b string
+		same bool
+	}{
+		"Side to move": {
+			"4k3/8/8/8/8/8/8/4K3 w - - 0 1", "4k3/8/8/8/8/8/8/4K3 b - - 0 1", false,
+		},
+		"Move counters": {
+			"4k3/8/8/8/8/8/8/4K3 w - - 0 1", "4k3/8/8/8/8/8/8/4K3 w - - 12 40", true,
+		},
+		"Castling rights": {
+			"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "r3k2r/8/8/8/8/8/8/R3K2R w Kkq - 0 1", false,
+		},
+		"Legal en-passant capture": {
+			"4k3/8/8/1Pp5/8/8/8/4K3 w - c6 0 1", "4k3/8/8/1Pp5/8/8/8/4K3 w - - 0 1", false,
+		},
+		"En-passant capture exposing the king": {
+			"8/8/8/KPp4r/8/8/8/4k3 w - c6 0 1", "8/8/8/KPp4r/8/8/8/4k3 w - - 0 1", true,
+		},
+	}
+
+	for title, test := range tests {
+		a, b := MustParseFEN(test.a), MustParseFEN(test.b)
+		if same := a.Hash() == b.Hash(); same != test.same {
+			t.Errorf("%s: hashes %x and %x equal = %v, want %v", title, a.Hash(), b.Hash(), same, test.same)
+		}
+	}
+}